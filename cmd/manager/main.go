@@ -1,21 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
+	"io/ioutil"
 	"os"
 	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	"github.com/isaaguilar/terraform-operator/pkg/apis"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
 	"github.com/isaaguilar/terraform-operator/pkg/controllers"
 	"github.com/isaaguilar/terraform-operator/pkg/webhook/admission"
+	"github.com/isaaguilar/terraform-operator/pkg/webhook/gitpush"
 	localcache "github.com/patrickmn/go-cache"
 	"go.uber.org/zap/zapcore"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -41,6 +48,21 @@ func main() {
 	var probeAddr string
 	var maxConcurrentReconciles int
 	var disableConversionWebhook bool
+	var taskPendingTimeout time.Duration
+	var taskFailureLogLines int64
+	var maxConcurrentWorkflows int
+	var maxConcurrentWorkflowsPerNamespace int
+	var concurrencyGroupNamespace string
+	var gitWebhookSecret string
+	var trustedCAFile string
+	var cliConfigFile string
+	var pluginCacheVolumeFile string
+	var pluginCacheRetention time.Duration
+	var runnerImage string
+	var engineVersionsConfigMap string
+	var engineVersionsFromRegistry bool
+	var strictEngineVersions bool
+	var allowCrossNamespaceDependencies bool
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -49,6 +71,21 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "The max number of concurrent Reconciles for the controller")
+	flag.IntVar(&maxConcurrentWorkflows, "max-concurrent-workflows", 0, "The max number of workflows running at the same time across the cluster. Workflows over the limit are queued. Set to 0 for no limit")
+	flag.IntVar(&maxConcurrentWorkflowsPerNamespace, "max-concurrent-workflows-per-namespace", 0, "The max number of workflows running at the same time in a namespace. Workflows over the limit are queued. Set to 0 for no limit")
+	flag.StringVar(&concurrencyGroupNamespace, "concurrency-group-namespace", os.Getenv("POD_NAMESPACE"), "The namespace of the Leases used to lock concurrency groups. Defaults to the namespace of the controller")
+	flag.StringVar(&gitWebhookSecret, "git-webhook-secret", os.Getenv("GIT_WEBHOOK_SECRET"), "The secret used to validate git push webhooks at /git-webhook. The endpoint is disabled when empty")
+	flag.StringVar(&trustedCAFile, "trusted-ca-file", os.Getenv("TRUSTED_CA_FILE"), "A PEM encoded CA bundle trusted by every resource's tasks and by the controller's git and registry clients")
+	flag.StringVar(&cliConfigFile, "cli-config-file", os.Getenv("CLI_CONFIG_FILE"), "A YAML or JSON file with the default spec.cliConfig of every resource, eg the provider mirrors of an air-gapped cluster")
+	flag.StringVar(&pluginCacheVolumeFile, "plugin-cache-volume-file", os.Getenv("PLUGIN_CACHE_VOLUME_FILE"), "A YAML or JSON file with the volume source, eg a nfs share, of the plugin cache shared by resources using the Cluster scope")
+	flag.DurationVar(&pluginCacheRetention, "plugin-cache-retention", 720*time.Hour, "How long a provider version that is not installed by any init is kept in the plugin caches. Versions used by a running workflow are kept longer")
+	flag.StringVar(&runnerImage, "runner-image", os.Getenv("RUNNER_IMAGE"), "The image of the task runner. When set, the tasks run the runner, which is copied from the image by an init container, instead of the entrypoint of the task images")
+	flag.StringVar(&engineVersionsConfigMap, "engine-versions-configmap", os.Getenv("ENGINE_VERSIONS_CONFIGMAP"), "The ConfigMap, as `namespace/name`, that lists the available versions of each engine under the `terraform` and `opentofu` keys. Version constraints are resolved against the list")
+	flag.BoolVar(&engineVersionsFromRegistry, "engine-versions-from-registry", false, "Resolve version constraints against the tags of the terraform task image when the engine versions ConfigMap is not set")
+	flag.BoolVar(&strictEngineVersions, "strict-engine-versions", false, "Reject resources that run the latest version of their engine")
+	flag.BoolVar(&allowCrossNamespaceDependencies, "allow-cross-namespace-dependencies", false, "Allow resources to depend on and read the outputs of resources in other namespaces")
+	flag.Int64Var(&taskFailureLogLines, "task-failure-log-lines", 20, "The number of lines of a failed task's log to add to the resource status. Set to 0 to disable")
+	flag.DurationVar(&taskPendingTimeout, "task-pending-timeout", 15*time.Minute, "The default time a task pod can be pending before the stage is failed. Set to 0 to disable")
 	opts := zap.Options{
 		Development: true,
 		Level:       zapcore.DebugLevel,
@@ -56,6 +93,10 @@ func main() {
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	if concurrencyGroupNamespace == "" {
+		concurrencyGroupNamespace = "tf-system"
+	}
+
 	globalEnvFromConfigmapData := make(map[string]string)
 	globalEnvFromSecretData := make(map[string][]byte)
 	for _, env := range os.Environ() {
@@ -71,6 +112,47 @@ func main() {
 	}
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	var trustedCA []byte
+	if trustedCAFile != "" {
+		var err error
+		trustedCA, err = ioutil.ReadFile(trustedCAFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read the trusted CA file")
+			os.Exit(1)
+		}
+	}
+	var cliConfig *tfv1alpha2.CLIConfig
+	if cliConfigFile != "" {
+		b, err := ioutil.ReadFile(cliConfigFile)
+		if err == nil {
+			cliConfig = &tfv1alpha2.CLIConfig{}
+			err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), len(b)).Decode(cliConfig)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to read the cli config file")
+			os.Exit(1)
+		}
+	}
+	var pluginCacheVolume *corev1.VolumeSource
+	if pluginCacheVolumeFile != "" {
+		b, err := ioutil.ReadFile(pluginCacheVolumeFile)
+		if err == nil {
+			pluginCacheVolume = &corev1.VolumeSource{}
+			err = yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), len(b)).Decode(pluginCacheVolume)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to read the plugin cache volume file")
+			os.Exit(1)
+		}
+	}
+	var engineVersions *types.NamespacedName
+	if engineVersionsConfigMap != "" {
+		engineVersions = &types.NamespacedName{Namespace: os.Getenv("POD_NAMESPACE"), Name: engineVersionsConfigMap}
+		if i := strings.Index(engineVersionsConfigMap, "/"); i != -1 {
+			engineVersions = &types.NamespacedName{Namespace: engineVersionsConfigMap[:i], Name: engineVersionsConfigMap[i+1:]}
+		}
+	}
 	c := localcache.New(60*time.Second, 3600*time.Second)
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
@@ -86,17 +168,33 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ReconcileTerraform{
-		Client:                     mgr.GetClient(),
-		Log:                        ctrl.Log.WithName("terraform_controller"),
-		Recorder:                   mgr.GetEventRecorderFor("terraform-controller"),
-		Scheme:                     mgr.GetScheme(),
-		MaxConcurrentReconciles:    maxConcurrentReconciles,
-		Cache:                      c,
-		GlobalEnvFromConfigmapData: globalEnvFromConfigmapData,
-		GlobalEnvFromSecretData:    globalEnvFromSecretData,
-		GlobalEnvSuffix:            "global-envs",
-	}).SetupWithManager(mgr); err != nil {
+	reconciler := &controllers.ReconcileTerraform{
+		Client:                             mgr.GetClient(),
+		Log:                                ctrl.Log.WithName("terraform_controller"),
+		Recorder:                           mgr.GetEventRecorderFor("terraform-controller"),
+		Scheme:                             mgr.GetScheme(),
+		MaxConcurrentReconciles:            maxConcurrentReconciles,
+		Cache:                              c,
+		GlobalEnvFromConfigmapData:         globalEnvFromConfigmapData,
+		GlobalEnvFromSecretData:            globalEnvFromSecretData,
+		GlobalEnvSuffix:                    "global-envs",
+		TaskPendingTimeout:                 taskPendingTimeout,
+		Clientset:                          kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		TaskFailureLogLines:                taskFailureLogLines,
+		MaxConcurrentWorkflows:             maxConcurrentWorkflows,
+		MaxConcurrentWorkflowsPerNamespace: maxConcurrentWorkflowsPerNamespace,
+		ConcurrencyGroupNamespace:          concurrencyGroupNamespace,
+		TrustedCA:                          trustedCA,
+		CLIConfig:                          cliConfig,
+		PluginCacheVolume:                  pluginCacheVolume,
+		PluginCacheRetention:               pluginCacheRetention,
+		RunnerImage:                        runnerImage,
+		EngineVersionsConfigMap:            engineVersions,
+		EngineVersionsFromRegistry:         engineVersionsFromRegistry,
+		StrictEngineVersions:               strictEngineVersions,
+		AllowCrossNamespaceDependencies:    allowCrossNamespaceDependencies,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
@@ -120,6 +218,9 @@ func main() {
 	if !disableConversionWebhook {
 		mgr.GetWebhookServer().Register("/conversion", admission.NewConversionWebhook(ctrl.Log.WithName("conversion")))
 	}
+	if gitWebhookSecret != "" {
+		mgr.GetWebhookServer().Register("/git-webhook", gitpush.NewWebhook([]byte(gitWebhookSecret), reconciler.TriggerGitPush, ctrl.Log.WithName("git-webhook")))
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
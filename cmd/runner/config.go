@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config is the environment the controller sets on the task pods
+type config struct {
+	// Task is the name of the task, eg setup or plan
+	Task      string
+	Namespace string
+
+	// EngineBinary is the binary the terraform tasks run, eg tofu
+	EngineBinary string
+
+	// Terragrunt runs terragrunt in TerragruntDir, relative to the main module, instead of the engine
+	Terragrunt        bool
+	TerragruntVersion string
+	TerragruntDir     string
+	TerragruntConfig  string
+	TerragruntRunAll  bool
+
+	// Workspace is the workspace selected by TF_WORKSPACE
+	Workspace string
+
+	// Mode is the mode of the workflow, eg refresh-only
+	Mode string
+
+	RootPath       string
+	Generation     string
+	GenerationPath string
+	MainModule     string
+
+	// Addons is the directory of the files rendered by the controller, eg the variables, the resource
+	// downloads and the inline scripts
+	Addons      string
+	CleanupDisk bool
+
+	MainModuleRepo       string
+	MainModuleRepoRef    string
+	MainModuleRepoSubdir string
+
+	MainModuleAddress  string
+	MainModuleChecksum string
+
+	URLSource           string
+	ConfigMapSourceName string
+	ConfigMapSourceKey  string
+	ConfigMapSourcePath string
+
+	// SecretVarFile is the tfvars file of the secret values
+	SecretVarFile string
+
+	SaveOutputs       bool
+	OutputsSecretName string
+	OutputsToInclude  []string
+	OutputsToOmit     []string
+
+	PluginCacheDir       string
+	PluginCacheLockFile  string
+	PluginCacheRetention time.Duration
+	// PluginCacheKeepSince is when the oldest workflow sharing the cache started. The versions marked since
+	// are kept even when they are older than the retention.
+	PluginCacheKeepSince time.Time
+
+	ErrorMarkerFile string
+
+	// TaskDoneFile is created when the task is done to stop the tunnel sidecar
+	TaskDoneFile string
+
+	// SSHPath is the directory of the ssh config and keys
+	SSHPath            string
+	TunnelHost         string
+	TunnelSocksAddress string
+	TunnelHosts        []string
+
+	GitAskpass   string
+	GitSSLCAInfo string
+}
+
+// loadConfig reads the config from the environment. The environment is looked up with getenv so the tests
+// do not depend on the environment of the process.
+func loadConfig(getenv func(string) string) (config, error) {
+	c := config{
+		Task:                 getenv("TFO_TASK"),
+		EngineBinary:         getenv("TFO_ENGINE_BINARY"),
+		Namespace:            getenv("TFO_NAMESPACE"),
+		TerragruntVersion:    getenv("TFO_TERRAGRUNT_VERSION"),
+		TerragruntDir:        getenv("TFO_TERRAGRUNT_DIR"),
+		TerragruntConfig:     getenv("TFO_TERRAGRUNT_CONFIG"),
+		Workspace:            getenv("TF_WORKSPACE"),
+		Mode:                 getenv("TFO_MODE"),
+		RootPath:             getenv("TFO_ROOT_PATH"),
+		Generation:           getenv("TFO_GENERATION"),
+		GenerationPath:       getenv("TFO_GENERATION_PATH"),
+		MainModule:           getenv("TFO_MAIN_MODULE"),
+		Addons:               getenv("TFO_MAIN_MODULE_ADDONS"),
+		MainModuleRepo:       getenv("TFO_MAIN_MODULE_REPO"),
+		MainModuleRepoRef:    getenv("TFO_MAIN_MODULE_REPO_REF"),
+		MainModuleRepoSubdir: getenv("TFO_MAIN_MODULE_REPO_SUBDIR"),
+		MainModuleAddress:    getenv("TFO_MAIN_MODULE_ADDRESS"),
+		MainModuleChecksum:   getenv("TFO_MAIN_MODULE_CHECKSUM"),
+		URLSource:            getenv("TFO_TASK_EXEC_URL_SOURCE"),
+		ConfigMapSourceName:  getenv("TFO_TASK_EXEC_CONFIGMAP_SOURCE_NAME"),
+		ConfigMapSourceKey:   getenv("TFO_TASK_EXEC_CONFIGMAP_SOURCE_KEY"),
+		ConfigMapSourcePath:  getenv("TFO_TASK_EXEC_CONFIGMAP_SOURCE_PATH"),
+		SecretVarFile:        getenv("TFO_SECRET_VAR_FILE"),
+		OutputsSecretName:    getenv("TFO_OUTPUTS_SECRET_NAME"),
+		OutputsToInclude:     splitList(getenv("TFO_OUTPUTS_TO_INCLUDE")),
+		OutputsToOmit:        splitList(getenv("TFO_OUTPUTS_TO_OMIT")),
+		PluginCacheDir:       getenv("TF_PLUGIN_CACHE_DIR"),
+		PluginCacheLockFile:  getenv("TFO_PLUGIN_CACHE_LOCK_FILE"),
+		ErrorMarkerFile:      getenv("TFO_ERROR_MARKER_FILE"),
+		TaskDoneFile:         getenv("TFO_TASK_DONE_FILE"),
+		SSHPath:              getenv("TFO_SSH"),
+		TunnelHost:           getenv("TFO_SSH_TUNNEL_HOST"),
+		TunnelSocksAddress:   getenv("TFO_SSH_TUNNEL_SOCKS_ADDRESS"),
+		TunnelHosts:          splitList(getenv("TFO_SSH_TUNNEL_HOSTS")),
+		GitAskpass:           getenv("GIT_ASKPASS"),
+		GitSSLCAInfo:         getenv("GIT_SSL_CAINFO"),
+	}
+	if c.Task == "" {
+		return c, fmt.Errorf("TFO_TASK is not set")
+	}
+	if c.GenerationPath == "" {
+		return c, fmt.Errorf("TFO_GENERATION_PATH is not set")
+	}
+	if c.MainModule == "" {
+		c.MainModule = filepath.Join(c.GenerationPath, "main")
+	}
+	if c.EngineBinary == "" {
+		c.EngineBinary = "terraform"
+	}
+	if c.TerragruntDir == "" {
+		c.TerragruntDir = "."
+	}
+	if c.MainModuleRepoSubdir == "" {
+		c.MainModuleRepoSubdir = "."
+	}
+
+	var err error
+	for name, value := range map[string]*bool{
+		"TFO_CLEANUP_DISK":       &c.CleanupDisk,
+		"TFO_SAVE_OUTPUTS":       &c.SaveOutputs,
+		"TFO_TERRAGRUNT":         &c.Terragrunt,
+		"TFO_TERRAGRUNT_RUN_ALL": &c.TerragruntRunAll,
+	} {
+		if s := getenv(name); s != "" {
+			*value, err = strconv.ParseBool(s)
+			if err != nil {
+				return c, fmt.Errorf("%s is not a bool: %v", name, err)
+			}
+		}
+	}
+	if s := getenv("TFO_PLUGIN_CACHE_RETENTION"); s != "" {
+		c.PluginCacheRetention, err = time.ParseDuration(s)
+		if err != nil {
+			return c, fmt.Errorf("TFO_PLUGIN_CACHE_RETENTION is not a duration: %v", err)
+		}
+	}
+	if s := getenv("TFO_PLUGIN_CACHE_KEEP_SINCE"); s != "" {
+		seconds, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("TFO_PLUGIN_CACHE_KEEP_SINCE is not a unix time: %v", err)
+		}
+		c.PluginCacheKeepSince = time.Unix(seconds, 0)
+	}
+	return c, nil
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,182 @@
+// Command runner runs the setup, terraform and script tasks. It implements the default task scripts
+// bundled with the operator and runs the scripts configured in taskOptions. The task is configured by the
+// TFO_* environment the controller sets on the task pod.
+//
+// `runner install <dir>` copies the runner into the directory. The controller runs it in an init container
+// to give the runner to task images that do not have it.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-logr/logr"
+	"github.com/isaaguilar/terraform-operator/pkg/download"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+type runner struct {
+	config
+
+	// home is where the ssh config and keys are copied to
+	home string
+
+	// out receives the output of the task and of the commands it runs
+	out io.Writer
+	log logr.Logger
+
+	// terraform is the binary of the engine
+	terraform string
+
+	// terragrunt is the binary of terragrunt
+	terragrunt string
+
+	clientset kubernetes.Interface
+}
+
+func main() {
+	if len(os.Args) == 3 && os.Args[1] == "install" {
+		if err := install(os.Args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	c, err := loadConfig(os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	r := &runner{
+		config:     c,
+		out:        os.Stdout,
+		terraform:  c.EngineBinary,
+		terragrunt: "terragrunt",
+	}
+	r.home, _ = os.UserHomeDir()
+
+	// The output of the task is also kept in the generation's directory
+	if err := os.MkdirAll(c.GenerationPath, 0755); err == nil {
+		f, err := os.OpenFile(filepath.Join(c.GenerationPath, c.Task+".out"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			defer f.Close()
+			r.out = io.MultiWriter(os.Stdout, f)
+		}
+	}
+	r.log = zap.New(zap.WriteTo(r.out), zap.UseDevMode(true))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	err = r.run(ctx)
+	r.done()
+	if err != nil {
+		r.fail(err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// run runs the task's script. The tunnel started for the task is stopped when the task is done.
+func (r *runner) run(ctx context.Context) error {
+	if err := r.setupSSH(); err != nil {
+		return err
+	}
+	if r.TunnelHost != "" {
+		stop, err := download.StartTunnel(ctx, filepath.Join(r.home, ".ssh", "config"), r.TunnelHost, r.TunnelSocksAddress)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	script, builtin, err := r.findScript(ctx)
+	if err != nil {
+		return err
+	}
+	if builtin != nil {
+		return builtin(r, ctx)
+	}
+	return r.runScript(ctx, script)
+}
+
+// done creates the done file, which stops the tunnel sidecar
+func (r *runner) done() {
+	if r.TaskDoneFile != "" {
+		_ = ioutil.WriteFile(r.TaskDoneFile, nil, 0644)
+	}
+}
+
+// fail writes the reason of the failure to the error marker, which the controller copies into the stage
+func (r *runner) fail(err error) {
+	fmt.Fprintln(r.out, err)
+	if r.ErrorMarkerFile != "" {
+		_ = ioutil.WriteFile(r.ErrorMarkerFile, []byte(err.Error()+"\n"), 0644)
+	}
+}
+
+// kube returns the clientset of the task's service account
+func (r *runner) kube() (kubernetes.Interface, error) {
+	if r.clientset != nil {
+		return r.clientset, nil
+	}
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	r.clientset, err = kubernetes.NewForConfig(restConfig)
+	return r.clientset, err
+}
+
+// setupSSH copies the ssh config and keys since ssh refuses the permissions of the mounted files
+func (r *runner) setupSSH() error {
+	if r.SSHPath == "" {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(r.SSHPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	dst := filepath.Join(r.home, ".ssh")
+	for _, entry := range entries {
+		// Skip the hidden directories of the mounted Secret
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(r.SSHPath, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dst, entry.Name()), b, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// install copies the running executable into the directory
+func install(dir string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return copyFile(self, filepath.Join(dir, "runner"), 0755)
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/isaaguilar/terraform-operator/pkg/taskscripts"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeTerraform logs its arguments and prints the outputs of `terraform output -json`. Init links a
+// provider like terraform does with a plugin cache and apply requires the plan.
+const fakeTerraform = `#!/bin/sh
+echo "$*" >> "$TFO_TEST_LOG"
+case "$1" in
+  init)
+    mkdir -p "$TF_PLUGIN_CACHE_DIR/registry.terraform.io/hashicorp/null/3.1.0/linux_amd64"
+    mkdir -p .terraform/providers/registry.terraform.io/hashicorp/null
+    ln -s "$TF_PLUGIN_CACHE_DIR/registry.terraform.io/hashicorp/null/3.1.0" .terraform/providers/registry.terraform.io/hashicorp/null/3.1.0
+    ;;
+  plan)
+    for arg; do
+      case "$arg" in -out=*) touch "${arg#-out=}" ;; esac
+    done
+    ;;
+  output)
+    echo '{"vpc_id": {"value": "vpc-123"}, "subnets": {"value": ["a", "b"]}, "secret": {"value": "x", "sensitive": true}}'
+    ;;
+  apply)
+    [ -f "$3" ] || exit 1
+    ;;
+esac
+`
+
+// fakeTerragrunt logs its arguments, the engine and the config it is given
+const fakeTerragrunt = `#!/bin/sh
+if [ "$1" = "--version" ]; then
+  echo "terragrunt version v0.55.1"
+  exit
+fi
+echo "$* ($(basename $PWD) $TERRAGRUNT_TFPATH ${TERRAGRUNT_CONFIG:-})" >> "$TFO_TEST_LOG"
+`
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func commit(t *testing.T, repo *git.Repository, dir string, files map[string]string) {
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = w.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestRunner returns a runner of the task with the directories of a task pod in a temporary directory.
+// The task runs its default script.
+func newTestRunner(t *testing.T, task string) (*runner, *bytes.Buffer) {
+	defaultScript := taskscripts.Noop
+	switch task {
+	case "setup", "setup-delete":
+		defaultScript = taskscripts.Setup
+	case "init", "init-delete", "plan", "plan-delete", "apply", "apply-delete":
+		defaultScript = taskscripts.Terraform
+	}
+	root, err := ioutil.TempDir("", "tfo-runner-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+	env := map[string]string{
+		"TFO_TASK":                            task,
+		"TFO_NAMESPACE":                       "default",
+		"TFO_ROOT_PATH":                       root,
+		"TFO_GENERATION":                      "2",
+		"TFO_GENERATION_PATH":                 filepath.Join(root, "generations", "2"),
+		"TFO_MAIN_MODULE_ADDONS":              filepath.Join(root, "addons"),
+		"TFO_TASK_EXEC_CONFIGMAP_SOURCE_NAME": taskscripts.ConfigMapPrefix + taskscripts.Version(),
+		"TFO_TASK_EXEC_CONFIGMAP_SOURCE_KEY":  defaultScript,
+		"TFO_TASK_EXEC_CONFIGMAP_SOURCE_PATH": filepath.Join(root, "config-map-source"),
+		"TFO_ERROR_MARKER_FILE":               filepath.Join(root, "error"),
+	}
+	c, err := loadConfig(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	return &runner{
+		config:    c,
+		home:      filepath.Join(root, "home"),
+		out:       out,
+		log:       logr.Discard(),
+		terraform: "terraform",
+	}, out
+}
+
+func TestSetup(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to clone local repos")
+	}
+	repoDir, err := ioutil.TempDir("", "tfo-repo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, repo, repoDir, map[string]string{"vpc/main.tf": "# v1\n", "vars/prod.tfvars": "a = 1\n"})
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag("v1", head.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, repo, repoDir, map[string]string{"vpc/main.tf": "# v2\n"})
+
+	archive := "b = 2\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, archive)
+	}))
+	defer server.Close()
+
+	r, out := newTestRunner(t, "setup")
+	r.MainModuleRepo = repoDir
+	r.MainModuleRepoRef = "v1"
+	r.MainModuleRepoSubdir = "vpc"
+	r.CleanupDisk = true
+	writeFile(t, filepath.Join(r.RootPath, "generations", "1", "main", "main.tf"), "# old\n")
+
+	// A mounted ConfigMap, its keys link into a hidden directory
+	configMap := filepath.Join(r.RootPath, "tfo-resources", "configmap", "tfvars")
+	writeFile(t, filepath.Join(configMap, "..data", "dev.tfvars"), "c = 3\n")
+	if err := os.Symlink(filepath.Join("..data", "dev.tfvars"), filepath.Join(configMap, "dev.tfvars")); err != nil {
+		t.Fatal(err)
+	}
+
+	downloads, err := json.Marshal([]resourceDownload{
+		{Address: "git::" + repoDir + "//vars", Path: "vars", UseAsVar: true},
+		{Address: "file://" + configMap, UseAsVar: true},
+		{Address: server.URL + "/extra.tfvars", Path: "extra", Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(archive)))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(r.Addons, resourceDownloadsFile), string(downloads))
+	writeFile(t, filepath.Join(r.Addons, "backend_override.tf"), "# backend\n")
+	writeFile(t, filepath.Join(r.Addons, "inline-plan.sh"), "echo plan\n")
+
+	if err := r.run(context.TODO()); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	for path, want := range map[string]string{
+		"main.tf":             "# v1\n",
+		"vars/prod.tfvars":    "a = 1\n",
+		"dev.tfvars":          "c = 3\n",
+		"extra/extra.tfvars":  "b = 2\n",
+		"backend_override.tf": "# backend\n",
+	} {
+		if got := readFile(t, filepath.Join(r.MainModule, path)); got != want {
+			t.Errorf("expected %s to be %q, got %q", path, want, got)
+		}
+	}
+	for _, path := range []string{"inline-plan.sh", resourceDownloadsFile, ".git", "..data"} {
+		if _, err := os.Stat(filepath.Join(r.MainModule, path)); err == nil {
+			t.Errorf("expected %s to not be in the main module", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(r.RootPath, "generations", "1")); err == nil {
+		t.Errorf("expected the old generation to be cleaned up")
+	}
+	wantVarFiles := filepath.Join(r.MainModule, "vars", "prod.tfvars") + "\n" + filepath.Join(r.MainModule, "dev.tfvars") + "\n"
+	if got := readFile(t, filepath.Join(r.GenerationPath, "var-files")); got != wantVarFiles {
+		t.Errorf("expected the var files %q, got %q", wantVarFiles, got)
+	}
+
+	// A bad checksum fails the download
+	archive = "b = 3\n"
+	if err := r.run(context.TODO()); err == nil || !strings.Contains(strings.ToLower(err.Error()), "checksums did not match") {
+		t.Errorf("expected the changed download to fail the checksum, got %v", err)
+	}
+
+	// The ConfigMap module is read with the task's service account
+	r, _ = newTestRunner(t, "setup")
+	r.clientset = fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "module"},
+		Data:       map[string]string{"main.tf": "# configmap\n", "README.md": "readme"},
+	})
+	writeFile(t, filepath.Join(r.Addons, configMapModuleFile), `{"name": "module", "key": "main.tf"}`)
+	if err := r.run(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(r.MainModule, "main.tf")); got != "# configmap\n" {
+		t.Errorf("unexpected ConfigMap module %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(r.MainModule, "README.md")); err == nil {
+		t.Errorf("expected only the selected key of the ConfigMap")
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	r, _ := newTestRunner(t, "setup")
+	configMap := filepath.Join(r.RootPath, "tfo-resources", "configmap", "tfvars")
+	writeFile(t, filepath.Join(configMap, "dev.tfvars"), "c = 3\n")
+	checksum := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("c = 3\n")))
+
+	dst := t.TempDir()
+	if err := r.download(context.TODO(), "file://"+filepath.Join(configMap, "dev.tfvars"), dst, checksum); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dst, "dev.tfvars")); got != "c = 3\n" {
+		t.Errorf("unexpected key %q", got)
+	}
+
+	// The checksum of a key is verified like the other files
+	badChecksum := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("c = 4\n")))
+	err := r.download(context.TODO(), "file://"+filepath.Join(configMap, "dev.tfvars"), t.TempDir(), badChecksum)
+	if err == nil || !strings.Contains(err.Error(), "checksums did not match") {
+		t.Errorf("expected the key to fail the checksum, got %v", err)
+	}
+
+	// Directories and git repos can not be verified
+	for _, address := range []string{"file://" + configMap, "git::https://github.com/org/repo.git?ref=main"} {
+		err := r.download(context.TODO(), address, t.TempDir(), checksum)
+		if err == nil || !strings.Contains(err.Error(), "can not be verified") {
+			t.Errorf("%s: expected the checksum to be rejected, got %v", address, err)
+		}
+	}
+}
+
+func TestGitDownloadThroughTunnel(t *testing.T) {
+	// The tunnel records the SOCKS5 greeting of the clients and closes the connection, which fails the
+	// clone after the client chose the tunnel
+	tunnel, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+	greetings := make(chan byte, 10)
+	go func() {
+		for {
+			conn, err := tunnel.Accept()
+			if err != nil {
+				return
+			}
+			version := make([]byte, 1)
+			if _, err := conn.Read(version); err == nil {
+				greetings <- version[0]
+			}
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		hosts       []string
+		tunnelled   bool
+		repoAddress string
+	}{
+		{hosts: []string{"git.internal.example.com"}, tunnelled: true, repoAddress: "git::https://git.internal.example.com/org/vpc.git"},
+		{hosts: []string{"git.internal.example.com"}, repoAddress: "git::https://127.0.0.1:1/org/vpc.git"},
+		{repoAddress: "git::https://git.internal.example.com/org/vpc.git"},
+	}
+	for _, tt := range tests {
+		r, _ := newTestRunner(t, "setup")
+		r.TunnelSocksAddress = tunnel.Addr().String()
+		r.TunnelHosts = tt.hosts
+		if err := r.download(context.TODO(), tt.repoAddress, t.TempDir(), ""); err == nil {
+			t.Fatalf("%s: expected the clone to fail", tt.repoAddress)
+		}
+		select {
+		case version := <-greetings:
+			if !tt.tunnelled || version != 5 {
+				t.Errorf("%s %v: expected no SOCKS5 connection, got version %d", tt.repoAddress, tt.hosts, version)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if tt.tunnelled {
+				t.Errorf("%s %v: expected the clone to go through the tunnel", tt.repoAddress, tt.hosts)
+			}
+		}
+	}
+}
+
+func TestTerraform(t *testing.T) {
+	r, out := newTestRunner(t, "init")
+	bin := filepath.Join(r.RootPath, "bin", "terraform")
+	writeFile(t, bin, fakeTerraform)
+	if err := os.Chmod(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	terraformLog := filepath.Join(r.RootPath, "terraform.log")
+	os.Setenv("TFO_TEST_LOG", terraformLog)
+	defer os.Unsetenv("TFO_TEST_LOG")
+
+	cache := filepath.Join(r.RootPath, "plugin-cache")
+	os.Setenv("TF_PLUGIN_CACHE_DIR", cache)
+	defer os.Unsetenv("TF_PLUGIN_CACHE_DIR")
+
+	if err := r.runTerraform(context.TODO()); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expected the missing main module to fail the task, got %v", err)
+	}
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(r.GenerationPath, "var-files"), filepath.Join(r.MainModule, "prod.tfvars")+"\n")
+	secretVarFile := filepath.Join(r.RootPath, "secret-variables", "variables.tfvars")
+	writeFile(t, secretVarFile, "db_password = \"hunter22\"\n")
+	r.SecretVarFile = secretVarFile
+
+	// Versions unused for the retention period are removed from the cache
+	stale := filepath.Join(cache, "registry.terraform.io", "hashicorp", "aws", "4.0.0")
+	unmarked := filepath.Join(cache, "registry.terraform.io", "hashicorp", "aws", "4.1.0")
+	writeFile(t, filepath.Join(stale, pluginCacheMarker), "")
+	writeFile(t, filepath.Join(unmarked, "linux_amd64", "provider"), "")
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(stale, pluginCacheMarker), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	r.terraform = bin
+	r.PluginCacheDir = cache
+	r.PluginCacheLockFile = filepath.Join(cache, ".tfo-lock")
+	r.PluginCacheRetention = 24 * time.Hour
+	r.SaveOutputs = true
+	r.OutputsSecretName = "app-outputs"
+	r.OutputsToOmit = []string{"secret"}
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-outputs"},
+	})
+	r.clientset = clientset
+	for _, task := range []string{"init", "plan", "apply", "plan-delete", "apply-delete"} {
+		r.Task = task
+		if err := r.runTerraform(context.TODO()); err != nil {
+			t.Fatalf("%s: %v\n%s", task, err, out)
+		}
+	}
+
+	plan := filepath.Join(r.GenerationPath, "tfplan")
+	// The secret values are loaded last
+	varFile := "-var-file=" + filepath.Join(r.MainModule, "prod.tfvars") + " -var-file=" + secretVarFile
+	want := strings.Join([]string{
+		"init -input=false",
+		"plan -input=false " + varFile + " -out=" + plan,
+		"apply -input=false " + plan,
+		"output -json",
+		"plan -input=false -destroy " + varFile + " -out=" + plan,
+		"apply -input=false " + plan,
+	}, "\n") + "\n"
+	if got := readFile(t, terraformLog); got != want {
+		t.Errorf("expected the terraform commands\n%s\ngot\n%s", want, got)
+	}
+
+	if _, err := os.Stat(stale); err == nil {
+		t.Errorf("expected the stale provider version to be removed from the cache")
+	}
+	for _, version := range []string{unmarked, filepath.Join(cache, "registry.terraform.io", "hashicorp", "null", "3.1.0")} {
+		if _, err := os.Stat(filepath.Join(version, pluginCacheMarker)); err != nil {
+			t.Errorf("expected %s to be marked: %v", version, err)
+		}
+	}
+
+	secret, err := clientset.CoreV1().Secrets("default").Get(context.TODO(), "app-outputs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret.Data) != 2 || string(secret.Data["vpc_id"]) != "vpc-123" || string(secret.Data["subnets"]) != `["a","b"]` {
+		t.Errorf("unexpected outputs %v", secret.Data)
+	}
+
+	r.Task = "refresh"
+	if err := r.runTerraform(context.TODO()); err == nil {
+		t.Errorf("expected an unknown task to fail")
+	}
+}
+
+func TestCollectPluginCacheKeepSince(t *testing.T) {
+	r, _ := newTestRunner(t, "init")
+	now := time.Now()
+	r.PluginCacheDir = t.TempDir()
+	r.PluginCacheRetention = time.Hour
+	r.PluginCacheKeepSince = now.Add(-3 * time.Hour)
+	versions := map[string]time.Duration{"stale": 4 * time.Hour, "running": 2 * time.Hour}
+	for name, age := range versions {
+		marker := filepath.Join(r.PluginCacheDir, "registry.terraform.io", "hashicorp", name, "1.0.0", pluginCacheMarker)
+		writeFile(t, marker, "")
+		if err := os.Chtimes(marker, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.collectPluginCache(now); err != nil {
+		t.Fatal(err)
+	}
+	// The version used since the oldest running workflow started is kept past the retention
+	for name, kept := range map[string]bool{"stale": false, "running": true} {
+		_, err := os.Stat(filepath.Join(r.PluginCacheDir, "registry.terraform.io", "hashicorp", name, "1.0.0"))
+		if (err == nil) != kept {
+			t.Errorf("expected %s to be kept %t, got %v", name, kept, err)
+		}
+	}
+}
+
+func TestTerragrunt(t *testing.T) {
+	r, out := newTestRunner(t, "plan")
+	bin := filepath.Join(r.RootPath, "bin", "terragrunt")
+	writeFile(t, bin, fakeTerragrunt)
+	if err := os.Chmod(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	terragruntLog := filepath.Join(r.RootPath, "terragrunt.log")
+	os.Setenv("TFO_TEST_LOG", terragruntLog)
+	defer os.Unsetenv("TFO_TEST_LOG")
+
+	r.terragrunt = bin
+	r.terraform = "tofu"
+	r.Terragrunt = true
+	r.TerragruntDir = "live"
+	r.TerragruntVersion = "0.55.1"
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.runTerraform(context.TODO()); err == nil || !strings.Contains(err.Error(), "terragrunt directory 'live' does not exist") {
+		t.Errorf("expected the missing terragrunt directory to fail the task, got %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(r.MainModule, "live"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r.TerragruntVersion = "v0.50.0"
+	if err := r.runTerraform(context.TODO()); err == nil || !strings.Contains(err.Error(), "instead of v0.50.0") {
+		t.Errorf("expected a different terragrunt version to fail the task, got %v", err)
+	}
+	r.TerragruntVersion = "v0.55.1"
+
+	// A single module is planned with its config and applied with the generation's plan
+	r.TerragruntConfig = "prod.hcl"
+	plan := filepath.Join(r.GenerationPath, "tfplan")
+	config := filepath.Join(r.MainModule, "live", "prod.hcl")
+	for _, task := range []string{"init", "plan", "apply-delete"} {
+		r.Task = task
+		if err := r.runTerraform(context.TODO()); err != nil {
+			t.Fatalf("%s: %v\n%s", task, err, out)
+		}
+	}
+
+	// A stack is run with run-all, keeps the plans in the modules and is destroyed with run-all destroy
+	r.TerragruntConfig = ""
+	r.TerragruntRunAll = true
+	r.SaveOutputs = true
+	for _, task := range []string{"plan", "apply", "apply-delete"} {
+		r.Task = task
+		if err := r.runTerraform(context.TODO()); err != nil {
+			t.Fatalf("%s: %v\n%s", task, err, out)
+		}
+	}
+	if !strings.Contains(out.String(), "outputs of a run-all stack are not saved") {
+		t.Errorf("expected the outputs of the stack to be skipped, got %q", out)
+	}
+
+	want := strings.Join([]string{
+		"init -input=false (live tofu " + config + ")",
+		"plan -input=false -out=" + plan + " (live tofu " + config + ")",
+		"apply -input=false " + plan + " (live tofu " + config + ")",
+		"run-all plan -input=false -out=tfplan (live tofu )",
+		"run-all apply -input=false tfplan (live tofu )",
+		"run-all destroy -input=false -auto-approve (live tofu )",
+	}, "\n") + "\n"
+	if got := readFile(t, terragruntLog); got != want {
+		t.Errorf("expected the terragrunt commands\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	r, out := newTestRunner(t, "init")
+	bin := filepath.Join(r.RootPath, "bin", "terraform")
+	writeFile(t, bin, "#!/bin/sh\necho \"$* (${TF_WORKSPACE:-})\" >> \"$TFO_TEST_LOG\"\n[ \"$2\" != delete ]\n")
+	if err := os.Chmod(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	terraformLog := filepath.Join(r.RootPath, "terraform.log")
+	os.Setenv("TFO_TEST_LOG", terraformLog)
+	defer os.Unsetenv("TFO_TEST_LOG")
+	os.Setenv("TF_WORKSPACE", "prod")
+	defer os.Unsetenv("TF_WORKSPACE")
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The workspace commands run without TF_WORKSPACE and the other commands in the workspace
+	r.terraform = bin
+	r.Workspace = "prod"
+	plan := filepath.Join(r.GenerationPath, "tfplan")
+	for _, task := range []string{"init", "plan-delete", "apply-delete"} {
+		r.Task = task
+		if err := r.runTerraform(context.TODO()); err != nil {
+			t.Fatalf("%s: %v\n%s", task, err, out)
+		}
+	}
+	want := strings.Join([]string{
+		"init -input=false ()",
+		"workspace select -or-create prod ()",
+		"plan -input=false -destroy -out=" + plan + " (prod)",
+		"apply -input=false " + plan + " (prod)",
+		"workspace select default ()",
+		"workspace delete prod ()",
+	}, "\n") + "\n"
+	if got := readFile(t, terraformLog); got != want {
+		t.Errorf("expected the terraform commands\n%s\ngot\n%s", want, got)
+	}
+	if !strings.Contains(out.String(), "Could not delete the workspace 'prod'") {
+		t.Errorf("expected the failed workspace deletion to be logged, got %q", out)
+	}
+}
+
+func TestRunMode(t *testing.T) {
+	r, out := newTestRunner(t, "plan")
+	bin := filepath.Join(r.RootPath, "bin", "terraform")
+	writeFile(t, bin, "#!/bin/sh\necho \"$*\" >> \"$TFO_TEST_LOG\"\n")
+	if err := os.Chmod(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	terraformLog := filepath.Join(r.RootPath, "terraform.log")
+	os.Setenv("TFO_TEST_LOG", terraformLog)
+	defer os.Unsetenv("TFO_TEST_LOG")
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the plan of the refresh-only mode is a refresh
+	r.terraform = bin
+	plan := filepath.Join(r.GenerationPath, "tfplan")
+	for _, mode := range []string{"", "plan-only", "refresh-only"} {
+		r.Mode = mode
+		for _, task := range []string{"plan", "plan-delete"} {
+			r.Task = task
+			if err := r.runTerraform(context.TODO()); err != nil {
+				t.Fatalf("%s %s: %v\n%s", mode, task, err, out)
+			}
+		}
+	}
+	want := strings.Join([]string{
+		"plan -input=false -out=" + plan,
+		"plan -input=false -destroy -out=" + plan,
+		"plan -input=false -out=" + plan,
+		"plan -input=false -destroy -out=" + plan,
+		"plan -input=false -refresh-only -out=" + plan,
+		"plan -input=false -destroy -out=" + plan,
+	}, "\n") + "\n"
+	if got := readFile(t, terraformLog); got != want {
+		t.Errorf("expected the terraform commands\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "#!/bin/sh\necho from url\n")
+	}))
+	defer server.Close()
+
+	r, out := newTestRunner(t, "postapply")
+	if err := r.run(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No script is configured for the postapply task") {
+		t.Errorf("expected the default script of the task to run, got %q", out)
+	}
+
+	r.ConfigMapSourceName = "scripts"
+	r.ConfigMapSourceKey = "post-apply.sh"
+	writeFile(t, filepath.Join(r.ConfigMapSourcePath, "post-apply.sh"), "echo from configmap in $(basename $PWD)\n")
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		t.Fatal(err)
+	}
+	r.URLSource = server.URL + "/post-apply.sh"
+	writeFile(t, filepath.Join(r.Addons, "inline-postapply.sh"), "echo inline\nexit 3\n")
+	for _, want := range []string{"inline", "from url", "from configmap in main"} {
+		out.Reset()
+		err := r.run(context.TODO())
+		if want == "inline" {
+			if err == nil {
+				t.Errorf("expected the failed script to fail the task")
+			}
+			r.fail(err)
+			if marker := readFile(t, r.ErrorMarkerFile); !strings.Contains(marker, "the postapply script failed") {
+				t.Errorf("unexpected error marker %q", marker)
+			}
+			os.Remove(filepath.Join(r.Addons, "inline-postapply.sh"))
+		} else if err != nil {
+			t.Fatal(err)
+		} else {
+			r.URLSource = ""
+		}
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output, got %q", want, out)
+		}
+	}
+}
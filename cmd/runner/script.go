@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/isaaguilar/terraform-operator/pkg/taskscripts"
+)
+
+// maxScriptBytes caps the size of a script fetched from a url
+const maxScriptBytes = 1 << 20
+
+// builtins are the Go implementations of the default task scripts
+var builtins = map[string]func(*runner, context.Context) error{
+	taskscripts.Setup:     (*runner).setup,
+	taskscripts.Terraform: (*runner).runTerraform,
+	taskscripts.Noop:      (*runner).noop,
+}
+
+// findScript returns the script of the task or the builtin that implements the task's default script. The
+// inline script, which includes the verified pinned sources, is used first, then the url and then the
+// ConfigMap of the task option.
+func (r *runner) findScript(ctx context.Context) ([]byte, func(*runner, context.Context) error, error) {
+	if r.Addons != "" {
+		inline, err := ioutil.ReadFile(filepath.Join(r.Addons, fmt.Sprintf("inline-%s.sh", r.Task)))
+		if err == nil {
+			return inline, nil, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	if r.URLSource != "" {
+		script, err := fetchScript(ctx, r.URLSource)
+		return script, nil, err
+	}
+	if r.ConfigMapSourceName != "" && r.ConfigMapSourceKey != "" {
+		if strings.HasPrefix(r.ConfigMapSourceName, taskscripts.ConfigMapPrefix) {
+			if builtin, ok := builtins[r.ConfigMapSourceKey]; ok {
+				return nil, builtin, nil
+			}
+		}
+		script, err := ioutil.ReadFile(filepath.Join(r.ConfigMapSourcePath, r.ConfigMapSourceKey))
+		return script, nil, err
+	}
+	return nil, (*runner).noop, nil
+}
+
+func fetchScript(ctx context.Context, source string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch '%s': %s", source, resp.Status)
+	}
+	script, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScriptBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch '%s': %v", source, err)
+	}
+	if len(script) > maxScriptBytes {
+		return nil, fmt.Errorf("'%s' is larger than %d bytes", source, maxScriptBytes)
+	}
+	return script, nil
+}
+
+// runScript runs the script in the main module directory. Scripts without a shebang are run by bash.
+func (r *runner) runScript(ctx context.Context, script []byte) error {
+	f, err := ioutil.TempFile("", "tfo-script-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, f.Name())
+	if !bytes.HasPrefix(script, []byte("#!")) {
+		cmd = exec.CommandContext(ctx, "/bin/bash", f.Name())
+	}
+	cmd.Dir = r.GenerationPath
+	if _, err := os.Stat(r.MainModule); err == nil {
+		cmd.Dir = r.MainModule
+	}
+	cmd.Stdout = r.out
+	cmd.Stderr = r.out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("the %s script failed: %v", r.Task, err)
+	}
+	return nil
+}
+
+func (r *runner) noop(ctx context.Context) error {
+	fmt.Fprintf(r.out, "No script is configured for the %s task\n", r.Task)
+	return nil
+}
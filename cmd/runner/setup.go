@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+	"github.com/isaaguilar/terraform-operator/pkg/download"
+	"github.com/isaaguilar/terraform-operator/pkg/gitclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	resourceDownloadsFile = ".__TFO__ResourceDownloads.json"
+	configMapModuleFile   = ".__TFO__ConfigMapModule.json"
+)
+
+// resourceDownload is an item of the resource downloads rendered by the controller
+type resourceDownload struct {
+	Address  string `json:"address"`
+	Checksum string `json:"checksum"`
+	Path     string `json:"path"`
+	UseAsVar bool   `json:"useAsVar"`
+}
+
+// configMapModule selects the ConfigMap, or one of its keys, used as the main module
+type configMapModule struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+}
+
+// setup puts the main module, the resource downloads and the main module addons into the generation's
+// directory
+func (r *runner) setup(ctx context.Context) error {
+	if r.CleanupDisk && r.RootPath != "" {
+		if err := r.cleanupDisk(); err != nil {
+			return err
+		}
+	}
+
+	varFiles := filepath.Join(r.GenerationPath, "var-files")
+	for _, path := range []string{r.MainModule, varFiles} {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(r.MainModule, 0755); err != nil {
+		return err
+	}
+
+	switch {
+	case r.MainModuleRepo != "":
+		fmt.Fprintf(r.out, "Downloading the main module from %s\n", r.MainModuleRepo)
+		if err := r.gitDownload(r.MainModuleRepo, r.MainModuleRepoRef, r.MainModuleRepoSubdir, r.MainModule); err != nil {
+			return err
+		}
+	case r.MainModuleAddress != "":
+		fmt.Fprintf(r.out, "Downloading the main module from %s\n", r.MainModuleAddress)
+		if err := r.download(ctx, r.MainModuleAddress, r.MainModule, r.MainModuleChecksum); err != nil {
+			return err
+		}
+	default:
+		if err := r.getConfigMapModule(ctx); err != nil {
+			return err
+		}
+	}
+
+	files, err := r.getResourceDownloads(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(varFiles, []byte(strings.Join(files, "")), 0644); err != nil {
+		return err
+	}
+
+	// The addons are the files rendered by the controller, eg the variables, except the task scripts
+	return copyFiles(r.Addons, r.MainModule, func(name string) bool {
+		return !strings.HasPrefix(name, ".__TFO__") && !(strings.HasPrefix(name, "inline-") && strings.HasSuffix(name, ".sh"))
+	})
+}
+
+// cleanupDisk removes the directories of the other generations
+func (r *runner) cleanupDisk() error {
+	generations := filepath.Join(r.RootPath, "generations")
+	entries, err := ioutil.ReadDir(generations)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == r.Generation {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(generations, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getResourceDownloads puts the resource downloads into the main module and returns the var-files lines of
+// the downloads used as variables
+func (r *runner) getResourceDownloads(ctx context.Context) ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(r.Addons, resourceDownloadsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	items := []resourceDownload{}
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("could not read the resource downloads: %v", err)
+	}
+
+	varFiles := []string{}
+	for _, item := range items {
+		dst, err := securePath(r.MainModule, item.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return nil, err
+		}
+		before, err := listFiles(dst)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(r.out, "Downloading %s\n", item.Address)
+		if err := r.download(ctx, item.Address, dst, item.Checksum); err != nil {
+			return nil, err
+		}
+		if !item.UseAsVar {
+			continue
+		}
+		after, err := listFiles(dst)
+		if err != nil {
+			return nil, err
+		}
+		added := []string{}
+		for file := range after {
+			if !before[file] {
+				added = append(added, file+"\n")
+			}
+		}
+		sort.Strings(added)
+		varFiles = append(varFiles, added...)
+	}
+	return varFiles, nil
+}
+
+// download puts the address into the directory. Git repos are cloned with the scm auth of the task, the
+// ConfigMaps and Secrets are read from their mounts and the other addresses are downloaded by go-getter.
+func (r *runner) download(ctx context.Context, address, dst, checksum string) error {
+	switch {
+	case strings.HasPrefix(address, "git::"):
+		repo, subdir := getter.SourceDirSubdir(strings.TrimPrefix(address, "git::"))
+		u, err := url.Parse(repo)
+		if err != nil {
+			return err
+		}
+		ref := u.Query().Get("ref")
+		u.RawQuery = ""
+		if subdir == "" {
+			subdir = "."
+		}
+		if checksum != "" {
+			return fmt.Errorf("the checksum of the git repo '%s' can not be verified", u.String())
+		}
+		return r.gitDownload(u.String(), ref, subdir, dst)
+	case strings.HasPrefix(address, "file://"):
+		// The mounted ConfigMaps and Secrets, their keys are links into hidden directories
+		src := strings.TrimPrefix(address, "file://")
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if checksum != "" {
+				return fmt.Errorf("the checksum of the directory '%s' can not be verified", src)
+			}
+			return copyFiles(src, dst, func(name string) bool { return !strings.HasPrefix(name, "..") })
+		}
+		if err := download.VerifyFile(src, checksum); err != nil {
+			return err
+		}
+		return copyFile(src, filepath.Join(dst, filepath.Base(src)), 0644)
+	default:
+		return download.Fetch(ctx, address, dst, checksum, r.proxy())
+	}
+}
+
+// gitDownload clones the repo and copies the subdir into dst
+func (r *runner) gitDownload(repo, ref, subdir, dst string) error {
+	tmp, err := ioutil.TempDir("", "tfo-repo-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	src, err := securePath(tmp, subdir)
+	if err != nil {
+		return err
+	}
+	if host, ok := sshHost(repo); ok {
+		key := filepath.Join(r.home, ".ssh", host)
+		gitRepo, err := gitclient.NewGitRepo("", "", key, r.log)
+		if err != nil {
+			return fmt.Errorf("could not read the ssh key of '%s': %v", host, err)
+		}
+		err = gitRepo.GitSSHDownload(repo, tmp, key, ref, 0)
+		if err != nil {
+			return err
+		}
+	} else {
+		password, err := r.gitPassword()
+		if err != nil {
+			return err
+		}
+		gitRepo, err := gitclient.NewGitRepo("git", password, "", r.log)
+		if err != nil {
+			return err
+		}
+		// go-git does not read the git config, which routes the hosts that require the ssh tunnel
+		// through it for the git cli
+		gitRepo.SetProxy(r.proxy().ProxyFunc())
+		if r.GitSSLCAInfo != "" {
+			pem, err := ioutil.ReadFile(r.GitSSLCAInfo)
+			if err != nil {
+				return err
+			}
+			if err := gitRepo.SetTrustedCA(pem); err != nil {
+				return err
+			}
+		}
+		err = gitRepo.GitHTTPDownload(repo, tmp, "", "", ref, 0)
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return err
+	}
+	return copyDir(src, dst)
+}
+
+// proxy routes the hosts that require the ssh tunnel through its SOCKS5 proxy
+func (r *runner) proxy() *download.Proxy {
+	return &download.Proxy{Address: r.TunnelSocksAddress, Hosts: r.TunnelHosts}
+}
+
+// gitPassword runs the GIT_ASKPASS program, which the controller renders with the token of the scm
+func (r *runner) gitPassword() (string, error) {
+	if r.GitAskpass == "" {
+		return "", nil
+	}
+	if _, err := os.Stat(r.GitAskpass); os.IsNotExist(err) {
+		return "", nil
+	}
+	out, err := exec.Command(r.GitAskpass, "Password").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not run GIT_ASKPASS: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getConfigMapModule writes the keys of the ConfigMap used as the main module
+func (r *runner) getConfigMapModule(ctx context.Context) error {
+	b, err := ioutil.ReadFile(filepath.Join(r.Addons, configMapModuleFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	module := configMapModule{}
+	if err := json.Unmarshal(b, &module); err != nil {
+		return fmt.Errorf("could not read the ConfigMap module: %v", err)
+	}
+	clientset, err := r.kube()
+	if err != nil {
+		return err
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps(r.Namespace).Get(ctx, module.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get the ConfigMap '%s': %v", module.Name, err)
+	}
+	for key, value := range configMap.Data {
+		if module.Key != "" && key != module.Key {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(r.MainModule, key), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sshHost returns the host of ssh urls, eg `ssh://git@github.com/org/repo.git`
+func sshHost(repo string) (string, bool) {
+	u, err := url.Parse(repo)
+	if err != nil || u.Scheme != "ssh" {
+		return "", false
+	}
+	return u.Hostname(), true
+}
+
+// securePath joins the path to the dir and fails when the result is outside of the dir
+func securePath(dir, path string) (string, error) {
+	joined := filepath.Join(dir, path)
+	if joined != filepath.Clean(dir) && !strings.HasPrefix(joined, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("'%s' is outside of '%s'", path, dir)
+	}
+	return joined, nil
+}
+
+// listFiles returns the regular files directly in the dir
+func listFiles(dir string) (map[string]bool, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			files[filepath.Join(dir, entry.Name())] = true
+		}
+	}
+	return files, nil
+}
+
+// copyFiles copies the files directly in src, which pass the filter, into dst. Symlinks are followed.
+func copyFiles(src, dst string, filter func(name string) bool) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !filter(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(src, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := copyFile(path, filepath.Join(dst, entry.Name()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyDir copies the content of src into dst
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
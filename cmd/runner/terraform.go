@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pluginCacheMarker is touched in the provider versions of the plugin cache when an init uses them
+const pluginCacheMarker = ".tfo-last-used"
+
+// runTerraform runs terraform init, plan or apply in the generation's main module and saves the outputs
+// after an apply. Terragrunt resources run terragrunt in its directory, with run-all for stacks.
+func (r *runner) runTerraform(ctx context.Context) error {
+	if _, err := os.Stat(r.MainModule); err != nil {
+		return fmt.Errorf("the main module '%s' does not exist", r.MainModule)
+	}
+	plan := filepath.Join(r.GenerationPath, "tfplan")
+	if r.Terragrunt {
+		if err := r.checkTerragrunt(ctx); err != nil {
+			return err
+		}
+		if r.TerragruntRunAll {
+			// Each module keeps its plan in its own working directory
+			plan = "tfplan"
+		}
+	}
+
+	switch r.Task {
+	case "init", "init-delete":
+		return r.init(ctx)
+	case "plan":
+		args := []string{"plan", "-input=false"}
+		if r.Mode == "refresh-only" {
+			// The refresh-only mode plans to update the state to match the infrastructure
+			args = append(args, "-refresh-only")
+		}
+		return r.terraformCommand(ctx, append(append(args, r.varFileArgs()...), "-out="+plan)...)
+	case "plan-delete":
+		return r.terraformCommand(ctx, append(append([]string{"plan", "-input=false", "-destroy"}, r.varFileArgs()...), "-out="+plan)...)
+	case "apply-delete":
+		var err error
+		if r.TerragruntRunAll {
+			// run-all destroy removes the modules in the reverse order of their dependencies
+			err = r.terraformCommand(ctx, append([]string{"destroy", "-input=false", "-auto-approve"}, r.varFileArgs()...)...)
+		} else {
+			err = r.terraformCommand(ctx, "apply", "-input=false", plan)
+		}
+		if err != nil {
+			return err
+		}
+		r.deleteWorkspace(ctx)
+		return nil
+	case "apply":
+		if err := r.terraformCommand(ctx, "apply", "-input=false", plan); err != nil {
+			return err
+		}
+		if !r.SaveOutputs {
+			return nil
+		}
+		if r.TerragruntRunAll {
+			fmt.Fprintln(r.out, "The outputs of a run-all stack are not saved")
+			return nil
+		}
+		return r.saveOutputs(ctx)
+	}
+	return fmt.Errorf("unknown terraform task '%s'", r.Task)
+}
+
+// checkTerragrunt checks the terragrunt of the image and the directory it runs in
+func (r *runner) checkTerragrunt(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, r.terragrunt, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("could not run terragrunt: %v", err)
+	}
+	if r.TerragruntVersion != "" {
+		fields := strings.Fields(string(out))
+		version := ""
+		if len(fields) > 0 {
+			version = fields[len(fields)-1]
+		}
+		if strings.TrimPrefix(version, "v") != strings.TrimPrefix(r.TerragruntVersion, "v") {
+			return fmt.Errorf("terragrunt is at version %s instead of %s", version, r.TerragruntVersion)
+		}
+	}
+	if _, err := os.Stat(r.workingDir()); err != nil {
+		return fmt.Errorf("the terragrunt directory '%s' does not exist", r.TerragruntDir)
+	}
+	return nil
+}
+
+// workingDir is where the commands run, the main module or the terragrunt directory
+func (r *runner) workingDir() string {
+	if r.Terragrunt {
+		return filepath.Join(r.MainModule, r.TerragruntDir)
+	}
+	return r.MainModule
+}
+
+// binary is the name of the binary the commands run
+func (r *runner) binary() string {
+	if r.Terragrunt {
+		return filepath.Base(r.terragrunt)
+	}
+	return filepath.Base(r.terraform)
+}
+
+// command runs the engine, or terragrunt with the engine. The output of the modules of a run-all stack is
+// prefixed with their path to tell apart their plans.
+func (r *runner) command(ctx context.Context, args ...string) *exec.Cmd {
+	name := r.terraform
+	env := append(os.Environ(), "TF_IN_AUTOMATION=true")
+	if r.Terragrunt {
+		name = r.terragrunt
+		env = append(env, "TERRAGRUNT_TFPATH="+r.terraform, "TERRAGRUNT_NON_INTERACTIVE=true", "TERRAGRUNT_INCLUDE_MODULE_PREFIX=true")
+		if r.TerragruntConfig != "" {
+			env = append(env, "TERRAGRUNT_CONFIG="+filepath.Join(r.workingDir(), r.TerragruntConfig))
+		}
+		if r.TerragruntRunAll {
+			args = append([]string{"run-all"}, args...)
+		}
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = r.workingDir()
+	cmd.Env = env
+	cmd.Stdout = r.out
+	cmd.Stderr = r.out
+	return cmd
+}
+
+func (r *runner) terraformCommand(ctx context.Context, args ...string) error {
+	if err := r.command(ctx, args...).Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %v", r.binary(), args[0], err)
+	}
+	return nil
+}
+
+// workspaceCommand runs a command without TF_WORKSPACE since the workspace commands refuse to run while it
+// overrides the selected workspace
+func (r *runner) workspaceCommand(ctx context.Context, args ...string) error {
+	cmd := r.command(ctx, args...)
+	env := []string{}
+	for _, e := range cmd.Env {
+		if !strings.HasPrefix(e, "TF_WORKSPACE=") {
+			env = append(env, e)
+		}
+	}
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %v", r.binary(), args[0], err)
+	}
+	return nil
+}
+
+// initWorkspace runs init and creates the workspace when it does not exist
+func (r *runner) initWorkspace(ctx context.Context) error {
+	if r.Workspace == "" {
+		return r.terraformCommand(ctx, "init", "-input=false")
+	}
+	if err := r.workspaceCommand(ctx, "init", "-input=false"); err != nil {
+		return err
+	}
+	return r.workspaceCommand(ctx, "workspace", "select", "-or-create", r.Workspace)
+}
+
+// deleteWorkspace deletes the workspace once its resources are destroyed. The resource is deleted even when
+// the workspace can not be.
+func (r *runner) deleteWorkspace(ctx context.Context) {
+	if r.Workspace == "" || r.Workspace == "default" {
+		return
+	}
+	err := r.workspaceCommand(ctx, "workspace", "select", "default")
+	if err == nil {
+		err = r.workspaceCommand(ctx, "workspace", "delete", r.Workspace)
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "Could not delete the workspace '%s': %v\n", r.Workspace, err)
+	}
+}
+
+// varFileArgs returns the -var-file flags of the resource downloads used as variables. The secret values
+// are loaded last so they take precedence over the other variable files.
+func (r *runner) varFileArgs() []string {
+	args := []string{}
+	if f, err := os.Open(filepath.Join(r.GenerationPath, "var-files")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				args = append(args, "-var-file="+line)
+			}
+		}
+	}
+	if r.SecretVarFile != "" {
+		if _, err := os.Stat(r.SecretVarFile); err == nil {
+			args = append(args, "-var-file="+r.SecretVarFile)
+		}
+	}
+	return args
+}
+
+// init holds the plugin cache's lock since the engines do not support concurrent writes to the cache
+func (r *runner) init(ctx context.Context) error {
+	if r.PluginCacheLockFile == "" {
+		return r.initWorkspace(ctx)
+	}
+	lock, err := os.OpenFile(r.PluginCacheLockFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not lock the plugin cache: %v", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("could not lock the plugin cache: %v", err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	if err := r.initWorkspace(ctx); err != nil {
+		return err
+	}
+	// The providers linked by terragrunt are in its cache so they can not be marked
+	if r.PluginCacheRetention > 0 && r.PluginCacheDir != "" && !r.Terragrunt {
+		return r.collectPluginCache(time.Now())
+	}
+	return nil
+}
+
+// collectPluginCache marks the provider versions linked by this init and removes the versions of the cache
+// that have not been marked for the retention period. Versions marked since the oldest running workflow
+// started are kept, its plan and apply use them without the lock. The versions are at
+// <cache>/<host>/<namespace>/<type>/<version>.
+func (r *runner) collectPluginCache(now time.Time) error {
+	linked, err := filepath.Glob(filepath.Join(r.MainModule, ".terraform", "providers", "*", "*", "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range linked {
+		rel, err := filepath.Rel(filepath.Join(r.MainModule, ".terraform", "providers"), dir)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(filepath.Join(r.PluginCacheDir, rel)); err == nil && info.IsDir() {
+			if err := touch(filepath.Join(r.PluginCacheDir, rel, pluginCacheMarker), now); err != nil {
+				return err
+			}
+		}
+	}
+
+	versions, err := filepath.Glob(filepath.Join(r.PluginCacheDir, "*", "*", "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if info, err := os.Stat(version); err != nil || !info.IsDir() {
+			continue
+		}
+		marker := filepath.Join(version, pluginCacheMarker)
+		info, err := os.Stat(marker)
+		if os.IsNotExist(err) {
+			if err := touch(marker, now); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		keep := !r.PluginCacheKeepSince.IsZero() && !info.ModTime().Before(r.PluginCacheKeepSince)
+		if now.Sub(info.ModTime()) > r.PluginCacheRetention && !keep {
+			fmt.Fprintf(r.out, "Removing %s from the plugin cache\n", version)
+			if err := os.RemoveAll(version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func touch(path string, now time.Time) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(path, now, now)
+}
+
+// saveOutputs writes the outputs to the outputs secret. Strings are saved as is and other types as json.
+func (r *runner) saveOutputs(ctx context.Context) error {
+	stdout := bytes.Buffer{}
+	cmd := r.command(ctx, "output", "-json")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s output failed: %v", r.binary(), err)
+	}
+	outputs := map[string]struct {
+		Value json.RawMessage `json:"value"`
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &outputs); err != nil {
+		return fmt.Errorf("could not read the outputs: %v", err)
+	}
+
+	data := make(map[string]string)
+	for key, output := range outputs {
+		if !r.includesOutput(key) {
+			continue
+		}
+		value := []byte(output.Value)
+		var s string
+		if err := json.Unmarshal(output.Value, &s); err == nil {
+			value = []byte(s)
+		} else {
+			compacted := bytes.Buffer{}
+			if err := json.Compact(&compacted, output.Value); err == nil {
+				value = compacted.Bytes()
+			}
+		}
+		data[key] = base64.StdEncoding.EncodeToString(value)
+	}
+
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "add", "path": "/data", "value": data},
+	})
+	if err != nil {
+		return err
+	}
+	clientset, err := r.kube()
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().Secrets(r.Namespace).Patch(ctx, r.OutputsSecretName, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("could not save the outputs: %v", err)
+	}
+	return nil
+}
+
+func (r *runner) includesOutput(key string) bool {
+	for _, omit := range r.OutputsToOmit {
+		if key == omit {
+			return false
+		}
+	}
+	if len(r.OutputsToInclude) == 0 {
+		return true
+	}
+	for _, include := range r.OutputsToInclude {
+		if key == include {
+			return true
+		}
+	}
+	return false
+}
@@ -15,11 +15,13 @@ const (
 	TerraformTaskImageTagDefault  = ""
 	ScriptTaskImageRepoDefault    = "ghcr.io/galleybytes/terraform-operator-script"
 	ScriptTaskImageTagDefault     = "1.0.1"
+	OpenTofuTaskImageRepoDefault  = "ghcr.io/galleybytes/terraform-operator-tofutask"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +genclient
 // Terraform is the Schema for the terraforms API
+// +kubebuilder:printcolumn:name="Result",type="string",JSONPath=".status.result"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +k8s:openapi-gen=true
 // +kubebuilder:storageversion
@@ -68,6 +70,12 @@ type TerraformSpec struct {
 	// terraform run data. If not defined, a default of "2Gi" is used.
 	PersistentVolumeSize *resource.Quantity `json:"persistentVolumeSize,omitempty"` // NOT MUTABLE
 
+	// PluginCache shares the providers downloaded by `terraform init` between the generations of the
+	// resource and, depending on the scope, other resources. When not defined, every generation downloads
+	// its providers.
+	// +optional
+	PluginCache *PluginCache `json:"pluginCache,omitempty"`
+
 	// ServiceAccount use a specific kubernetes ServiceAccount for running the create + destroy pods.
 	// If not specified we create a new ServiceAccount per Terraform
 	ServiceAccount string `json:"serviceAccount,omitempty"`
@@ -84,6 +92,22 @@ type TerraformSpec struct {
 	// operator/runner runs in. An example is enterprise-Github servers running on a private network.
 	SSHTunnel *ProxyOpts `json:"sshTunnel,omitempty"`
 
+	// TrustedCA adds PEM encoded CA certificates, eg of an internal git server, registry mirror or cloud
+	// endpoint, to the certificates trusted by the tasks and by the controller's git and registry clients.
+	// The certificates are added to the controller-wide default (`--trusted-ca-file`) and the system roots.
+	// +optional
+	TrustedCA *TrustedCA `json:"trustedCA,omitempty"`
+
+	// CLIConfig renders the `.terraformrc` of the tasks, eg to install providers from mirrors in air-gapped
+	// clusters. It is merged over the controller-wide default (`--cli-config-file`).
+	// +optional
+	CLIConfig *CLIConfig `json:"cliConfig,omitempty"`
+
+	// Network configures how the tasks reach endpoints, eg providers' APIs that are only reachable through
+	// the ssh tunnel or a corporate proxy.
+	// +optional
+	Network *Network `json:"network,omitempty"`
+
 	// SCMAuthMethods define multiple SCMs that require tokens/keys
 	SCMAuthMethods []SCMAuthMethod `json:"scmAuthMethods,omitempty"`
 
@@ -98,8 +122,24 @@ type TerraformSpec struct {
 
 	// TerraformVersion is the version of terraform which is used to run the module. The terraform version is
 	// used as the tag of the terraform image  regardless if images.terraform.image is defined with a tag. In
-	// that case, the tag is stripped and replace with this value.
-	TerraformVersion string `json:"terraformVersion"`
+	// that case, the tag is stripped and replace with this value. The opentofu engine uses engine.version
+	// instead. Without a version, the tag of images.terraform.image is the version, and `latest` when the
+	// image has no tag.
+	//
+	// A version constraint, eg `~> 1.5`, is resolved to the newest available version when the generation
+	// starts. The available versions are configured in the controller. See status.resolvedEngineVersion.
+	// +optional
+	TerraformVersion string `json:"terraformVersion,omitempty"`
+
+	// Engine selects the tool, terraform or opentofu, that runs the module. Resources without an engine use
+	// terraform at terraformVersion.
+	// +optional
+	Engine *Engine `json:"engine,omitempty"`
+
+	// Terragrunt runs the terraform tasks with terragrunt instead of running the engine directly. The image
+	// of the terraform tasks must provide terragrunt.
+	// +optional
+	Terragrunt *Terragrunt `json:"terragrunt,omitempty"`
 
 	// Backend is mandatory terraform backend configuration. Must use a valid terraform backend block.
 	// For more information see https://www.terraform.io/language/settings/backends/configuration
@@ -132,9 +172,64 @@ type TerraformSpec struct {
 	// Usage of the kubernetes backend is only available as of terraform v0.13+.
 	Backend string `json:"backend"`
 
+	// Workspace is the terraform workspace the terraform tasks select with TF_WORKSPACE. The workspace is
+	// created by the init task when it does not exist, so the backend must support workspaces, and is deleted
+	// once the delete workflow has destroyed its resources. Requires terraform 1.4 or later.
+	// +optional
+	Workspace string `json:"workspace,omitempty"`
+
+	// Mode selects what the workflow does with the plan. The `apply` mode applies it. The `plan-only`
+	// mode stops after the plan and the post-plan task so the resource previews the changes. The
+	// `refresh-only` mode plans with `-refresh-only` and applies the plan, which only updates the state to
+	// match the infrastructure. The delete workflow of the plan-only and refresh-only modes plans the
+	// destroy without applying it, unless the resource has been applied in the apply mode before, which
+	// status.applied tells. status.result shows what the last completed workflow did.
+	// +kubebuilder:validation:Enum=apply;plan-only;refresh-only
+	// +kubebuilder:default=apply
+	// +optional
+	Mode RunMode `json:"mode,omitempty"`
+
 	// TaskOptions are a list of configuration options to be injected into task pods.
 	TaskOptions []TaskOption `json:"taskOptions,omitempty"`
 
+	// Priority is used to order the run queue when the controller's concurrent workflow limits have been
+	// reached. Workflows with a higher priority start first. Workflows with the same priority start in the
+	// order they were queued.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// ConcurrencyGroup is a key shared by resources that must not run at the same time, for example resources
+	// that use the same backend state or cloud account. Only one workflow in the group runs at a time, across
+	// all namespaces. The others wait in the `queued` phase.
+	// +optional
+	ConcurrencyGroup string `json:"concurrencyGroup,omitempty"`
+
+	// DependsOn is a list of other Terraform resources that must complete their current generation before
+	// this workflow starts. Outputs of the dependencies can be passed into this module as input variables.
+	// When this resource is deleted, the destroy workflow waits until no other resource depends on it so
+	// stacks are destroyed in the reverse order they were created.
+	// +optional
+	DependsOn []Dependency `json:"dependsOn,omitempty"`
+
+	// Variables are the input variables of the module. The values are read when a generation starts and are
+	// rendered into the generation's addons so every generation runs with the same inputs, even if the
+	// sources change afterwards.
+	// +optional
+	Variables *Variables `json:"variables,omitempty"`
+
+	// WatchInputs when true will start a new run of the workflow when the content of a ConfigMap or Secret
+	// referenced by the resource changes. This includes the module's ConfigMap, `envFrom` and `env`
+	// references of task options, credentials, scm auth secrets and variables. The workflow is restarted
+	// from the setup task once the current run is finished.
+	// +optional
+	WatchInputs bool `json:"watchInputs,omitempty"`
+
+	// ExportRepo commits the configuration of each generation to a git repo after the plan task succeeds.
+	// The rendered variables, the backend and a summary of the plan are exported so the module can be run
+	// outside of the operator.
+	// +optional
+	ExportRepo *ExportRepo `json:"exportRepo,omitempty"`
+
 	// Plugins are tasks that run during a workflow but are not part of the main workflow.
 	// Plugins can be treated as just another task, however, plugins do not have completion or failure
 	// detection.
@@ -169,6 +264,138 @@ type TerraformSpec struct {
 	Plugins map[TaskName]Plugin `json:"plugins,omitempty"`
 }
 
+// Dependency is a reference to another Terraform resource and the outputs to pass from it.
+// +k8s:openapi-gen=true
+type Dependency struct {
+	// Name of the Terraform resource
+	Name string `json:"name"`
+
+	// Namespace of the Terraform resource. Defaults to the namespace of this resource. Dependencies in other
+	// namespaces are rejected unless the controller runs with `--allow-cross-namespace-dependencies`.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Outputs are the outputs of the dependency to pass into the module as input variables.
+	// +optional
+	Outputs []DependencyOutput `json:"outputs,omitempty"`
+}
+
+// DependencyOutput maps an output of a dependency to an input variable of the module.
+// +k8s:openapi-gen=true
+type DependencyOutput struct {
+	// Name of the output of the dependency. The output is read from the dependency's outputs secret, or from
+	// `status.outputs` when the dependency does not save outputs to a secret.
+	Name string `json:"name"`
+
+	// Variable is the name of the input variable that receives the output. The value is written to the
+	// generation's tfvars file of secret values. Defaults to the name of the output.
+	// +optional
+	Variable string `json:"variable,omitempty"`
+}
+
+// Variables defines the input variables of the module.
+// +k8s:openapi-gen=true
+type Variables struct {
+	// Values is a list of input variables. Values from ConfigMaps and literals are written to a generated
+	// `.auto.tfvars` file. Values from Secrets, and the outputs of dependencies, are written to a tfvars file
+	// in the generation's Secret so they are never written to a ConfigMap. That file is loaded last and takes
+	// precedence over every other variable file.
+	// +optional
+	Values []Variable `json:"values,omitempty"`
+
+	// Files are whole tfvars files read from ConfigMaps. Files are loaded in order, before `values`, so
+	// `values` take precedence.
+	// +optional
+	Files []corev1.ConfigMapKeySelector `json:"files,omitempty"`
+}
+
+// Variable is a single input variable of the module.
+// +k8s:openapi-gen=true
+type Variable struct {
+	// Name of the input variable
+	Name string `json:"name"`
+
+	// Value is the literal value of the variable.
+	// +optional
+	Value string `json:"value,omitempty"`
+
+	// Type describes how the value is written to the tfvars file. Can be `string` (default), which quotes
+	// the value, or `number`, `bool`, `hcl` or `json` which are written as is. Use `hcl` or `json` for lists,
+	// maps and objects, eg `["a", "b"]` or `{"key": "value"}`.
+	// +kubebuilder:validation:Enum=string;number;bool;hcl;json
+	// +optional
+	Type VariableType `json:"type,omitempty"`
+
+	// ValueFrom reads the value from a ConfigMap or Secret key in the resource's namespace.
+	// +optional
+	ValueFrom *VariableSource `json:"valueFrom,omitempty"`
+}
+
+// VariableType is how the value of a variable is interpreted.
+type VariableType string
+
+const (
+	VariableTypeString VariableType = "string"
+	VariableTypeNumber VariableType = "number"
+	VariableTypeBool   VariableType = "bool"
+	VariableTypeHCL    VariableType = "hcl"
+	VariableTypeJSON   VariableType = "json"
+)
+
+// ExportRepo configures the git repo the configuration is exported to. Credentials are taken from the
+// `scmAuthMethods` of the repo's host.
+// +k8s:openapi-gen=true
+type ExportRepo struct {
+	// Address is the git repo to export to, eg `git@github.com:org/repo.git` or
+	// `https://github.com/org/repo.git`.
+	Address string `json:"address"`
+
+	// Branch to commit to. It is created from the default branch when it does not exist. Defaults to the
+	// default branch of the repo.
+	// +optional
+	Branch string `json:"branch,omitempty"`
+
+	// TFVarsFile is the path, relative to the root of the repo, of the rendered variables. Defaults to
+	// `<namespace>/<name>/terraform.tfvars`.
+	// +optional
+	TFVarsFile string `json:"tfvarsFile,omitempty"`
+
+	// ConfFile is the path, relative to the root of the repo, of the backend configuration. Defaults to
+	// `<namespace>/<name>/backend.tf`.
+	// +optional
+	ConfFile string `json:"confFile,omitempty"`
+
+	// PlanSummaryFile is the path, relative to the root of the repo, of the plan summary. Defaults to
+	// `<namespace>/<name>/plan.txt`.
+	// +optional
+	PlanSummaryFile string `json:"planSummaryFile,omitempty"`
+
+	// GitEmail is the email of the commit author. This is typically an automation user, probably the user
+	// whose token or ssh key is configured in `scmAuthMethods`.
+	// +optional
+	GitEmail string `json:"gitEmail,omitempty"`
+
+	// GitUsername is the name of the commit author.
+	// +optional
+	GitUsername string `json:"gitUsername,omitempty"`
+
+	// RetryOnFailure retries the export until it succeeds instead of moving on to the next task.
+	// +optional
+	RetryOnFailure bool `json:"retryOnFailure,omitempty"`
+}
+
+// VariableSource selects the source of a variable's value. Only one of the sources can be used.
+// +k8s:openapi-gen=true
+type VariableSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
 // Setup are things that only happen during the life of the setup task.
 // +k8s:openapi-gen=true
 type Setup struct {
@@ -218,7 +445,14 @@ type Module struct {
 	// Terraform Operator prefers modules that are defined in a git repo as opposed to other scm types.
 	// Refer to https://www.terraform.io/language/modules/sources#module-sources for more details.
 	Source string `json:"source,omitempty"`
-	// Version to select from a terraform registry. For version to be used, source must be defined.
+	// Checksum is verified before the module's files are put into the main module directory. Refer to
+	// ResourceDownload.Checksum for the format. Source supports the same addresses as ResourceDownload.Address.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+	// Version is the version constraint, eg `~> 3.0` or `>= 1.2.0, < 2.0.0`, of a module from a terraform
+	// registry, ie when source is `<namespace>/<name>/<provider>` or `<host>/<namespace>/<name>/<provider>`.
+	// The newest version that matches is selected when a generation starts and is kept in
+	// `status.resolvedModuleVersion` for the rest of the generation. The newest version is used when not set.
 	// Refer to https://www.terraform.io/language/modules/sources#module-sources for more details
 	Version string `json:"version,omitempty"`
 
@@ -234,6 +468,15 @@ type Module struct {
 
 	// Inline used to define an entire terraform module inline and then mounted in the TFO_MAIN_MODULE path.
 	Inline string `json:"inline,omitempty"`
+
+	// PollInterval is how often the controller checks the git source's ref, eg `?ref=main`, for new commits.
+	// When the ref points to a new commit, a new run is started. The minimum interval is 1m. Polling is
+	// disabled when not set.
+	//
+	// Git refs are always resolved to a commit sha when a generation starts so every task of the generation
+	// uses the same commit.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
 }
 
 type TaskName string
@@ -375,6 +618,19 @@ type TaskOption struct {
 	// Script is used to configure the source of the task's executable script.
 	// +optional
 	Script StageScript `json:"script,omitempty"`
+
+	// Timeout is the maximum duration the task pod is allowed to be active. It is enforced by setting the
+	// pod's activeDeadlineSeconds. When the deadline is exceeded the pod fails with the reason
+	// `DeadlineExceeded`. When more than one taskOption defines a timeout for a task, the last one wins.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// PendingTimeout is the maximum duration a task pod can be in the `Pending` phase, for example when the
+	// image cannot be pulled or the pod cannot be scheduled. Once exceeded, the stage is failed and the cause
+	// is copied into the stage message. Defaults to the controller's `--task-pending-timeout`. Set to "0s"
+	// to disable.
+	// +optional
+	PendingTimeout *metav1.Duration `json:"pendingTimeout,omitempty"`
 }
 
 // StageScript defines the different ways of sourcing execution scripts of tasks. There is an order of
@@ -384,9 +640,17 @@ type TaskOption struct {
 //     3. source
 // +k8s:openapi-gen=true
 type StageScript struct {
-	// Source is an http source that the task container will fetch and then execute.
+	// Source is an http source that the task container will fetch and then execute. When the tasks do not
+	// have a script, the default scripts bundled with the operator are used.
 	Source string `json:"source,omitempty"`
 
+	// Checksum pins the content of the source, eg `sha256:<hex>`. The controller downloads the source when
+	// the generation starts and fails the run when the content does not match. The tasks run the verified
+	// content instead of fetching the source.
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-fA-F]{64}$`
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
 	// ConfigMapSelector reads a in a script from a configmap name+key
 	ConfigMapSelector *ConfigMapSelector `json:"configMapSelector,omitempty"`
 
@@ -410,6 +674,16 @@ type SCMAuthMethod struct {
 
 	// Git configuration options for auth methods of git
 	Git *GitSCM `json:"git,omitempty"`
+
+	// Registry configures the token of a private terraform module registry on the host. The token is used
+	// to resolve registry modules and is passed to the tasks as `TF_TOKEN_<host>`.
+	Registry *RegistrySCM `json:"registry,omitempty"`
+}
+
+// RegistrySCM defines the auth method of a terraform registry
+// +k8s:openapi-gen=true
+type RegistrySCM struct {
+	TokenSecretRef *TokenSecretRef `json:"tokenSecretRef"`
 }
 
 // GitSCM define the auth methods of git
@@ -426,11 +700,11 @@ type GitSSH struct {
 	SSHKeySecretRef *SSHKeySecretRef `json:"sshKeySecretRef"`
 }
 
-// GitHTTPS configures the setup for git over https using tokens. Proxy is not
-// supported in the terraform job pod at this moment
-// TODO HTTPS Proxy support
+// GitHTTPS configures the setup for git over https using tokens
 // +k8s:openapi-gen=true
 type GitHTTPS struct {
+	// RequireProxy routes the https traffic to the host, ie git and resourceDownloads, through a SOCKS5
+	// proxy the runner starts with the ssh tunnel. Requires `spec.sshTunnel`.
 	RequireProxy   bool            `json:"requireProxy,omitempty"`
 	TokenSecretRef *TokenSecretRef `json:"tokenSecretRef"`
 }
@@ -449,9 +723,24 @@ type TerraformList struct {
 // +k8s:openapi-gen=true
 type ResourceDownload struct {
 
-	// Address defines the source address resources to fetch.
+	// Address defines the source address resources to fetch. Along with git repos and the other go-getter
+	// sources, the supported addresses are:
+	//
+	// - http(s) urls of files and archives, eg `https://example.com/vpc.tar.gz`
+	// - S3 objects, eg `s3://<bucket>/<key>` or `s3::https://<endpoint>/<bucket>/<key>`
+	// - GCS objects, eg `gs://<bucket>/<object>`
+	// - OCI artifacts, eg `oci://<registry>/<repository>:<tag>`
+	// - ConfigMaps and Secrets in the namespace of the resource, eg `configmap::<name>[/<key>]` or
+	//   `secret::<name>[/<key>]`. All the keys are downloaded when the key is not given.
 	Address string `json:"address"`
 
+	// Checksum is verified before the downloaded files are put into the main module directory. Archives are
+	// verified before they are extracted. The format is `<type>:<hex>` where type is one of md5, sha1, sha256
+	// or sha512, eg `sha256:2ef7bde6...`. Only files and archives can be verified, the controller rejects a
+	// checksum for a git repo, an OCI artifact or a ConfigMap or Secret without a key.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
 	// Path will download the resources into this path which is relative to
 	// the main module directory.
 	Path string `json:"path,omitempty"`
@@ -469,6 +758,180 @@ type ProxyOpts struct {
 	SSHKeySecretRef SSHKeySecretRef `json:"sshKeySecretRef"`
 }
 
+// TrustedCA selects the ConfigMap and Secret keys with PEM encoded CA certificates. Both can be used.
+// The bundle is mounted into every task and SSL_CERT_FILE and GIT_SSL_CAINFO point to it.
+// +k8s:openapi-gen=true
+type TrustedCA struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap in the namespace of the resource.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret in the namespace of the resource.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// PluginCacheScope selects which resources share a plugin cache
+type PluginCacheScope string
+
+const (
+	// PluginCacheScopeNamespace shares the cache between the resources of a namespace
+	PluginCacheScopeNamespace PluginCacheScope = "Namespace"
+
+	// PluginCacheScopeCluster shares the cache between every resource using the cluster scope
+	PluginCacheScopeCluster PluginCacheScope = "Cluster"
+)
+
+// PluginCache is mounted into the terraform tasks and TF_PLUGIN_CACHE_DIR points to it. The task holds
+// the cache's lock file while it installs providers, since terraform does not support concurrent writes to
+// the cache, and removes the provider versions that have not been used for the controller's
+// `--plugin-cache-retention`. The retention is not set per resource since the resources share the cache.
+// +k8s:openapi-gen=true
+type PluginCache struct {
+	// Scope is `Namespace` (default) or `Cluster`. The namespace cache is a ReadWriteMany
+	// PersistentVolumeClaim, `tfo-plugin-cache`, created by the controller in the resource's namespace.
+	// The cluster cache is the controller-wide volume (`--plugin-cache-volume-file`), eg a nfs share.
+	// +kubebuilder:validation:Enum=Namespace;Cluster
+	// +optional
+	Scope PluginCacheScope `json:"scope,omitempty"`
+
+	// StorageClassName of the namespace cache's claim. The class must support ReadWriteMany. Only used
+	// when the controller creates the claim.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size of the namespace cache's claim. Defaults to "10Gi". Only used when the controller creates the
+	// claim.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// CLIConfig is rendered into the terraform CLI configuration file. TF_CLI_CONFIG_FILE points to it.
+// +k8s:openapi-gen=true
+type CLIConfig struct {
+	// ProviderInstallation configures where `terraform init` installs providers from. It replaces the
+	// controller-wide default's providerInstallation.
+	// +optional
+	ProviderInstallation *ProviderInstallation `json:"providerInstallation,omitempty"`
+
+	// Credentials are the API tokens of registries and of Terraform Cloud/Enterprise hosts. They are
+	// rendered into `credentials` blocks and set as `TF_TOKEN_<host>` variables. A host's credentials
+	// replace the controller-wide default's credentials of the same host.
+	// +optional
+	Credentials []CLICredentials `json:"credentials,omitempty"`
+}
+
+// ProviderInstallation is the `provider_installation` block. The methods are tried in order: the
+// filesystem mirrors, the network mirrors and direct installation.
+// +k8s:openapi-gen=true
+type ProviderInstallation struct {
+	// FilesystemMirrors are directories of the task image, or mounted into it, with the providers.
+	// +optional
+	FilesystemMirrors []FilesystemMirror `json:"filesystemMirrors,omitempty"`
+
+	// NetworkMirrors are https servers that implement the provider network mirror protocol.
+	// +optional
+	NetworkMirrors []NetworkMirror `json:"networkMirrors,omitempty"`
+
+	// Direct installs providers from their origin registries. When nil and mirrors are defined, providers
+	// are only installed from the mirrors.
+	// +optional
+	Direct *ProviderInstallationMethod `json:"direct,omitempty"`
+}
+
+// ProviderInstallationMethod selects the providers of an installation method, eg
+// `registry.terraform.io/hashicorp/*`. When include is empty, every provider is included.
+// +k8s:openapi-gen=true
+type ProviderInstallationMethod struct {
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// FilesystemMirror is a `filesystem_mirror` method
+// +k8s:openapi-gen=true
+type FilesystemMirror struct {
+	// Path is the directory of the mirror
+	Path string `json:"path"`
+
+	ProviderInstallationMethod `json:",inline"`
+}
+
+// NetworkMirror is a `network_mirror` method
+// +k8s:openapi-gen=true
+type NetworkMirror struct {
+	// URL of the mirror. Must be https and end with a `/`.
+	URL string `json:"url"`
+
+	ProviderInstallationMethod `json:",inline"`
+}
+
+// CLICredentials is the token of a host
+// +k8s:openapi-gen=true
+type CLICredentials struct {
+	// Host is the hostname, and optional port, of the registry or Terraform Cloud/Enterprise.
+	Host string `json:"host"`
+
+	// TokenSecretRef selects the token
+	TokenSecretRef TokenSecretRef `json:"tokenSecretRef"`
+}
+
+// Network configures the proxies and tunnels used by the tasks
+// +k8s:openapi-gen=true
+type Network struct {
+	// HTTPProxy is set as HTTP_PROXY in every task, eg `http://proxy.corp.example.com:3128`.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is set as HTTPS_PROXY in every task.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy are the hosts, domains (eg `.corp.example.com`) and CIDRs that are not proxied. The kubernetes
+	// api and cluster domains are always added when a proxy is used.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+
+	// Tunnel runs the ssh tunnel of `spec.sshTunnel` as a sidecar of the terraform tasks so providers can
+	// reach endpoints that are only reachable through the bastion.
+	// +optional
+	Tunnel *NetworkTunnel `json:"tunnel,omitempty"`
+}
+
+// NetworkTunnel configures the ssh tunnel sidecar of the terraform tasks
+// +k8s:openapi-gen=true
+type NetworkTunnel struct {
+	// Proxy sets HTTPS_PROXY and HTTP_PROXY of the terraform tasks to the SOCKS5 proxy of the tunnel. It
+	// takes precedence over httpProxy and httpsProxy.
+	// +optional
+	Proxy bool `json:"proxy,omitempty"`
+
+	// Endpoints are forwarded through the tunnel. Each endpoint's host resolves to a loopback address in
+	// the task pod where the tunnel listens on the endpoint's port, so providers connect to the usual
+	// host and port and TLS verification keeps working.
+	// +optional
+	Endpoints []TunnelEndpoint `json:"endpoints,omitempty"`
+
+	// Image of the sidecar, which needs a shell and an ssh client. Defaults to the setup image.
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// TunnelEndpoint is a host and port forwarded through the ssh tunnel
+// +k8s:openapi-gen=true
+type TunnelEndpoint struct {
+	// Name of the endpoint. The forwarded address is set as TFO_ENDPOINT_<NAME> in the terraform tasks.
+	Name string `json:"name"`
+
+	// Host as resolved by the bastion, eg `vault.internal.example.com`.
+	Host string `json:"host"`
+
+	// Port of the endpoint, eg `8200`.
+	Port int32 `json:"port"`
+}
+
 // SSHKeySecretRef defines the secret where the SSH key (for the proxy, git, etc) is stored
 // +k8s:openapi-gen=true
 type SSHKeySecretRef struct {
@@ -600,6 +1063,114 @@ type TerraformStatus struct {
 	// refreshed each generation.
 	// +optional
 	Plugins []TaskName `json:"plugins,omitempty"`
+
+	// Queued is true while the workflow waits on its dependencies, its concurrency group or the run queue.
+	// Create workflows are in the `queued` phase while they wait and delete workflows keep the
+	// `initializing-delete` phase.
+	// +optional
+	Queued bool `json:"queued,omitempty"`
+
+	// QueuePosition is the position of the workflow in the run queue while it is queued.
+	// +optional
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+
+	// ConcurrencyGroupHolder is the resource, as `namespace/name`, that currently holds the concurrency group.
+	// +optional
+	ConcurrencyGroupHolder string `json:"concurrencyGroupHolder,omitempty"`
+
+	// WaitingOn is a list of resources, as `namespace/name`, the workflow is waiting on. These are the
+	// dependencies that have not completed, or when the resource is being deleted, the resources that still
+	// depend on it.
+	// +optional
+	WaitingOn []string `json:"waitingOn,omitempty"`
+
+	// InputHashes are the sha256 hashes of the ConfigMaps and Secrets used by the current run keyed by
+	// `<kind>/<namespace>/<name>`. Comparing hashes shows which input changed when a run starts because
+	// of `watchInputs`.
+	// +optional
+	InputHashes map[string]string `json:"inputHashes,omitempty"`
+
+	// ResolvedCommit is the commit sha the git source's ref pointed to when the current run started.
+	// +optional
+	ResolvedCommit string `json:"resolvedCommit,omitempty"`
+
+	// LastGitPush is the `<ref>@<commit>` of the last git push received by the git webhook that was run.
+	// +optional
+	LastGitPush string `json:"lastGitPush,omitempty"`
+
+	// ResolvedModuleVersion is the version of the registry module selected when the current generation
+	// started.
+	// +optional
+	ResolvedModuleVersion string `json:"resolvedModuleVersion,omitempty"`
+
+	// ResolvedEngineVersion is the version of the engine the current generation runs. Version constraints
+	// and `latest` are resolved to a version of the controller's list of available versions when the
+	// generation starts.
+	// +optional
+	ResolvedEngineVersion string `json:"resolvedEngineVersion,omitempty"`
+
+	// ExportedGeneration is the last generation exported to the `exportRepo`.
+	// +optional
+	ExportedGeneration int64 `json:"exportedGeneration,omitempty"`
+
+	// ExportedCommit is the commit sha of the last export to the `exportRepo`.
+	// +optional
+	ExportedCommit string `json:"exportedCommit,omitempty"`
+
+	// PlanResults are the changes planned for each module by the last completed plan of a terragrunt
+	// resource or of a resource in the plan-only or refresh-only mode.
+	// +optional
+	PlanResults []PlanResult `json:"planResults,omitempty"`
+
+	// Result is what the last completed workflow did. It is `Applied` in the apply mode. `Planned` and
+	// `Refreshed` tell that the plan-only and refresh-only workflows did not change the infrastructure.
+	// +optional
+	Result RunResult `json:"result,omitempty"`
+
+	// Applied is true once an apply task of the apply mode has started. The delete workflow of an applied
+	// resource applies the destroy plan whatever the mode so the infrastructure is not orphaned when the
+	// mode is changed to plan-only or refresh-only.
+	// +optional
+	Applied bool `json:"applied,omitempty"`
+}
+
+// RunMode selects what the workflow does with the plan
+type RunMode string
+
+const (
+	// RunModeApply applies the plan
+	RunModeApply RunMode = "apply"
+
+	// RunModePlanOnly stops the workflow after the plan
+	RunModePlanOnly RunMode = "plan-only"
+
+	// RunModeRefreshOnly plans and applies a refresh of the state
+	RunModeRefreshOnly RunMode = "refresh-only"
+)
+
+// RunResult is what a completed workflow did
+type RunResult string
+
+const (
+	// RunResultApplied is the result of the apply mode
+	RunResultApplied RunResult = "Applied"
+
+	// RunResultPlanned is the result of the plan-only mode, which did not apply anything
+	RunResultPlanned RunResult = "Planned"
+
+	// RunResultRefreshed is the result of the refresh-only mode, which only updated the state
+	RunResultRefreshed RunResult = "Refreshed"
+)
+
+// PlanResult is the number of changes the plan of a module found
+// +k8s:openapi-gen=true
+type PlanResult struct {
+	// Module is the path of the module relative to the terragrunt directory, `.` for the directory itself.
+	Module string `json:"module"`
+
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
 }
 
 type Exported string
@@ -647,6 +1218,25 @@ type Stage struct {
 	// PodName is the pod assigned to execute the stage.
 	// +optional
 	PodName string `json:"podName,omitempty"`
+
+	// ExitCode is the exit code of the task container once it has terminated.
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// TerminationReason is the reason the task container or pod terminated, such as `Error`, `OOMKilled`
+	// or `DeadlineExceeded`.
+	// +optional
+	TerminationReason string `json:"terminationReason,omitempty"`
+
+	// ErrorMarker is the content the task wrote to its error marker file (`TFO_ERROR_MARKER_FILE`) before
+	// failing. Sensitive values are masked.
+	// +optional
+	ErrorMarker string `json:"errorMarker,omitempty"`
+
+	// LogTail is the last lines of the task log captured when the task failed. Sensitive values are
+	// masked.
+	// +optional
+	LogTail string `json:"logTail,omitempty"`
 }
 
 // IsEqual checks desired stage if equal to current stage
@@ -676,6 +1266,7 @@ type StatusPhase string
 
 const (
 	PhaseInitializing StatusPhase = "initializing"
+	PhaseQueued       StatusPhase = "queued"
 	PhaseCompleted    StatusPhase = "completed"
 	PhaseRunning      StatusPhase = "running"
 	PhaseInitDelete   StatusPhase = "initializing-delete"
@@ -704,3 +1295,66 @@ func init() {
 	SchemeBuilder.Register(&Terraform{}, &TerraformList{})
 
 }
+
+// EngineName is the tool that runs the module
+type EngineName string
+
+const (
+	// EngineTerraform runs the module with HashiCorp Terraform
+	EngineTerraform EngineName = "terraform"
+
+	// EngineOpenTofu runs the module with OpenTofu
+	EngineOpenTofu EngineName = "opentofu"
+)
+
+// Engine selects the tool that runs the module and its version
+// +k8s:openapi-gen=true
+type Engine struct {
+	// Name of the engine. The default task image of the terraform tasks, the binary the tasks run and the
+	// name of the rendered CLI config, `.terraformrc` or `.tofurc`, depend on the engine.
+	// +kubebuilder:validation:Enum=terraform;opentofu
+	// +kubebuilder:default=terraform
+	// +optional
+	Name EngineName `json:"name,omitempty"`
+
+	// Version of the engine, or a version constraint, used as the tag of the terraform tasks' image like
+	// terraformVersion. The terraform engine defaults to terraformVersion.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// StateEncryption configures the state and plan encryption of OpenTofu. It is only supported by the
+	// opentofu engine.
+	// +optional
+	StateEncryption *StateEncryption `json:"stateEncryption,omitempty"`
+}
+
+// StateEncryption selects the encryption config of OpenTofu
+// +k8s:openapi-gen=true
+type StateEncryption struct {
+	// ConfigSecretRef selects a key of a Secret, in the namespace of the resource, with the HCL or JSON
+	// `encryption` block, eg the key provider and its passphrase. It is given to the tasks as TF_ENCRYPTION.
+	ConfigSecretRef corev1.SecretKeySelector `json:"configSecretRef"`
+}
+
+// Terragrunt configures how the terraform tasks run terragrunt
+// +k8s:openapi-gen=true
+type Terragrunt struct {
+	// Version of terragrunt. The tasks fail when the terragrunt of the image is a different version.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Dir is the directory, relative to the main module, terragrunt runs in. Defaults to the main module.
+	// +optional
+	Dir string `json:"dir,omitempty"`
+
+	// ConfigPath is the path, relative to dir, of the terragrunt config when it is not `terragrunt.hcl`.
+	// It can not be used with runAll since every module of the stack has its own config.
+	// +optional
+	ConfigPath string `json:"configPath,omitempty"`
+
+	// RunAll runs the commands with `run-all` for every terragrunt module under dir. The modules are
+	// destroyed with `run-all destroy` so they are removed in the reverse order of their dependencies and
+	// their outputs are not saved.
+	// +optional
+	RunAll bool `json:"runAll,omitempty"`
+}
@@ -22,8 +22,9 @@ limitations under the License.
 package v1alpha2
 
 import (
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/api/rbac/v1"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -42,6 +43,47 @@ func (in *AWSCredentials) DeepCopy() *AWSCredentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CLIConfig) DeepCopyInto(out *CLIConfig) {
+	*out = *in
+	if in.ProviderInstallation != nil {
+		in, out := &in.ProviderInstallation, &out.ProviderInstallation
+		*out = new(ProviderInstallation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = make([]CLICredentials, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CLIConfig.
+func (in *CLIConfig) DeepCopy() *CLIConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CLIConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CLICredentials) DeepCopyInto(out *CLICredentials) {
+	*out = *in
+	out.TokenSecretRef = in.TokenSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CLICredentials.
+func (in *CLICredentials) DeepCopy() *CLICredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(CLICredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigMapSelector) DeepCopyInto(out *ConfigMapSelector) {
 	*out = *in
@@ -81,6 +123,92 @@ func (in *Credentials) DeepCopy() *Credentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Dependency) DeepCopyInto(out *Dependency) {
+	*out = *in
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]DependencyOutput, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Dependency.
+func (in *Dependency) DeepCopy() *Dependency {
+	if in == nil {
+		return nil
+	}
+	out := new(Dependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyOutput) DeepCopyInto(out *DependencyOutput) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyOutput.
+func (in *DependencyOutput) DeepCopy() *DependencyOutput {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyOutput)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Engine) DeepCopyInto(out *Engine) {
+	*out = *in
+	if in.StateEncryption != nil {
+		in, out := &in.StateEncryption, &out.StateEncryption
+		*out = new(StateEncryption)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Engine.
+func (in *Engine) DeepCopy() *Engine {
+	if in == nil {
+		return nil
+	}
+	out := new(Engine)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportRepo) DeepCopyInto(out *ExportRepo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportRepo.
+func (in *ExportRepo) DeepCopy() *ExportRepo {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportRepo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FilesystemMirror) DeepCopyInto(out *FilesystemMirror) {
+	*out = *in
+	in.ProviderInstallationMethod.DeepCopyInto(&out.ProviderInstallationMethod)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FilesystemMirror.
+func (in *FilesystemMirror) DeepCopy() *FilesystemMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(FilesystemMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitHTTPS) DeepCopyInto(out *GitHTTPS) {
 	*out = *in
@@ -199,6 +327,11 @@ func (in *Module) DeepCopyInto(out *Module) {
 		*out = new(ConfigMapSelector)
 		**out = **in
 	}
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Module.
@@ -211,6 +344,82 @@ func (in *Module) DeepCopy() *Module {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Network) DeepCopyInto(out *Network) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tunnel != nil {
+		in, out := &in.Tunnel, &out.Tunnel
+		*out = new(NetworkTunnel)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
+func (in *Network) DeepCopy() *Network {
+	if in == nil {
+		return nil
+	}
+	out := new(Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkMirror) DeepCopyInto(out *NetworkMirror) {
+	*out = *in
+	in.ProviderInstallationMethod.DeepCopyInto(&out.ProviderInstallationMethod)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkMirror.
+func (in *NetworkMirror) DeepCopy() *NetworkMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkTunnel) DeepCopyInto(out *NetworkTunnel) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]TunnelEndpoint, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkTunnel.
+func (in *NetworkTunnel) DeepCopy() *NetworkTunnel {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkTunnel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlanResult) DeepCopyInto(out *PlanResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlanResult.
+func (in *PlanResult) DeepCopy() *PlanResult {
+	if in == nil {
+		return nil
+	}
+	out := new(PlanResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Plugin) DeepCopyInto(out *Plugin) {
 	*out = *in
@@ -227,6 +436,90 @@ func (in *Plugin) DeepCopy() *Plugin {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginCache) DeepCopyInto(out *PluginCache) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginCache.
+func (in *PluginCache) DeepCopy() *PluginCache {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderInstallation) DeepCopyInto(out *ProviderInstallation) {
+	*out = *in
+	if in.FilesystemMirrors != nil {
+		in, out := &in.FilesystemMirrors, &out.FilesystemMirrors
+		*out = make([]FilesystemMirror, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NetworkMirrors != nil {
+		in, out := &in.NetworkMirrors, &out.NetworkMirrors
+		*out = make([]NetworkMirror, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Direct != nil {
+		in, out := &in.Direct, &out.Direct
+		*out = new(ProviderInstallationMethod)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderInstallation.
+func (in *ProviderInstallation) DeepCopy() *ProviderInstallation {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderInstallation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderInstallationMethod) DeepCopyInto(out *ProviderInstallationMethod) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderInstallationMethod.
+func (in *ProviderInstallationMethod) DeepCopy() *ProviderInstallationMethod {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderInstallationMethod)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProxyOpts) DeepCopyInto(out *ProxyOpts) {
 	*out = *in
@@ -243,6 +536,26 @@ func (in *ProxyOpts) DeepCopy() *ProxyOpts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistrySCM) DeepCopyInto(out *RegistrySCM) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(TokenSecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrySCM.
+func (in *RegistrySCM) DeepCopy() *RegistrySCM {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistrySCM)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceDownload) DeepCopyInto(out *ResourceDownload) {
 	*out = *in
@@ -266,6 +579,11 @@ func (in *SCMAuthMethod) DeepCopyInto(out *SCMAuthMethod) {
 		*out = new(GitSCM)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(RegistrySCM)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SCMAuthMethod.
@@ -333,6 +651,11 @@ func (in *Stage) DeepCopyInto(out *Stage) {
 	*out = *in
 	in.StartTime.DeepCopyInto(&out.StartTime)
 	in.StopTime.DeepCopyInto(&out.StopTime)
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Stage.
@@ -365,6 +688,22 @@ func (in *StageScript) DeepCopy() *StageScript {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateEncryption) DeepCopyInto(out *StateEncryption) {
+	*out = *in
+	in.ConfigSecretRef.DeepCopyInto(&out.ConfigSecretRef)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateEncryption.
+func (in *StateEncryption) DeepCopy() *StateEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(StateEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TaskOption) DeepCopyInto(out *TaskOption) {
 	*out = *in
@@ -375,7 +714,7 @@ func (in *TaskOption) DeepCopyInto(out *TaskOption) {
 	}
 	if in.PolicyRules != nil {
 		in, out := &in.PolicyRules, &out.PolicyRules
-		*out = make([]v1.PolicyRule, len(*in))
+		*out = make([]rbacv1.PolicyRule, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -396,24 +735,34 @@ func (in *TaskOption) DeepCopyInto(out *TaskOption) {
 	}
 	if in.EnvFrom != nil {
 		in, out := &in.EnvFrom, &out.EnvFrom
-		*out = make([]corev1.EnvFromSource, len(*in))
+		*out = make([]v1.EnvFromSource, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]corev1.EnvVar, len(*in))
+		*out = make([]v1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
+		*out = new(v1.ResourceRequirements)
 		(*in).DeepCopyInto(*out)
 	}
 	in.Script.DeepCopyInto(&out.Script)
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PendingTimeout != nil {
+		in, out := &in.PendingTimeout, &out.PendingTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TaskOption.
@@ -503,6 +852,11 @@ func (in *TerraformSpec) DeepCopyInto(out *TerraformSpec) {
 		x := (*in).DeepCopy()
 		*out = &x
 	}
+	if in.PluginCache != nil {
+		in, out := &in.PluginCache, &out.PluginCache
+		*out = new(PluginCache)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Credentials != nil {
 		in, out := &in.Credentials, &out.Credentials
 		*out = make([]Credentials, len(*in))
@@ -515,6 +869,21 @@ func (in *TerraformSpec) DeepCopyInto(out *TerraformSpec) {
 		*out = new(ProxyOpts)
 		**out = **in
 	}
+	if in.TrustedCA != nil {
+		in, out := &in.TrustedCA, &out.TrustedCA
+		*out = new(TrustedCA)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CLIConfig != nil {
+		in, out := &in.CLIConfig, &out.CLIConfig
+		*out = new(CLIConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(Network)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.SCMAuthMethods != nil {
 		in, out := &in.SCMAuthMethods, &out.SCMAuthMethods
 		*out = make([]SCMAuthMethod, len(*in))
@@ -533,6 +902,16 @@ func (in *TerraformSpec) DeepCopyInto(out *TerraformSpec) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.TerraformModule.DeepCopyInto(&out.TerraformModule)
+	if in.Engine != nil {
+		in, out := &in.Engine, &out.Engine
+		*out = new(Engine)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Terragrunt != nil {
+		in, out := &in.Terragrunt, &out.Terragrunt
+		*out = new(Terragrunt)
+		**out = **in
+	}
 	if in.TaskOptions != nil {
 		in, out := &in.TaskOptions, &out.TaskOptions
 		*out = make([]TaskOption, len(*in))
@@ -540,6 +919,23 @@ func (in *TerraformSpec) DeepCopyInto(out *TerraformSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]Dependency, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Variables != nil {
+		in, out := &in.Variables, &out.Variables
+		*out = new(Variables)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExportRepo != nil {
+		in, out := &in.ExportRepo, &out.ExportRepo
+		*out = new(ExportRepo)
+		**out = **in
+	}
 	if in.Plugins != nil {
 		in, out := &in.Plugins, &out.Plugins
 		*out = make(map[TaskName]Plugin, len(*in))
@@ -582,6 +978,23 @@ func (in *TerraformStatus) DeepCopyInto(out *TerraformStatus) {
 		*out = make([]TaskName, len(*in))
 		copy(*out, *in)
 	}
+	if in.WaitingOn != nil {
+		in, out := &in.WaitingOn, &out.WaitingOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InputHashes != nil {
+		in, out := &in.InputHashes, &out.InputHashes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PlanResults != nil {
+		in, out := &in.PlanResults, &out.PlanResults
+		*out = make([]PlanResult, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TerraformStatus.
@@ -594,6 +1007,21 @@ func (in *TerraformStatus) DeepCopy() *TerraformStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Terragrunt) DeepCopyInto(out *Terragrunt) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Terragrunt.
+func (in *Terragrunt) DeepCopy() *Terragrunt {
+	if in == nil {
+		return nil
+	}
+	out := new(Terragrunt)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TokenSecretRef) DeepCopyInto(out *TokenSecretRef) {
 	*out = *in
@@ -608,3 +1036,117 @@ func (in *TokenSecretRef) DeepCopy() *TokenSecretRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedCA) DeepCopyInto(out *TrustedCA) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedCA.
+func (in *TrustedCA) DeepCopy() *TrustedCA {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TunnelEndpoint) DeepCopyInto(out *TunnelEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TunnelEndpoint.
+func (in *TunnelEndpoint) DeepCopy() *TunnelEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(TunnelEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Variable) DeepCopyInto(out *Variable) {
+	*out = *in
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(VariableSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Variable.
+func (in *Variable) DeepCopy() *Variable {
+	if in == nil {
+		return nil
+	}
+	out := new(Variable)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VariableSource) DeepCopyInto(out *VariableSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VariableSource.
+func (in *VariableSource) DeepCopy() *VariableSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VariableSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Variables) DeepCopyInto(out *Variables) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]Variable, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]v1.ConfigMapKeySelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Variables.
+func (in *Variables) DeepCopy() *Variables {
+	if in == nil {
+		return nil
+	}
+	out := new(Variables)
+	in.DeepCopyInto(out)
+	return out
+}
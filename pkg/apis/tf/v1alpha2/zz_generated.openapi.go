@@ -30,29 +30,51 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.AWSCredentials":    schema_pkg_apis_tf_v1alpha2_AWSCredentials(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector": schema_pkg_apis_tf_v1alpha2_ConfigMapSelector(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials":       schema_pkg_apis_tf_v1alpha2_Credentials(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitHTTPS":          schema_pkg_apis_tf_v1alpha2_GitHTTPS(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM":            schema_pkg_apis_tf_v1alpha2_GitSCM(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSSH":            schema_pkg_apis_tf_v1alpha2_GitSSH(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ImageConfig":       schema_pkg_apis_tf_v1alpha2_ImageConfig(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images":            schema_pkg_apis_tf_v1alpha2_Images(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module":            schema_pkg_apis_tf_v1alpha2_Module(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin":            schema_pkg_apis_tf_v1alpha2_Plugin(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts":         schema_pkg_apis_tf_v1alpha2_ProxyOpts(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload":  schema_pkg_apis_tf_v1alpha2_ResourceDownload(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod":     schema_pkg_apis_tf_v1alpha2_SCMAuthMethod(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef":   schema_pkg_apis_tf_v1alpha2_SSHKeySecretRef(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SecretNameRef":     schema_pkg_apis_tf_v1alpha2_SecretNameRef(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup":             schema_pkg_apis_tf_v1alpha2_Setup(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage":             schema_pkg_apis_tf_v1alpha2_Stage(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript":       schema_pkg_apis_tf_v1alpha2_StageScript(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption":        schema_pkg_apis_tf_v1alpha2_TaskOption(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Terraform":         schema_pkg_apis_tf_v1alpha2_Terraform(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec":     schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus":   schema_pkg_apis_tf_v1alpha2_TerraformStatus(ref),
-		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef":    schema_pkg_apis_tf_v1alpha2_TokenSecretRef(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.AWSCredentials":             schema_pkg_apis_tf_v1alpha2_AWSCredentials(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLIConfig":                  schema_pkg_apis_tf_v1alpha2_CLIConfig(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLICredentials":             schema_pkg_apis_tf_v1alpha2_CLICredentials(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector":          schema_pkg_apis_tf_v1alpha2_ConfigMapSelector(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials":                schema_pkg_apis_tf_v1alpha2_Credentials(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Dependency":                 schema_pkg_apis_tf_v1alpha2_Dependency(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.DependencyOutput":           schema_pkg_apis_tf_v1alpha2_DependencyOutput(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Engine":                     schema_pkg_apis_tf_v1alpha2_Engine(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ExportRepo":                 schema_pkg_apis_tf_v1alpha2_ExportRepo(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.FilesystemMirror":           schema_pkg_apis_tf_v1alpha2_FilesystemMirror(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitHTTPS":                   schema_pkg_apis_tf_v1alpha2_GitHTTPS(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM":                     schema_pkg_apis_tf_v1alpha2_GitSCM(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSSH":                     schema_pkg_apis_tf_v1alpha2_GitSSH(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ImageConfig":                schema_pkg_apis_tf_v1alpha2_ImageConfig(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images":                     schema_pkg_apis_tf_v1alpha2_Images(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module":                     schema_pkg_apis_tf_v1alpha2_Module(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Network":                    schema_pkg_apis_tf_v1alpha2_Network(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkMirror":              schema_pkg_apis_tf_v1alpha2_NetworkMirror(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkTunnel":              schema_pkg_apis_tf_v1alpha2_NetworkTunnel(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PlanResult":                 schema_pkg_apis_tf_v1alpha2_PlanResult(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin":                     schema_pkg_apis_tf_v1alpha2_Plugin(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PluginCache":                schema_pkg_apis_tf_v1alpha2_PluginCache(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallation":       schema_pkg_apis_tf_v1alpha2_ProviderInstallation(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallationMethod": schema_pkg_apis_tf_v1alpha2_ProviderInstallationMethod(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts":                  schema_pkg_apis_tf_v1alpha2_ProxyOpts(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.RegistrySCM":                schema_pkg_apis_tf_v1alpha2_RegistrySCM(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload":           schema_pkg_apis_tf_v1alpha2_ResourceDownload(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod":              schema_pkg_apis_tf_v1alpha2_SCMAuthMethod(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef":            schema_pkg_apis_tf_v1alpha2_SSHKeySecretRef(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SecretNameRef":              schema_pkg_apis_tf_v1alpha2_SecretNameRef(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup":                      schema_pkg_apis_tf_v1alpha2_Setup(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage":                      schema_pkg_apis_tf_v1alpha2_Stage(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript":                schema_pkg_apis_tf_v1alpha2_StageScript(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StateEncryption":            schema_pkg_apis_tf_v1alpha2_StateEncryption(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption":                 schema_pkg_apis_tf_v1alpha2_TaskOption(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Terraform":                  schema_pkg_apis_tf_v1alpha2_Terraform(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec":              schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus":            schema_pkg_apis_tf_v1alpha2_TerraformStatus(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Terragrunt":                 schema_pkg_apis_tf_v1alpha2_Terragrunt(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef":             schema_pkg_apis_tf_v1alpha2_TokenSecretRef(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TrustedCA":                  schema_pkg_apis_tf_v1alpha2_TrustedCA(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TunnelEndpoint":             schema_pkg_apis_tf_v1alpha2_TunnelEndpoint(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variable":                   schema_pkg_apis_tf_v1alpha2_Variable(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.VariableSource":             schema_pkg_apis_tf_v1alpha2_VariableSource(ref),
+		"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variables":                  schema_pkg_apis_tf_v1alpha2_Variables(ref),
 	}
 }
 
@@ -83,6 +105,72 @@ func schema_pkg_apis_tf_v1alpha2_AWSCredentials(ref common.ReferenceCallback) co
 	}
 }
 
+func schema_pkg_apis_tf_v1alpha2_CLIConfig(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CLIConfig is rendered into the terraform CLI configuration file. TF_CLI_CONFIG_FILE points to it.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"providerInstallation": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ProviderInstallation configures where `terraform init` installs providers from. It replaces the controller-wide default's providerInstallation.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallation"),
+						},
+					},
+					"credentials": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Credentials are the API tokens of registries and of Terraform Cloud/Enterprise hosts. They are rendered into `credentials` blocks and set as `TF_TOKEN_<host>` variables. A host's credentials replace the controller-wide default's credentials of the same host.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLICredentials"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLICredentials", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallation"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_CLICredentials(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "CLICredentials is the token of a host",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"host": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Host is the hostname, and optional port, of the registry or Terraform Cloud/Enterprise.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tokenSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TokenSecretRef selects the token",
+							Default:     map[string]interface{}{},
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef"),
+						},
+					},
+				},
+				Required: []string{"host", "tokenSecretRef"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef"},
+	}
+}
+
 func schema_pkg_apis_tf_v1alpha2_ConfigMapSelector(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -155,17 +243,248 @@ func schema_pkg_apis_tf_v1alpha2_Credentials(ref common.ReferenceCallback) commo
 	}
 }
 
+func schema_pkg_apis_tf_v1alpha2_Dependency(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Dependency is a reference to another Terraform resource and the outputs to pass from it.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the Terraform resource",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"namespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Namespace of the Terraform resource. Defaults to the namespace of this resource. Dependencies in other namespaces are rejected unless the controller runs with `--allow-cross-namespace-dependencies`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"outputs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Outputs are the outputs of the dependency to pass into the module as input variables.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.DependencyOutput"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.DependencyOutput"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_DependencyOutput(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "DependencyOutput maps an output of a dependency to an input variable of the module.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the output of the dependency. The output is read from the dependency's outputs secret, or from `status.outputs` when the dependency does not save outputs to a secret.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"variable": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Variable is the name of the input variable that receives the output. The value is written to the generation's tfvars file of secret values. Defaults to the name of the output.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Engine(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Engine selects the tool that runs the module and its version",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the engine. The default task image of the terraform tasks, the binary the tasks run and the name of the rendered CLI config, `.terraformrc` or `.tofurc`, depend on the engine.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"version": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Version of the engine, or a version constraint, used as the tag of the terraform tasks' image like terraformVersion. The terraform engine defaults to terraformVersion.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"stateEncryption": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StateEncryption configures the state and plan encryption of OpenTofu. It is only supported by the opentofu engine.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StateEncryption"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StateEncryption"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_ExportRepo(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ExportRepo configures the git repo the configuration is exported to. Credentials are taken from the `scmAuthMethods` of the repo's host.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"address": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Address is the git repo to export to, eg `git@github.com:org/repo.git` or `https://github.com/org/repo.git`.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"branch": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Branch to commit to. It is created from the default branch when it does not exist. Defaults to the default branch of the repo.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tfvarsFile": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TFVarsFile is the path, relative to the root of the repo, of the rendered variables. Defaults to `<namespace>/<name>/terraform.tfvars`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"confFile": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConfFile is the path, relative to the root of the repo, of the backend configuration. Defaults to `<namespace>/<name>/backend.tf`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"planSummaryFile": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PlanSummaryFile is the path, relative to the root of the repo, of the plan summary. Defaults to `<namespace>/<name>/plan.txt`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"gitEmail": {
+						SchemaProps: spec.SchemaProps{
+							Description: "GitEmail is the email of the commit author. This is typically an automation user, probably the user whose token or ssh key is configured in `scmAuthMethods`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"gitUsername": {
+						SchemaProps: spec.SchemaProps{
+							Description: "GitUsername is the name of the commit author.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"retryOnFailure": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RetryOnFailure retries the export until it succeeds instead of moving on to the next task.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"address"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_FilesystemMirror(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "FilesystemMirror is a `filesystem_mirror` method",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path is the directory of the mirror",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"include": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"exclude": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
+
 func schema_pkg_apis_tf_v1alpha2_GitHTTPS(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "GitHTTPS configures the setup for git over https using tokens. Proxy is not supported in the terraform job pod at this moment",
+				Description: "GitHTTPS configures the setup for git over https using tokens",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
 					"requireProxy": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"boolean"},
-							Format: "",
+							Description: "RequireProxy routes the https traffic to the host, ie git and resourceDownloads, through a SOCKS5 proxy the runner starts with the ssh tunnel. Requires `spec.sshTunnel`.",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
 					"tokenSecretRef": {
@@ -310,9 +629,16 @@ func schema_pkg_apis_tf_v1alpha2_Module(ref common.ReferenceCallback) common.Ope
 							Format:      "",
 						},
 					},
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum is verified before the module's files are put into the main module directory. Refer to ResourceDownload.Checksum for the format. Source supports the same addresses as ResourceDownload.Address.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 					"version": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Version to select from a terraform registry. For version to be used, source must be defined. Refer to https://www.terraform.io/language/modules/sources#module-sources for more details",
+							Description: "Version is the version constraint, eg `~> 3.0` or `>= 1.2.0, < 2.0.0`, of a module from a terraform registry, ie when source is `<namespace>/<name>/<provider>` or `<host>/<namespace>/<name>/<provider>`. The newest version that matches is selected when a generation starts and is kept in `status.resolvedModuleVersion` for the rest of the generation. The newest version is used when not set. Refer to https://www.terraform.io/language/modules/sources#module-sources for more details",
 							Type:        []string{"string"},
 							Format:      "",
 						},
@@ -330,587 +656,1367 @@ func schema_pkg_apis_tf_v1alpha2_Module(ref common.ReferenceCallback) common.Ope
 							Format:      "",
 						},
 					},
+					"pollInterval": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PollInterval is how often the controller checks the git source's ref, eg `?ref=main`, for new commits. When the ref points to a new commit, a new run is started. The minimum interval is 1m. Polling is disabled when not set.\n\nGit refs are always resolved to a commit sha when a generation starts so every task of the generation uses the same commit.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector"},
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_Plugin(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_Network(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "Plugin Define additional pods to run during a workflow",
+				Description: "Network configures the proxies and tunnels used by the tasks",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"image": {
+					"httpProxy": {
 						SchemaProps: spec.SchemaProps{
-							Description: "The container image from the registry; tags must be omitted",
-							Default:     "",
+							Description: "HTTPProxy is set as HTTP_PROXY in every task, eg `http://proxy.corp.example.com:3128`.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"imagePullPolicy": {
+					"httpsProxy": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
+							Description: "HTTPSProxy is set as HTTPS_PROXY in every task.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"when": {
+					"noProxy": {
 						SchemaProps: spec.SchemaProps{
-							Description: "When is a keyword of a two-part selector of when the plugin gets run in the workflow. The value must be one of\n\n- <code>At</code> to run at the same time as the defined task\n\n- <code>After</code> to run after the defined task has completed.",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "NoProxy are the hosts, domains (eg `.corp.example.com`) and CIDRs that are not proxied. The kubernetes api and cluster domains are always added when a proxy is used.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"task": {
+					"tunnel": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Task is the second part of a two-part selector of when the plugin gets run in the workflow. This should correspond to one of the tfo task names.",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Tunnel runs the ssh tunnel of `spec.sshTunnel` as a sidecar of the terraform tasks so providers can reach endpoints that are only reachable through the bastion.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkTunnel"),
 						},
 					},
 				},
-				Required: []string{"image", "when", "task"},
 			},
 		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkTunnel"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_ProxyOpts(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_NetworkMirror(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "ProxyOpts configures ssh tunnel/socks5 for downloading ssh/https resources",
+				Description: "NetworkMirror is a `network_mirror` method",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"host": {
+					"url": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Description: "URL of the mirror. Must be https and end with a `/`.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"user": {
+					"include": {
 						SchemaProps: spec.SchemaProps{
-							Type:   []string{"string"},
-							Format: "",
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"sshKeySecretRef": {
+					"exclude": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef"),
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
 				},
-				Required: []string{"sshKeySecretRef"},
+				Required: []string{"url"},
 			},
 		},
-		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_ResourceDownload(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_NetworkTunnel(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "ResourceDownload (formerly SrcOpts) defines a resource to fetch using one of the configured protocols: ssh|http|https (eg git::SSH or git::HTTPS)",
+				Description: "NetworkTunnel configures the ssh tunnel sidecar of the terraform tasks",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"address": {
+					"proxy": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Address defines the source address resources to fetch.",
-							Default:     "",
-							Type:        []string{"string"},
+							Description: "Proxy sets HTTPS_PROXY and HTTP_PROXY of the terraform tasks to the SOCKS5 proxy of the tunnel. It takes precedence over httpProxy and httpsProxy.",
+							Type:        []string{"boolean"},
 							Format:      "",
 						},
 					},
-					"path": {
+					"endpoints": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Path will download the resources into this path which is relative to the main module directory.",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Endpoints are forwarded through the tunnel. Each endpoint's host resolves to a loopback address in the task pod where the tunnel listens on the endpoint's port, so providers connect to the usual host and port and TLS verification keeps working.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TunnelEndpoint"),
+									},
+								},
+							},
 						},
 					},
-					"useAsVar": {
+					"image": {
 						SchemaProps: spec.SchemaProps{
-							Description: "UseAsVar will add the file as a tfvar via the -var-file flag of the terraform plan command. The downloaded resource must not be a directory.",
-							Type:        []string{"boolean"},
+							Description: "Image of the sidecar, which needs a shell and an ssh client. Defaults to the setup image.",
+							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
 				},
-				Required: []string{"address"},
 			},
 		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TunnelEndpoint"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_SCMAuthMethod(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_PlanResult(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SCMAuthMethod definition of SCMs that require tokens/keys",
+				Description: "PlanResult is the number of changes the plan of a module found",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"host": {
+					"module": {
 						SchemaProps: spec.SchemaProps{
-							Default: "",
-							Type:    []string{"string"},
-							Format:  "",
+							Description: "Module is the path of the module relative to the terragrunt directory, `.` for the directory itself.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"git": {
+					"add": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Git configuration options for auth methods of git",
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM"),
+							Default: 0,
+							Type:    []string{"integer"},
+							Format:  "int32",
+						},
+					},
+					"change": {
+						SchemaProps: spec.SchemaProps{
+							Default: 0,
+							Type:    []string{"integer"},
+							Format:  "int32",
+						},
+					},
+					"destroy": {
+						SchemaProps: spec.SchemaProps{
+							Default: 0,
+							Type:    []string{"integer"},
+							Format:  "int32",
 						},
 					},
 				},
-				Required: []string{"host"},
+				Required: []string{"module", "add", "change", "destroy"},
 			},
 		},
-		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_SSHKeySecretRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_Plugin(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SSHKeySecretRef defines the secret where the SSH key (for the proxy, git, etc) is stored",
+				Description: "Plugin Define additional pods to run during a workflow",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"name": {
+					"image": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Name the secret name that has the SSH key",
+							Description: "The container image from the registry; tags must be omitted",
 							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"namespace": {
+					"imagePullPolicy": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Namespace of the secret; Default is the namespace of the terraform resource",
+							Description: "Image pull policy. One of Always, Never, IfNotPresent. Defaults to Always if :latest tag is specified, or IfNotPresent otherwise. Cannot be updated. More info: https://kubernetes.io/docs/concepts/containers/images#updating-images",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"key": {
+					"when": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Key in the secret ref. Default to `id_rsa`",
+							Description: "When is a keyword of a two-part selector of when the plugin gets run in the workflow. The value must be one of\n\n- <code>At</code> to run at the same time as the defined task\n\n- <code>After</code> to run after the defined task has completed.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"task": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Task is the second part of a two-part selector of when the plugin gets run in the workflow. This should correspond to one of the tfo task names.",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
 				},
-				Required: []string{"name"},
+				Required: []string{"image", "when", "task"},
 			},
 		},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_SecretNameRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_PluginCache(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "SecretNameRef is the name of the kubernetes secret to use",
+				Description: "PluginCache is mounted into the terraform tasks and TF_PLUGIN_CACHE_DIR points to it. The task holds the cache's lock file while it installs providers, since terraform does not support concurrent writes to the cache, and removes the provider versions that have not been used for the controller's `--plugin-cache-retention`. The retention is not set per resource since the resources share the cache.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"name": {
+					"scope": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Name of the secret",
-							Default:     "",
+							Description: "Scope is `Namespace` (default) or `Cluster`. The namespace cache is a ReadWriteMany PersistentVolumeClaim, `tfo-plugin-cache`, created by the controller in the resource's namespace. The cluster cache is the controller-wide volume (`--plugin-cache-volume-file`), eg a nfs share.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"namespace": {
+					"storageClassName": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Namespace of the secret; Defaults to namespace of the tf resource",
+							Description: "StorageClassName of the namespace cache's claim. The class must support ReadWriteMany. Only used when the controller creates the claim.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"key": {
+					"size": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Key of the secret",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Size of the namespace cache's claim. Defaults to \"10Gi\". Only used when the controller creates the claim.",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
 						},
 					},
 				},
-				Required: []string{"name"},
 			},
 		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_Setup(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_ProviderInstallation(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "Setup are things that only happen during the life of the setup task.",
+				Description: "ProviderInstallation is the `provider_installation` block. The methods are tried in order: the filesystem mirrors, the network mirrors and direct installation.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"resourceDownloads": {
+					"filesystemMirrors": {
 						SchemaProps: spec.SchemaProps{
-							Description: "ResourceDownloads defines other files to download into the module directory that can be used by the terraform workflow runners. The `tfvar` type will also be fetched by the `exportRepo` option (if defined) to aggregate the set of tfvars to save to an scm system.",
+							Description: "FilesystemMirrors are directories of the task image, or mounted into it, with the providers.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload"),
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.FilesystemMirror"),
 									},
 								},
 							},
 						},
 					},
-					"cleanupDisk": {
+					"networkMirrors": {
 						SchemaProps: spec.SchemaProps{
-							Description: "CleanupDisk will clear out previous terraform run data from the persistent volume.",
-							Type:        []string{"boolean"},
-							Format:      "",
+							Description: "NetworkMirrors are https servers that implement the provider network mirror protocol.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkMirror"),
+									},
+								},
+							},
+						},
+					},
+					"direct": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Direct installs providers from their origin registries. When nil and mirrors are defined, providers are only installed from the mirrors.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallationMethod"),
 						},
 					},
 				},
 			},
 		},
 		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload"},
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.FilesystemMirror", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.NetworkMirror", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProviderInstallationMethod"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_Stage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_ProviderInstallationMethod(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "Stage is the current task of the workflow.",
+				Description: "ProviderInstallationMethod selects the providers of an installation method, eg `registry.terraform.io/hashicorp/*`. When include is empty, every provider is included.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"generation": {
-						SchemaProps: spec.SchemaProps{
-							Description: "Generation is the generation of the resource when the task got started.",
-							Default:     0,
-							Type:        []string{"integer"},
-							Format:      "int64",
-						},
-					},
-					"state": {
+					"include": {
 						SchemaProps: spec.SchemaProps{
-							Description: "State is the phase of the task pod.",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"podType": {
+					"exclude": {
 						SchemaProps: spec.SchemaProps{
-							Description: "TaskType is which task is currently running.",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"interruptible": {
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_ProxyOpts(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ProxyOpts configures ssh tunnel/socks5 for downloading ssh/https resources",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"host": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Interruptible is set to false when the pod should not be terminated such as when doing a terraform apply.",
-							Default:     false,
-							Type:        []string{"boolean"},
-							Format:      "",
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"reason": {
+					"user": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Reason is a message of what is happening with the pod. The controller uses this field when certain reasons occur to make scheduling decisions.",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Type:   []string{"string"},
+							Format: "",
 						},
 					},
-					"startTime": {
+					"sshKeySecretRef": {
 						SchemaProps: spec.SchemaProps{
-							Description: "StartTime is when the task got created by the controller, not when a pod got started.",
-							Default:     map[string]interface{}{},
-							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+							Default: map[string]interface{}{},
+							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef"),
 						},
 					},
-					"stopTime": {
-						SchemaProps: spec.SchemaProps{
-							Description: "StopTime is when the task went into a stopped phase.",
-							Default:     map[string]interface{}{},
-							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+				},
+				Required: []string{"sshKeySecretRef"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SSHKeySecretRef"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_RegistrySCM(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RegistrySCM defines the auth method of a terraform registry",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"tokenSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef"),
+						},
+					},
+				},
+				Required: []string{"tokenSecretRef"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TokenSecretRef"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_ResourceDownload(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ResourceDownload (formerly SrcOpts) defines a resource to fetch using one of the configured protocols: ssh|http|https (eg git::SSH or git::HTTPS)",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"address": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Address defines the source address resources to fetch. Along with git repos and the other go-getter sources, the supported addresses are:\n\n- http(s) urls of files and archives, eg `https://example.com/vpc.tar.gz` - S3 objects, eg `s3://<bucket>/<key>` or `s3::https://<endpoint>/<bucket>/<key>` - GCS objects, eg `gs://<bucket>/<object>` - OCI artifacts, eg `oci://<registry>/<repository>:<tag>` - ConfigMaps and Secrets in the namespace of the resource, eg `configmap::<name>[/<key>]` or\n  `secret::<name>[/<key>]`. All the keys are downloaded when the key is not given.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum is verified before the downloaded files are put into the main module directory. Archives are verified before they are extracted. The format is `<type>:<hex>` where type is one of md5, sha1, sha256 or sha512, eg `sha256:2ef7bde6...`. Only files and archives can be verified, the controller rejects a checksum for a git repo, an OCI artifact or a ConfigMap or Secret without a key.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"path": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Path will download the resources into this path which is relative to the main module directory.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"useAsVar": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UseAsVar will add the file as a tfvar via the -var-file flag of the terraform plan command. The downloaded resource must not be a directory.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"address"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_SCMAuthMethod(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SCMAuthMethod definition of SCMs that require tokens/keys",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"host": {
+						SchemaProps: spec.SchemaProps{
+							Default: "",
+							Type:    []string{"string"},
+							Format:  "",
+						},
+					},
+					"git": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Git configuration options for auth methods of git",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM"),
+						},
+					},
+					"registry": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Registry configures the token of a private terraform module registry on the host. The token is used to resolve registry modules and is passed to the tasks as `TF_TOKEN_<host>`.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.RegistrySCM"),
+						},
+					},
+				},
+				Required: []string{"host"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.GitSCM", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.RegistrySCM"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_SSHKeySecretRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SSHKeySecretRef defines the secret where the SSH key (for the proxy, git, etc) is stored",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name the secret name that has the SSH key",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"namespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Namespace of the secret; Default is the namespace of the terraform resource",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"key": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Key in the secret ref. Default to `id_rsa`",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_SecretNameRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SecretNameRef is the name of the kubernetes secret to use",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Name of the secret",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"namespace": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Namespace of the secret; Defaults to namespace of the tf resource",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"key": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Key of the secret",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Setup(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Setup are things that only happen during the life of the setup task.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"resourceDownloads": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ResourceDownloads defines other files to download into the module directory that can be used by the terraform workflow runners. The `tfvar` type will also be fetched by the `exportRepo` option (if defined) to aggregate the set of tfvars to save to an scm system.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload"),
+									},
+								},
+							},
+						},
+					},
+					"cleanupDisk": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CleanupDisk will clear out previous terraform run data from the persistent volume.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ResourceDownload"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Stage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Stage is the current task of the workflow.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"generation": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Generation is the generation of the resource when the task got started.",
+							Default:     0,
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"state": {
+						SchemaProps: spec.SchemaProps{
+							Description: "State is the phase of the task pod.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"podType": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TaskType is which task is currently running.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"interruptible": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Interruptible is set to false when the pod should not be terminated such as when doing a terraform apply.",
+							Default:     false,
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"reason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reason is a message of what is happening with the pod. The controller uses this field when certain reasons occur to make scheduling decisions.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"startTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StartTime is when the task got created by the controller, not when a pod got started.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"stopTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StopTime is when the task went into a stopped phase.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Message stores the last message displayed in the logs. It is stored and checked by the controller to reduce the noise in the logs by only displying the message once.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"podName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PodName is the pod assigned to execute the stage.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"exitCode": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ExitCode is the exit code of the task container once it has terminated.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"terminationReason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TerminationReason is the reason the task container or pod terminated, such as `Error`, `OOMKilled` or `DeadlineExceeded`.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"errorMarker": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ErrorMarker is the content the task wrote to its error marker file (`TFO_ERROR_MARKER_FILE`) before failing. Sensitive values are masked.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"logTail": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LogTail is the last lines of the task log captured when the task failed. Sensitive values are masked.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"generation", "state", "podType", "interruptible", "reason"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_StageScript(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "StageScript defines the different ways of sourcing execution scripts of tasks. There is an order of precendence of selecting which source is used, which is:\n    1. inline\n    2. configMapSelector\n    3. source",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"source": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Source is an http source that the task container will fetch and then execute. When the tasks do not have a script, the default scripts bundled with the operator are used.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"checksum": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Checksum pins the content of the source, eg `sha256:<hex>`. The controller downloads the source when the generation starts and fails the run when the content does not match. The tasks run the verified content instead of fetching the source.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"configMapSelector": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConfigMapSelector reads a in a script from a configmap name+key",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector"),
+						},
+					},
+					"inline": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Inline is used to write the entire task execution script in the tfo resource.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_StateEncryption(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "StateEncryption selects the encryption config of OpenTofu",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"configSecretRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConfigSecretRef selects a key of a Secret, in the namespace of the resource, with the HCL or JSON `encryption` block, eg the key provider and its passphrase. It is given to the tasks as TF_ENCRYPTION.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
+						},
+					},
+				},
+				Required: []string{"configSecretRef"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.SecretKeySelector"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_TaskOption(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TaskOption are different configuration options to be injected into task pods. Can apply to one ore more task pods.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"affects": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Affects is a list of tasks these options will get applied to.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"policyRules": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RunnerRules are RBAC rules that will be added to all runner pods.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/api/rbac/v1.PolicyRule"),
+									},
+								},
+							},
+						},
+					},
+					"labels": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Labels extra labels to add task pods.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"annotations": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Annotaitons extra annotaitons to add the task pods",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"envFrom": {
+						SchemaProps: spec.SchemaProps{
+							Description: "List of sources to populate environment variables in the container. The keys defined within a source must be a C_IDENTIFIER. All invalid keys will be reported as an event when the container is starting. When a key exists in multiple sources, the value associated with the last source will take precedence. Values defined by an Env with a duplicate key will take precedence. Cannot be updated.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/api/core/v1.EnvFromSource"),
+									},
+								},
+							},
 						},
 					},
-					"message": {
+					"env": {
+						VendorExtensible: spec.VendorExtensible{
+							Extensions: spec.Extensions{
+								"x-kubernetes-patch-merge-key": "name",
+								"x-kubernetes-patch-strategy":  "merge",
+							},
+						},
 						SchemaProps: spec.SchemaProps{
-							Description: "Message stores the last message displayed in the logs. It is stored and checked by the controller to reduce the noise in the logs by only displying the message once.",
+							Description: "List of environment variables to set in the task pods.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/api/core/v1.EnvVar"),
+									},
+								},
+							},
+						},
+					},
+					"resources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Compute Resources required by the task pods.",
+							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
+						},
+					},
+					"script": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Script is used to configure the source of the task's executable script.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript"),
+						},
+					},
+					"timeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Timeout is the maximum duration the task pod is allowed to be active. It is enforced by setting the pod's activeDeadlineSeconds. When the deadline is exceeded the pod fails with the reason `DeadlineExceeded`. When more than one taskOption defines a timeout for a task, the last one wins.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+					"pendingTimeout": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PendingTimeout is the maximum duration a task pod can be in the `Pending` phase, for example when the image cannot be pulled or the pod cannot be scheduled. Once exceeded, the stage is failed and the cause is copied into the stage message. Defaults to the controller's `--task-pending-timeout`. Set to \"0s\" to disable.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Duration"),
+						},
+					},
+				},
+				Required: []string{"affects"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/rbac/v1.PolicyRule", "k8s.io/apimachinery/pkg/apis/meta/v1.Duration"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Terraform(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Terraform is the Schema for the terraforms API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Default: map[string]interface{}{},
+							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TerraformSpec defines the desired state of Terraform",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"keepLatestPodsOnly": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KeepLatestPodsOnly when true will keep only the pods that match the current generation of the terraform k8s-resource. This overrides the behavior of `keepCompletedPods`.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"keepCompletedPods": {
+						SchemaProps: spec.SchemaProps{
+							Description: "KeepCompletedPods when true will keep completed pods. Default is false and completed pods are removed.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"outputsSecret": {
+						SchemaProps: spec.SchemaProps{
+							Description: "OutputsSecret will create a secret with the outputs from the module. All outputs from the module will be written to the secret unless the user defines \"outputsToInclude\" or \"outputsToOmit\".",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"outputsToInclude": {
+						SchemaProps: spec.SchemaProps{
+							Description: "OutputsToInclude is a whitelist of outputs to write when writing the outputs to kubernetes.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"outputsToOmit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "OutputsToOmit is a blacklist of outputs to omit when writing the outputs to kubernetes.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"writeOutputsToStatus": {
+						SchemaProps: spec.SchemaProps{
+							Description: "WriteOutputsToStatus will add the outputs from the module to the status of the Terraform CustomResource.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"persistentVolumeSize": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PersistentVolumeSize define the size of the disk used to store terraform run data. If not defined, a default of \"2Gi\" is used.",
+							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+						},
+					},
+					"pluginCache": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PluginCache shares the providers downloaded by `terraform init` between the generations of the resource and, depending on the scope, other resources. When not defined, every generation downloads its providers.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PluginCache"),
+						},
+					},
+					"serviceAccount": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ServiceAccount use a specific kubernetes ServiceAccount for running the create + destroy pods. If not specified we create a new ServiceAccount per Terraform",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"credentials": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Credentials is an array of credentials generally used for Terraform providers",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials"),
+									},
+								},
+							},
+						},
+					},
+					"ignoreDelete": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IgnoreDelete will bypass the finalization process and remove the tf resource without running any delete jobs.",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"sshTunnel": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SSHTunnel can be defined for pulling from scm sources that cannot be accessed by the network the operator/runner runs in. An example is enterprise-Github servers running on a private network.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts"),
+						},
+					},
+					"trustedCA": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TrustedCA adds PEM encoded CA certificates, eg of an internal git server, registry mirror or cloud endpoint, to the certificates trusted by the tasks and by the controller's git and registry clients. The certificates are added to the controller-wide default (`--trusted-ca-file`) and the system roots.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TrustedCA"),
+						},
+					},
+					"cliConfig": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CLIConfig renders the `.terraformrc` of the tasks, eg to install providers from mirrors in air-gapped clusters. It is merged over the controller-wide default (`--cli-config-file`).",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLIConfig"),
+						},
+					},
+					"network": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Network configures how the tasks reach endpoints, eg providers' APIs that are only reachable through the ssh tunnel or a corporate proxy.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Network"),
+						},
+					},
+					"scmAuthMethods": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SCMAuthMethods define multiple SCMs that require tokens/keys",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod"),
+									},
+								},
+							},
+						},
+					},
+					"images": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Images describes the container images used by task classes.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images"),
+						},
+					},
+					"setup": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Setup is configuration generally used once in the setup task",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup"),
+						},
+					},
+					"terraformModule": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TerraformModule is used to configure the source of the terraform module.",
+							Default:     map[string]interface{}{},
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module"),
+						},
+					},
+					"terraformVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TerraformVersion is the version of terraform which is used to run the module. The terraform version is used as the tag of the terraform image  regardless if images.terraform.image is defined with a tag. In that case, the tag is stripped and replace with this value. The opentofu engine uses engine.version instead. Without a version, the tag of images.terraform.image is the version, and `latest` when the image has no tag.\n\nA version constraint, eg `~> 1.5`, is resolved to the newest available version when the generation starts. The available versions are configured in the controller. See status.resolvedEngineVersion.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"podName": {
+					"engine": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Engine selects the tool, terraform or opentofu, that runs the module. Resources without an engine use terraform at terraformVersion.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Engine"),
+						},
+					},
+					"terragrunt": {
 						SchemaProps: spec.SchemaProps{
-							Description: "PodName is the pod assigned to execute the stage.",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Terragrunt runs the terraform tasks with terragrunt instead of running the engine directly. The image of the terraform tasks must provide terragrunt.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Terragrunt"),
 						},
 					},
-				},
-				Required: []string{"generation", "state", "podType", "interruptible", "reason"},
-			},
-		},
-		Dependencies: []string{
-			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
-	}
-}
-
-func schema_pkg_apis_tf_v1alpha2_StageScript(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "StageScript defines the different ways of sourcing execution scripts of tasks. There is an order of precendence of selecting which source is used, which is:\n    1. inline\n    2. configMapSelector\n    3. source",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"source": {
+					"backend": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Source is an http source that the task container will fetch and then execute.",
+							Description: "Backend is mandatory terraform backend configuration. Must use a valid terraform backend block. For more information see https://www.terraform.io/language/settings/backends/configuration\n\nExample usage of the kubernetes cluster as a backend:\n\n```hcl\n  terraform {\n   backend \"kubernetes\" {\n    secret_suffix     = \"all-task-types\"\n    namespace         = \"default\"\n    in_cluster_config = true\n   }\n  }\n```\n\nExample of a remote backend:\n\n```hcl\n  terraform {\n   backend \"remote\" {\n    organization = \"example_corp\"\n    workspaces {\n      name = \"my-app-prod\"\n    }\n   }\n  }\n```\n\nUsage of the kubernetes backend is only available as of terraform v0.13+.",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"configMapSelector": {
+					"workspace": {
 						SchemaProps: spec.SchemaProps{
-							Description: "ConfigMapSelector reads a in a script from a configmap name+key",
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector"),
+							Description: "Workspace is the terraform workspace the terraform tasks select with TF_WORKSPACE. The workspace is created by the init task when it does not exist, so the backend must support workspaces, and is deleted once the delete workflow has destroyed its resources. Requires terraform 1.4 or later.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"inline": {
+					"mode": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Inline is used to write the entire task execution script in the tfo resource.",
+							Description: "Mode selects what the workflow does with the plan. The `apply` mode applies it. The `plan-only` mode stops after the plan and the post-plan task so the resource previews the changes. The `refresh-only` mode plans with `-refresh-only` and applies the plan, which only updates the state to match the infrastructure. The delete workflow of the plan-only and refresh-only modes plans the destroy without applying it, unless the resource has been applied in the apply mode before, which status.applied tells. status.result shows what the last completed workflow did.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-				},
-			},
-		},
-		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ConfigMapSelector"},
-	}
-}
-
-func schema_pkg_apis_tf_v1alpha2_TaskOption(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "TaskOption are different configuration options to be injected into task pods. Can apply to one ore more task pods.",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"affects": {
+					"taskOptions": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Affects is a list of tasks these options will get applied to.",
+							Description: "TaskOptions are a list of configuration options to be injected into task pods.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
-										Default: "",
-										Type:    []string{"string"},
-										Format:  "",
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption"),
 									},
 								},
 							},
 						},
 					},
-					"policyRules": {
+					"priority": {
 						SchemaProps: spec.SchemaProps{
-							Description: "RunnerRules are RBAC rules that will be added to all runner pods.",
+							Description: "Priority is used to order the run queue when the controller's concurrent workflow limits have been reached. Workflows with a higher priority start first. Workflows with the same priority start in the order they were queued.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"concurrencyGroup": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConcurrencyGroup is a key shared by resources that must not run at the same time, for example resources that use the same backend state or cloud account. Only one workflow in the group runs at a time, across all namespaces. The others wait in the `queued` phase.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"dependsOn": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DependsOn is a list of other Terraform resources that must complete their current generation before this workflow starts. Outputs of the dependencies can be passed into this module as input variables. When this resource is deleted, the destroy workflow waits until no other resource depends on it so stacks are destroyed in the reverse order they were created.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Default: map[string]interface{}{},
-										Ref:     ref("k8s.io/api/rbac/v1.PolicyRule"),
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Dependency"),
 									},
 								},
 							},
 						},
 					},
-					"labels": {
+					"variables": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Labels extra labels to add task pods.",
-							Type:        []string{"object"},
-							AdditionalProperties: &spec.SchemaOrBool{
-								Allows: true,
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: "",
-										Type:    []string{"string"},
-										Format:  "",
-									},
-								},
-							},
+							Description: "Variables are the input variables of the module. The values are read when a generation starts and are rendered into the generation's addons so every generation runs with the same inputs, even if the sources change afterwards.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variables"),
 						},
 					},
-					"annotations": {
+					"watchInputs": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Annotaitons extra annotaitons to add the task pods",
-							Type:        []string{"object"},
-							AdditionalProperties: &spec.SchemaOrBool{
-								Allows: true,
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: "",
-										Type:    []string{"string"},
-										Format:  "",
-									},
-								},
-							},
+							Description: "WatchInputs when true will start a new run of the workflow when the content of a ConfigMap or Secret referenced by the resource changes. This includes the module's ConfigMap, `envFrom` and `env` references of task options, credentials, scm auth secrets and variables. The workflow is restarted from the setup task once the current run is finished.",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
-					"envFrom": {
+					"exportRepo": {
 						SchemaProps: spec.SchemaProps{
-							Description: "List of sources to populate environment variables in the container. The keys defined within a source must be a C_IDENTIFIER. All invalid keys will be reported as an event when the container is starting. When a key exists in multiple sources, the value associated with the last source will take precedence. Values defined by an Env with a duplicate key will take precedence. Cannot be updated.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("k8s.io/api/core/v1.EnvFromSource"),
-									},
-								},
-							},
+							Description: "ExportRepo commits the configuration of each generation to a git repo after the plan task succeeds. The rendered variables, the backend and a summary of the plan are exported so the module can be run outside of the operator.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ExportRepo"),
 						},
 					},
-					"env": {
-						VendorExtensible: spec.VendorExtensible{
-							Extensions: spec.Extensions{
-								"x-kubernetes-patch-merge-key": "name",
-								"x-kubernetes-patch-strategy":  "merge",
-							},
-						},
+					"plugins": {
 						SchemaProps: spec.SchemaProps{
-							Description: "List of environment variables to set in the task pods.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
+							Description: "Plugins are tasks that run during a workflow but are not part of the main workflow. Plugins can be treated as just another task, however, plugins do not have completion or failure detection.\n\nExample definition of a plugin:\n\n```yaml\n  plugins:\n    monitor:\n      image: ghcr.io/galleybytes/monitor:latest\n      imagePullPolicy: IfNotPresent\n      when: After\n      task: setup\n```\n\nThe above plugin task will run after the setup task has completed.\n\nAlternatively, a plugin can be triggered to start at the same time of another task. For example:\n\n```yaml\n  plugins:\n    monitor:\n      image: ghcr.io/galleybytes/monitor:latest\n      imagePullPolicy: IfNotPresent\n      when: At\n      task: setup\n```\n\nEach plugin is run once per generation. Plugins that are older than the current generation are automatically reaped.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Default: map[string]interface{}{},
-										Ref:     ref("k8s.io/api/core/v1.EnvVar"),
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin"),
 									},
 								},
 							},
 						},
 					},
-					"resources": {
-						SchemaProps: spec.SchemaProps{
-							Description: "Compute Resources required by the task pods.",
-							Ref:         ref("k8s.io/api/core/v1.ResourceRequirements"),
-						},
-					},
-					"script": {
-						SchemaProps: spec.SchemaProps{
-							Description: "Script is used to configure the source of the task's executable script.",
-							Default:     map[string]interface{}{},
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript"),
-						},
-					},
 				},
-				Required: []string{"affects"},
+				Required: []string{"terraformModule", "backend"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.StageScript", "k8s.io/api/core/v1.EnvFromSource", "k8s.io/api/core/v1.EnvVar", "k8s.io/api/core/v1.ResourceRequirements", "k8s.io/api/rbac/v1.PolicyRule"},
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.CLIConfig", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Dependency", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Engine", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ExportRepo", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Network", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PluginCache", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Terragrunt", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TrustedCA", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variables", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_Terraform(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_TerraformStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "Terraform is the Schema for the terraforms API",
+				Description: "TerraformStatus defines the observed state of Terraform",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"kind": {
+					"podNamePrefix": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Description: "PodNamePrefix is used to identify this installation of the resource. For very long resource names, like those greater than 220 characters, the prefix ensures resource uniqueness for runners and other resources used by the runner. Another case for the pod name prefix is when rapidly deleteing a resource and recreating it, the chance of recycling existing resources is reduced to virtually nil.",
+							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"apiVersion": {
+					"phase": {
 						SchemaProps: spec.SchemaProps{
-							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
-							Type:        []string{"string"},
-							Format:      "",
+							Default: "",
+							Type:    []string{"string"},
+							Format:  "",
 						},
 					},
-					"metadata": {
+					"lastCompletedGeneration": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+							Default: 0,
+							Type:    []string{"integer"},
+							Format:  "int64",
 						},
 					},
-					"spec": {
+					"outputs": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec"),
+							Type: []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"status": {
+					"stages": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"),
+									},
+								},
+							},
+						},
+					},
+					"stage": {
 						SchemaProps: spec.SchemaProps{
 							Default: map[string]interface{}{},
-							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus"),
+							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"),
 						},
 					},
-				},
-			},
-		},
-		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformSpec", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TerraformStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
-	}
-}
-
-func schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
-	return common.OpenAPIDefinition{
-		Schema: spec.Schema{
-			SchemaProps: spec.SchemaProps{
-				Description: "TerraformSpec defines the desired state of Terraform",
-				Type:        []string{"object"},
-				Properties: map[string]spec.Schema{
-					"keepLatestPodsOnly": {
+					"plugins": {
 						SchemaProps: spec.SchemaProps{
-							Description: "KeepLatestPodsOnly when true will keep only the pods that match the current generation of the terraform k8s-resource. This overrides the behavior of `keepCompletedPods`.",
-							Type:        []string{"boolean"},
-							Format:      "",
+							Description: "Plugins is a list of plugins that have been executed by the controller. Will get refreshed each generation.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
 						},
 					},
-					"keepCompletedPods": {
+					"queued": {
 						SchemaProps: spec.SchemaProps{
-							Description: "KeepCompletedPods when true will keep completed pods. Default is false and completed pods are removed.",
+							Description: "Queued is true while the workflow waits on its dependencies, its concurrency group or the run queue. Create workflows are in the `queued` phase while they wait and delete workflows keep the `initializing-delete` phase.",
 							Type:        []string{"boolean"},
 							Format:      "",
 						},
 					},
-					"outputsSecret": {
+					"queuePosition": {
 						SchemaProps: spec.SchemaProps{
-							Description: "OutputsSecret will create a secret with the outputs from the module. All outputs from the module will be written to the secret unless the user defines \"outputsToInclude\" or \"outputsToOmit\".",
+							Description: "QueuePosition is the position of the workflow in the run queue while it is queued.",
+							Type:        []string{"integer"},
+							Format:      "int32",
+						},
+					},
+					"concurrencyGroupHolder": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ConcurrencyGroupHolder is the resource, as `namespace/name`, that currently holds the concurrency group.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"outputsToInclude": {
+					"waitingOn": {
 						SchemaProps: spec.SchemaProps{
-							Description: "OutputsToInclude is a whitelist of outputs to write when writing the outputs to kubernetes.",
+							Description: "WaitingOn is a list of resources, as `namespace/name`, the workflow is waiting on. These are the dependencies that have not completed, or when the resource is being deleted, the resources that still depend on it.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
@@ -923,11 +2029,12 @@ func schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref common.ReferenceCallback) com
 							},
 						},
 					},
-					"outputsToOmit": {
+					"inputHashes": {
 						SchemaProps: spec.SchemaProps{
-							Description: "OutputsToOmit is a blacklist of outputs to omit when writing the outputs to kubernetes.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
+							Description: "InputHashes are the sha256 hashes of the ConfigMaps and Secrets used by the current run keyed by `<kind>/<namespace>/<name>`. Comparing hashes shows which input changed when a run starts because of `watchInputs`.",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Default: "",
@@ -938,259 +2045,337 @@ func schema_pkg_apis_tf_v1alpha2_TerraformSpec(ref common.ReferenceCallback) com
 							},
 						},
 					},
-					"writeOutputsToStatus": {
+					"resolvedCommit": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ResolvedCommit is the commit sha the git source's ref pointed to when the current run started.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"lastGitPush": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LastGitPush is the `<ref>@<commit>` of the last git push received by the git webhook that was run.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"resolvedModuleVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ResolvedModuleVersion is the version of the registry module selected when the current generation started.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"resolvedEngineVersion": {
 						SchemaProps: spec.SchemaProps{
-							Description: "WriteOutputsToStatus will add the outputs from the module to the status of the Terraform CustomResource.",
-							Type:        []string{"boolean"},
+							Description: "ResolvedEngineVersion is the version of the engine the current generation runs. Version constraints and `latest` are resolved to a version of the controller's list of available versions when the generation starts.",
+							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"persistentVolumeSize": {
+					"exportedGeneration": {
 						SchemaProps: spec.SchemaProps{
-							Description: "PersistentVolumeSize define the size of the disk used to store terraform run data. If not defined, a default of \"2Gi\" is used.",
-							Ref:         ref("k8s.io/apimachinery/pkg/api/resource.Quantity"),
+							Description: "ExportedGeneration is the last generation exported to the `exportRepo`.",
+							Type:        []string{"integer"},
+							Format:      "int64",
 						},
 					},
-					"serviceAccount": {
+					"exportedCommit": {
 						SchemaProps: spec.SchemaProps{
-							Description: "ServiceAccount use a specific kubernetes ServiceAccount for running the create + destroy pods. If not specified we create a new ServiceAccount per Terraform",
+							Description: "ExportedCommit is the commit sha of the last export to the `exportRepo`.",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"credentials": {
+					"planResults": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Credentials is an array of credentials generally used for Terraform providers",
+							Description: "PlanResults are the changes planned for each module by the last completed plan of a terragrunt resource or of a resource in the plan-only or refresh-only mode.",
 							Type:        []string{"array"},
 							Items: &spec.SchemaOrArray{
 								Schema: &spec.Schema{
 									SchemaProps: spec.SchemaProps{
 										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials"),
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PlanResult"),
 									},
 								},
 							},
 						},
 					},
-					"ignoreDelete": {
+					"result": {
 						SchemaProps: spec.SchemaProps{
-							Description: "IgnoreDelete will bypass the finalization process and remove the tf resource without running any delete jobs.",
-							Type:        []string{"boolean"},
+							Description: "Result is what the last completed workflow did. It is `Applied` in the apply mode. `Planned` and `Refreshed` tell that the plan-only and refresh-only workflows did not change the infrastructure.",
+							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"sshTunnel": {
+					"applied": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SSHTunnel can be defined for pulling from scm sources that cannot be accessed by the network the operator/runner runs in. An example is enterprise-Github servers running on a private network.",
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts"),
+							Description: "Applied is true once an apply task of the apply mode has started. The delete workflow of an applied resource applies the destroy plan whatever the mode so the infrastructure is not orphaned when the mode is changed to plan-only or refresh-only.",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
-					"scmAuthMethods": {
+				},
+				Required: []string{"podNamePrefix", "phase", "lastCompletedGeneration", "stages", "stage"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.PlanResult", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Terragrunt(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Terragrunt configures how the terraform tasks run terragrunt",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"version": {
 						SchemaProps: spec.SchemaProps{
-							Description: "SCMAuthMethods define multiple SCMs that require tokens/keys",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod"),
-									},
-								},
-							},
+							Description: "Version of terragrunt. The tasks fail when the terragrunt of the image is a different version.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"images": {
+					"dir": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Images describes the container images used by task classes.",
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images"),
+							Description: "Dir is the directory, relative to the main module, terragrunt runs in. Defaults to the main module.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"setup": {
+					"configPath": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Setup is configuration generally used once in the setup task",
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup"),
+							Description: "ConfigPath is the path, relative to dir, of the terragrunt config when it is not `terragrunt.hcl`. It can not be used with runAll since every module of the stack has its own config.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"terraformModule": {
+					"runAll": {
 						SchemaProps: spec.SchemaProps{
-							Description: "TerraformModule is used to configure the source of the terraform module.",
-							Default:     map[string]interface{}{},
-							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module"),
+							Description: "RunAll runs the commands with `run-all` for every terragrunt module under dir. The modules are destroyed with `run-all destroy` so they are removed in the reverse order of their dependencies and their outputs are not saved.",
+							Type:        []string{"boolean"},
+							Format:      "",
 						},
 					},
-					"terraformVersion": {
+				},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_TokenSecretRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TokenSecretRef defines the token or password that can be used to log into a system (eg git)",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Description: "TerraformVersion is the version of terraform which is used to run the module. The terraform version is used as the tag of the terraform image  regardless if images.terraform.image is defined with a tag. In that case, the tag is stripped and replace with this value.",
+							Description: "Name the secret name that has the token or password",
 							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"backend": {
+					"namespace": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Backend is mandatory terraform backend configuration. Must use a valid terraform backend block. For more information see https://www.terraform.io/language/settings/backends/configuration\n\nExample usage of the kubernetes cluster as a backend:\n\n```hcl\n  terraform {\n   backend \"kubernetes\" {\n    secret_suffix     = \"all-task-types\"\n    namespace         = \"default\"\n    in_cluster_config = true\n   }\n  }\n```\n\nExample of a remote backend:\n\n```hcl\n  terraform {\n   backend \"remote\" {\n    organization = \"example_corp\"\n    workspaces {\n      name = \"my-app-prod\"\n    }\n   }\n  }\n```\n\nUsage of the kubernetes backend is only available as of terraform v0.13+.",
-							Default:     "",
+							Description: "Namespace of the secret; Default is the namespace of the terraform resource",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"taskOptions": {
+					"key": {
 						SchemaProps: spec.SchemaProps{
-							Description: "TaskOptions are a list of configuration options to be injected into task pods.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption"),
-									},
-								},
-							},
+							Description: "Key in the secret ref. Default to `token`",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"plugins": {
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_TrustedCA(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "TrustedCA selects the ConfigMap and Secret keys with PEM encoded CA certificates. Both can be used. The bundle is mounted into every task and SSL_CERT_FILE and GIT_SSL_CAINFO point to it.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"configMapKeyRef": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Plugins are tasks that run during a workflow but are not part of the main workflow. Plugins can be treated as just another task, however, plugins do not have completion or failure detection.\n\nExample definition of a plugin:\n\n```yaml\n  plugins:\n    monitor:\n      image: ghcr.io/galleybytes/monitor:latest\n      imagePullPolicy: IfNotPresent\n      when: After\n      task: setup\n```\n\nThe above plugin task will run after the setup task has completed.\n\nAlternatively, a plugin can be triggered to start at the same time of another task. For example:\n\n```yaml\n  plugins:\n    monitor:\n      image: ghcr.io/galleybytes/monitor:latest\n      imagePullPolicy: IfNotPresent\n      when: At\n      task: setup\n```\n\nEach plugin is run once per generation. Plugins that are older than the current generation are automatically reaped.",
-							Type:        []string{"object"},
-							AdditionalProperties: &spec.SchemaOrBool{
-								Allows: true,
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin"),
-									},
-								},
-							},
+							Description: "ConfigMapKeyRef selects a key of a ConfigMap in the namespace of the resource.",
+							Ref:         ref("k8s.io/api/core/v1.ConfigMapKeySelector"),
+						},
+					},
+					"secretKeyRef": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SecretKeyRef selects a key of a Secret in the namespace of the resource.",
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 						},
 					},
 				},
-				Required: []string{"terraformModule", "terraformVersion", "backend"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Credentials", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Images", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Module", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Plugin", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.ProxyOpts", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.SCMAuthMethod", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Setup", "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.TaskOption", "k8s.io/apimachinery/pkg/api/resource.Quantity"},
+			"k8s.io/api/core/v1.ConfigMapKeySelector", "k8s.io/api/core/v1.SecretKeySelector"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_TerraformStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_TunnelEndpoint(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "TerraformStatus defines the observed state of Terraform",
+				Description: "TunnelEndpoint is a host and port forwarded through the ssh tunnel",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"podNamePrefix": {
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Description: "PodNamePrefix is used to identify this installation of the resource. For very long resource names, like those greater than 220 characters, the prefix ensures resource uniqueness for runners and other resources used by the runner. Another case for the pod name prefix is when rapidly deleteing a resource and recreating it, the chance of recycling existing resources is reduced to virtually nil.",
+							Description: "Name of the endpoint. The forwarded address is set as TFO_ENDPOINT_<NAME> in the terraform tasks.",
 							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
-					"phase": {
+					"host": {
 						SchemaProps: spec.SchemaProps{
-							Default: "",
-							Type:    []string{"string"},
-							Format:  "",
+							Description: "Host as resolved by the bastion, eg `vault.internal.example.com`.",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"lastCompletedGeneration": {
+					"port": {
 						SchemaProps: spec.SchemaProps{
-							Default: 0,
-							Type:    []string{"integer"},
-							Format:  "int64",
+							Description: "Port of the endpoint, eg `8200`.",
+							Default:     0,
+							Type:        []string{"integer"},
+							Format:      "int32",
 						},
 					},
-					"outputs": {
+				},
+				Required: []string{"name", "host", "port"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Variable(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Variable is a single input variable of the module.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"name": {
 						SchemaProps: spec.SchemaProps{
-							Type: []string{"object"},
-							AdditionalProperties: &spec.SchemaOrBool{
-								Allows: true,
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: "",
-										Type:    []string{"string"},
-										Format:  "",
-									},
-								},
-							},
+							Description: "Name of the input variable",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"stages": {
+					"value": {
 						SchemaProps: spec.SchemaProps{
-							Type: []string{"array"},
-							Items: &spec.SchemaOrArray{
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: map[string]interface{}{},
-										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"),
-									},
-								},
-							},
+							Description: "Value is the literal value of the variable.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"stage": {
+					"type": {
 						SchemaProps: spec.SchemaProps{
-							Default: map[string]interface{}{},
-							Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"),
+							Description: "Type describes how the value is written to the tfvars file. Can be `string` (default), which quotes the value, or `number`, `bool`, `hcl` or `json` which are written as is. Use `hcl` or `json` for lists, maps and objects, eg `[\"a\", \"b\"]` or `{\"key\": \"value\"}`.",
+							Type:        []string{"string"},
+							Format:      "",
 						},
 					},
-					"plugins": {
+					"valueFrom": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Plugins is a list of plugins that have been executed by the controller. Will get refreshed each generation.",
-							Type:        []string{"array"},
-							Items: &spec.SchemaOrArray{
-								Schema: &spec.Schema{
-									SchemaProps: spec.SchemaProps{
-										Default: "",
-										Type:    []string{"string"},
-										Format:  "",
-									},
-								},
-							},
+							Description: "ValueFrom reads the value from a ConfigMap or Secret key in the resource's namespace.",
+							Ref:         ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.VariableSource"),
 						},
 					},
 				},
-				Required: []string{"podNamePrefix", "phase", "lastCompletedGeneration", "stages", "stage"},
+				Required: []string{"name"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Stage"},
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.VariableSource"},
 	}
 }
 
-func schema_pkg_apis_tf_v1alpha2_TokenSecretRef(ref common.ReferenceCallback) common.OpenAPIDefinition {
+func schema_pkg_apis_tf_v1alpha2_VariableSource(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
-				Description: "TokenSecretRef defines the token or password that can be used to log into a system (eg git)",
+				Description: "VariableSource selects the source of a variable's value. Only one of the sources can be used.",
 				Type:        []string{"object"},
 				Properties: map[string]spec.Schema{
-					"name": {
+					"configMapKeyRef": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Name the secret name that has the token or password",
-							Default:     "",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "ConfigMapKeyRef selects a key of a ConfigMap.",
+							Ref:         ref("k8s.io/api/core/v1.ConfigMapKeySelector"),
 						},
 					},
-					"namespace": {
+					"secretKeyRef": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Namespace of the secret; Default is the namespace of the terraform resource",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "SecretKeyRef selects a key of a Secret.",
+							Ref:         ref("k8s.io/api/core/v1.SecretKeySelector"),
 						},
 					},
-					"key": {
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/api/core/v1.ConfigMapKeySelector", "k8s.io/api/core/v1.SecretKeySelector"},
+	}
+}
+
+func schema_pkg_apis_tf_v1alpha2_Variables(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "Variables defines the input variables of the module.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"values": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Key in the secret ref. Default to `token`",
-							Type:        []string{"string"},
-							Format:      "",
+							Description: "Values is a list of input variables. Values from ConfigMaps and literals are written to a generated `.auto.tfvars` file. Values from Secrets, and the outputs of dependencies, are written to a tfvars file in the generation's Secret so they are never written to a ConfigMap. That file is loaded last and takes precedence over every other variable file.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variable"),
+									},
+								},
+							},
+						},
+					},
+					"files": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Files are whole tfvars files read from ConfigMaps. Files are loaded in order, before `values`, so `values` take precedence.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("k8s.io/api/core/v1.ConfigMapKeySelector"),
+									},
+								},
+							},
 						},
 					},
 				},
-				Required: []string{"name"},
 			},
 		},
+		Dependencies: []string{
+			"github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2.Variable", "k8s.io/api/core/v1.ConfigMapKeySelector"},
 	}
 }
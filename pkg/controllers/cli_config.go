@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// cliConfigKey is the key of the rendered .terraformrc in the versioned secret
+	cliConfigKey = "terraformrc"
+
+	// cliConfigPath is where the .terraformrc is mounted in the task pods
+	cliConfigPath = "/tmp/tfo-cli-config"
+)
+
+// mergeCLIConfig merges the resource's cli config over the controller-wide default. The resource's
+// provider installation replaces the default's and its credentials replace the default's of the same host.
+func mergeCLIConfig(defaults, config *tfv1alpha2.CLIConfig) *tfv1alpha2.CLIConfig {
+	if defaults == nil || config == nil {
+		if config != nil {
+			return config
+		}
+		return defaults
+	}
+	merged := &tfv1alpha2.CLIConfig{ProviderInstallation: defaults.ProviderInstallation}
+	if config.ProviderInstallation != nil {
+		merged.ProviderInstallation = config.ProviderInstallation
+	}
+	hosts := map[string]bool{}
+	for _, credentials := range config.Credentials {
+		hosts[strings.ToLower(credentials.Host)] = true
+	}
+	for _, credentials := range defaults.Credentials {
+		if !hosts[strings.ToLower(credentials.Host)] {
+			merged.Credentials = append(merged.Credentials, credentials)
+		}
+	}
+	merged.Credentials = append(merged.Credentials, config.Credentials...)
+	return merged
+}
+
+// usesCLIConfig returns true when the tasks get a .terraformrc
+func (r ReconcileTerraform) usesCLIConfig(tf *tfv1alpha2.Terraform) bool {
+	return mergeCLIConfig(r.CLIConfig, tf.Spec.CLIConfig) != nil
+}
+
+// validateCLIConfig checks the cli config options that can not be checked by the CRD schema
+func validateCLIConfig(config *tfv1alpha2.CLIConfig) error {
+	if installation := config.ProviderInstallation; installation != nil {
+		for _, mirror := range installation.FilesystemMirrors {
+			if !path.IsAbs(mirror.Path) {
+				return fmt.Errorf("filesystem mirror path '%s' must be absolute", mirror.Path)
+			}
+		}
+		for _, mirror := range installation.NetworkMirrors {
+			u, err := url.Parse(mirror.URL)
+			if err != nil || u.Scheme != "https" || u.Host == "" || !strings.HasSuffix(u.Path, "/") {
+				return fmt.Errorf("network mirror url '%s' must be https and end with a '/'", mirror.URL)
+			}
+		}
+	}
+	for _, credentials := range config.Credentials {
+		if credentials.Host == "" || credentials.TokenSecretRef.Name == "" {
+			return fmt.Errorf("cli config credentials require a host and a tokenSecretRef")
+		}
+	}
+	return nil
+}
+
+// renderCLIConfig returns the .terraformrc of the tasks and the TF_TOKEN_<host> variables of its
+// credentials. Hosts with a port only get the credentials block because the variables can not express them.
+func (r ReconcileTerraform) renderCLIConfig(ctx context.Context, tf *tfv1alpha2.Terraform) ([]byte, map[string][]byte, error) {
+	config := mergeCLIConfig(r.CLIConfig, tf.Spec.CLIConfig)
+	if config == nil {
+		return nil, nil, nil
+	}
+	if err := validateCLIConfig(config); err != nil {
+		return nil, nil, err
+	}
+
+	var b strings.Builder
+	if installation := config.ProviderInstallation; installation != nil {
+		b.WriteString("provider_installation {\n")
+		for _, mirror := range installation.FilesystemMirrors {
+			writeCLIConfigMethod(&b, "filesystem_mirror", "path", mirror.Path, mirror.ProviderInstallationMethod)
+		}
+		for _, mirror := range installation.NetworkMirrors {
+			writeCLIConfigMethod(&b, "network_mirror", "url", mirror.URL, mirror.ProviderInstallationMethod)
+		}
+		if installation.Direct != nil {
+			writeCLIConfigMethod(&b, "direct", "", "", *installation.Direct)
+		}
+		b.WriteString("}\n")
+	}
+
+	tokenEnvs := make(map[string][]byte)
+	for _, credentials := range config.Credentials {
+		ref := credentials.TokenSecretRef
+		key := ref.Key
+		if key == "" {
+			key = "token"
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = tf.Namespace
+		}
+		token, err := loadPassword(ctx, r.Client, key, ref.Name, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		host := strings.ToLower(credentials.Host)
+		fmt.Fprintf(&b, "\ncredentials %s {\n  token = %s\n}\n", hclString(host), hclString(token))
+		if !strings.Contains(host, ":") {
+			tokenEnvs[tokenEnvName(host)] = []byte(token)
+		}
+	}
+	return []byte(b.String()), tokenEnvs, nil
+}
+
+// writeCLIConfigMethod writes an installation method block of the provider_installation block
+func writeCLIConfigMethod(b *strings.Builder, block, attribute, value string, method tfv1alpha2.ProviderInstallationMethod) {
+	fmt.Fprintf(b, "  %s {\n", block)
+	if attribute != "" {
+		fmt.Fprintf(b, "    %s = %s\n", attribute, hclString(value))
+	}
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{"include", method.Include},
+		{"exclude", method.Exclude},
+	} {
+		if len(list.values) == 0 {
+			continue
+		}
+		quoted := []string{}
+		for _, v := range list.values {
+			quoted = append(quoted, hclString(v))
+		}
+		fmt.Fprintf(b, "    %s = [%s]\n", list.name, strings.Join(quoted, ", "))
+	}
+	b.WriteString("  }\n")
+}
+
+// cliConfigVolume mounts the CLI config of the versioned secret and points the engine to it. OpenTofu also
+// reads TF_CLI_CONFIG_FILE.
+func (r TaskOptions) cliConfigVolume() (corev1.Volume, corev1.VolumeMount, corev1.EnvVar) {
+	volume := corev1.Volume{
+		Name: "cli-config",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: r.versionedName,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  cliConfigKey,
+						Path: cliConfigFileName(r.engine),
+					},
+				},
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      "cli-config",
+		MountPath: cliConfigPath,
+		ReadOnly:  true,
+	}
+	env := corev1.EnvVar{
+		Name:  "TF_CLI_CONFIG_FILE",
+		Value: cliConfigPath + "/" + cliConfigFileName(r.engine),
+	}
+	return volume, mount, env
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCLIConfigReconciler() ReconcileTerraform {
+	r := newTestReconciler(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tf-system", Name: "tfe"},
+			Data:       map[string][]byte{"token": []byte("tfe-token")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mirror"},
+			Data:       map[string][]byte{"token": []byte("mirror-token"), "old": []byte("old-token")},
+		},
+	)
+	r.CLIConfig = &tfv1alpha2.CLIConfig{
+		ProviderInstallation: &tfv1alpha2.ProviderInstallation{
+			Direct: &tfv1alpha2.ProviderInstallationMethod{},
+		},
+		Credentials: []tfv1alpha2.CLICredentials{
+			{Host: "app.terraform.io", TokenSecretRef: tfv1alpha2.TokenSecretRef{Name: "tfe", Namespace: "tf-system"}},
+			{Host: "mirror.example.com", TokenSecretRef: tfv1alpha2.TokenSecretRef{Name: "mirror", Key: "old"}},
+		},
+	}
+	return r
+}
+
+func newTestCLIConfigTerraform() *tfv1alpha2.Terraform {
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		CLIConfig: &tfv1alpha2.CLIConfig{
+			ProviderInstallation: &tfv1alpha2.ProviderInstallation{
+				FilesystemMirrors: []tfv1alpha2.FilesystemMirror{
+					{Path: "/usr/share/terraform/providers", ProviderInstallationMethod: tfv1alpha2.ProviderInstallationMethod{Include: []string{"example.com/*/*"}}},
+				},
+				NetworkMirrors: []tfv1alpha2.NetworkMirror{
+					{URL: "https://mirror.example.com/providers/", ProviderInstallationMethod: tfv1alpha2.ProviderInstallationMethod{Exclude: []string{"example.com/*/*"}}},
+				},
+			},
+			Credentials: []tfv1alpha2.CLICredentials{
+				{Host: "Mirror.example.com", TokenSecretRef: tfv1alpha2.TokenSecretRef{Name: "mirror"}},
+				{Host: "registry.example.com:8443", TokenSecretRef: tfv1alpha2.TokenSecretRef{Name: "mirror"}},
+			},
+		},
+	})
+}
+
+func TestCLIConfig(t *testing.T) {
+	r := newTestCLIConfigReconciler()
+	tf := newTestCLIConfigTerraform()
+	if !r.usesCLIConfig(tf) {
+		t.Error("expected the cli config to be used")
+	}
+	cliConfig, tokenEnvs, err := r.renderCLIConfig(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `provider_installation {
+  filesystem_mirror {
+    path = "/usr/share/terraform/providers"
+    include = ["example.com/*/*"]
+  }
+  network_mirror {
+    url = "https://mirror.example.com/providers/"
+    exclude = ["example.com/*/*"]
+  }
+}
+
+credentials "app.terraform.io" {
+  token = "tfe-token"
+}
+
+credentials "mirror.example.com" {
+  token = "mirror-token"
+}
+
+credentials "registry.example.com:8443" {
+  token = "mirror-token"
+}
+`
+	if string(cliConfig) != want {
+		t.Errorf("unexpected cli config:\n%s", cliConfig)
+	}
+	if len(tokenEnvs) != 2 || string(tokenEnvs["TF_TOKEN_app_terraform_io"]) != "tfe-token" || string(tokenEnvs["TF_TOKEN_mirror_example_com"]) != "mirror-token" {
+		t.Errorf("unexpected token envs %v", tokenEnvs)
+	}
+}
+
+func TestDefaultCLIConfig(t *testing.T) {
+	// Resources without a cli config get the default
+	defaultOnly := newTestTerraform(tfv1alpha2.TerraformSpec{})
+	cliConfig, _, err := newTestCLIConfigReconciler().renderCLIConfig(context.TODO(), defaultOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(cliConfig), "provider_installation {\n  direct {\n  }\n}\n") || !strings.Contains(string(cliConfig), `token = "old-token"`) {
+		t.Errorf("expected the default provider installation, got:\n%s", cliConfig)
+	}
+
+	// The TF_TOKEN_* variables of the controller-wide credentials reach resources without their own
+	// credentials
+	referenced := false
+	for _, envFrom := range taskContainer(defaultOnly, tfv1alpha2.RunInit).EnvFrom {
+		referenced = referenced || (envFrom.SecretRef != nil && envFrom.SecretRef.Name == "app-abc-v1-variables")
+	}
+	if !referenced {
+		t.Error("expected the tasks to get the token variables from the variables secret")
+	}
+
+	if (ReconcileTerraform{}).usesCLIConfig(defaultOnly) {
+		t.Error("expected no cli config")
+	}
+}
+
+func TestCLIConfigEscapesHCL(t *testing.T) {
+	r := newTestReconciler(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tfe"},
+		Data:       map[string][]byte{"token": []byte("t0k${en}%{if}\"\\")},
+	})
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		CLIConfig: &tfv1alpha2.CLIConfig{
+			ProviderInstallation: &tfv1alpha2.ProviderInstallation{
+				FilesystemMirrors: []tfv1alpha2.FilesystemMirror{
+					{Path: "/providers/${path}", ProviderInstallationMethod: tfv1alpha2.ProviderInstallationMethod{Include: []string{"example.com/%{org}/*"}}},
+				},
+			},
+			Credentials: []tfv1alpha2.CLICredentials{
+				{Host: "app.terraform.io", TokenSecretRef: tfv1alpha2.TokenSecretRef{Name: "tfe"}},
+			},
+		},
+	})
+	cliConfig, _, err := r.renderCLIConfig(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The template sequences are escaped so the values are read literally
+	for _, want := range []string{
+		`path = "/providers/$${path}"`,
+		`include = ["example.com/%%{org}/*"]`,
+		`token = "t0k$${en}%%{if}\"\\"`,
+	} {
+		if !strings.Contains(string(cliConfig), want) {
+			t.Errorf("expected the cli config to contain %s, got:\n%s", want, cliConfig)
+		}
+	}
+}
+
+func TestCLIConfigNetworkMirror(t *testing.T) {
+	tf := newTestCLIConfigTerraform()
+	tf.Spec.CLIConfig.ProviderInstallation.NetworkMirrors[0].URL = "http://mirror.example.com/providers"
+	if _, _, err := newTestCLIConfigReconciler().renderCLIConfig(context.TODO(), tf); err == nil {
+		t.Error("expected the network mirror url to be invalid")
+	}
+}
+
+func TestCLIConfigTaskPod(t *testing.T) {
+	runOpts := newTaskOptions(newTestCLIConfigTerraform(), tfv1alpha2.RunInit, 1, nil)
+	runOpts.cliConfig = true
+	runOpts.secretData = map[string][]byte{"config": []byte(""), cliConfigKey: []byte("provider_installation {}\n")}
+	pod := runOpts.generatePod()
+	if envs := podEnvs(pod.Spec.Containers[0]); envs["TF_CLI_CONFIG_FILE"] != "/tmp/tfo-cli-config/terraformrc" {
+		t.Errorf("expected TF_CLI_CONFIG_FILE, got %s", envs["TF_CLI_CONFIG_FILE"])
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == "ssh" {
+			for _, item := range volume.Secret.Items {
+				if item.Key == cliConfigKey {
+					t.Error("expected the cli config to not be mounted with the ssh config")
+				}
+			}
+		}
+	}
+}
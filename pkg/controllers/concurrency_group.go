@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const concurrencyGroupAnnotation = "terraforms.tf.isaaguilar.com/concurrencyGroup"
+
+// concurrencyGroupLeaseName returns a valid resource name for the group. The group key is hashed since
+// users can choose any string for the key.
+func concurrencyGroupLeaseName(group string) string {
+	return fmt.Sprintf("tfo-concurrency-group-%x", sha256.Sum256([]byte(group)))[:38]
+}
+
+func concurrencyGroupIdentity(tf *tfv1alpha2.Terraform) string {
+	return fmt.Sprintf("%s/%s", tf.Namespace, tf.Name)
+}
+
+// isBlockedByConcurrencyGroup returns true when the workflow is waiting on another resource in its group.
+func isBlockedByConcurrencyGroup(tf *tfv1alpha2.Terraform) bool {
+	holder := tf.Status.ConcurrencyGroupHolder
+	return holder != "" && holder != concurrencyGroupIdentity(tf)
+}
+
+// canHoldConcurrencyGroup returns true when the resource is still entitled to the group's lease, ie it is
+// still in the group and its workflow is either running or about to run.
+func canHoldConcurrencyGroup(tf *tfv1alpha2.Terraform, group string) bool {
+	if tf.Spec.ConcurrencyGroup != group {
+		return false
+	}
+	return isWorkflowActive(tf) || isWaitingToRun(tf)
+}
+
+// acquireConcurrencyGroup tries to take the lease of the resource's concurrency group and returns the
+// identity of the holder. The lease is taken over when the holder no longer exists or is no longer
+// running a workflow in the group. Updates use the lease's resourceVersion so only one controller replica
+// can win the lease.
+func (r ReconcileTerraform) acquireConcurrencyGroup(ctx context.Context, tf *tfv1alpha2.Terraform) (string, error) {
+	if r.Clientset == nil {
+		return "", fmt.Errorf("concurrency groups require the controller to be configured with a clientset")
+	}
+	group := tf.Spec.ConcurrencyGroup
+	identity := concurrencyGroupIdentity(tf)
+	leases := r.Clientset.CoordinationV1().Leases(r.ConcurrencyGroupNamespace)
+	now := metav1.NewMicroTime(time.Now())
+
+	lease, err := leases.Get(ctx, concurrencyGroupLeaseName(group), metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return "", err
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      concurrencyGroupLeaseName(group),
+				Namespace: r.ConcurrencyGroupNamespace,
+				Annotations: map[string]string{
+					concurrencyGroupAnnotation: group,
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity: &identity,
+				AcquireTime:    &now,
+				RenewTime:      &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if err != nil {
+			return "", err
+		}
+		r.Recorder.Event(tf, "Normal", "ConcurrencyGroupAcquired", fmt.Sprintf("Acquired concurrency group '%s'", group))
+		return identity, nil
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder == identity {
+		return identity, nil
+	}
+	if holder != "" && r.isConcurrencyGroupHolderValid(ctx, holder, group) {
+		return holder, nil
+	}
+
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	if holder != "" {
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return "", err
+	}
+	r.Recorder.Event(tf, "Normal", "ConcurrencyGroupAcquired", fmt.Sprintf("Acquired concurrency group '%s'", group))
+	return identity, nil
+}
+
+// waitForConcurrencyGroup takes the lease of the resource's concurrency group before the pod of a task is
+// created and returns true when another resource holds it. The lease is released when a stage fails, so a
+// workflow restarted after a failure has to take it again. Restarted workflows keep their phase while they
+// wait, which tells them apart from workflows that have not started.
+func (r ReconcileTerraform) waitForConcurrencyGroup(ctx context.Context, tf *tfv1alpha2.Terraform, logger logr.Logger) (bool, error) {
+	holder, err := r.acquireConcurrencyGroup(ctx, tf)
+	if err != nil {
+		return false, err
+	}
+	if holder == concurrencyGroupIdentity(tf) {
+		tf.Status.ConcurrencyGroupHolder = holder
+		return false, nil
+	}
+	queue := isWaitingToRun(tf) && !tf.Status.Queued
+	if tf.Status.ConcurrencyGroupHolder != holder {
+		r.Recorder.Event(tf, "Normal", "Queued", fmt.Sprintf("Waiting for '%s' which holds concurrency group '%s'", holder, tf.Spec.ConcurrencyGroup))
+	}
+	if queue || tf.Status.ConcurrencyGroupHolder != holder {
+		if queue {
+			setQueued(tf)
+		}
+		tf.Status.ConcurrencyGroupHolder = holder
+		err = r.updateStatusWithRetry(ctx, tf, &tf.Status, logger)
+		if err != nil {
+			logger.V(1).Info(err.Error())
+		}
+	}
+	return true, nil
+}
+
+// isConcurrencyGroupHolderValid checks that the holder, given as `namespace/name`, can still hold the group.
+func (r ReconcileTerraform) isConcurrencyGroupHolderValid(ctx context.Context, holder, group string) bool {
+	parts := strings.SplitN(holder, "/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	holderTf := &tfv1alpha2.Terraform{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[1]}, holderTf)
+	if err != nil {
+		// When the holder can't be found the lease is free to be taken over
+		return !errors.IsNotFound(err)
+	}
+	return canHoldConcurrencyGroup(holderTf, group)
+}
+
+// releaseConcurrencyGroup gives up the lease of the resource's concurrency group if the resource holds it.
+func (r ReconcileTerraform) releaseConcurrencyGroup(ctx context.Context, tf *tfv1alpha2.Terraform) error {
+	if r.Clientset == nil || tf.Spec.ConcurrencyGroup == "" {
+		return nil
+	}
+	leases := r.Clientset.CoordinationV1().Leases(r.ConcurrencyGroupNamespace)
+	lease, err := leases.Get(ctx, concurrencyGroupLeaseName(tf.Spec.ConcurrencyGroup), metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != concurrencyGroupIdentity(tf) {
+		return nil
+	}
+	lease.Spec.HolderIdentity = nil
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	r.Recorder.Event(tf, "Normal", "ConcurrencyGroupReleased", fmt.Sprintf("Released concurrency group '%s'", tf.Spec.ConcurrencyGroup))
+	return nil
+}
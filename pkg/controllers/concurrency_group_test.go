@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newConcurrencyGroupTestTerraform(namespace, name string, phase tfv1alpha2.StatusPhase) *tfv1alpha2.Terraform {
+	return &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       tfv1alpha2.TerraformSpec{ConcurrencyGroup: "prod-account"},
+		Status: tfv1alpha2.TerraformStatus{
+			Phase: phase,
+			Stage: tfv1alpha2.Stage{TaskType: tfv1alpha2.RunSetup, State: tfv1alpha2.StateInitializing},
+		},
+	}
+}
+
+func TestAcquireConcurrencyGroup(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	first := newConcurrencyGroupTestTerraform("a", "first", tfv1alpha2.PhaseInitializing)
+	second := newConcurrencyGroupTestTerraform("b", "second", tfv1alpha2.PhaseInitializing)
+	r := ReconcileTerraform{
+		Client:                    fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second).Build(),
+		Clientset:                 kubernetesfake.NewSimpleClientset(),
+		Recorder:                  record.NewFakeRecorder(10),
+		ConcurrencyGroupNamespace: "tf-system",
+	}
+
+	holder, err := r.acquireConcurrencyGroup(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder != "a/first" {
+		t.Errorf("expected a/first to acquire the group, got %s", holder)
+	}
+
+	holder, err = r.acquireConcurrencyGroup(ctx, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder != "a/first" {
+		t.Errorf("expected b/second to wait on a/first, got %s", holder)
+	}
+
+	err = r.releaseConcurrencyGroup(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err = r.acquireConcurrencyGroup(ctx, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder != "b/second" {
+		t.Errorf("expected b/second to acquire the released group, got %s", holder)
+	}
+}
+
+func TestCanHoldConcurrencyGroup(t *testing.T) {
+	running := newConcurrencyGroupTestTerraform("a", "running", tfv1alpha2.PhaseRunning)
+	running.Status.Stage.State = tfv1alpha2.StateInProgress
+	if !canHoldConcurrencyGroup(running, "prod-account") {
+		t.Error("expected a running workflow to hold the group")
+	}
+	if canHoldConcurrencyGroup(running, "dev-account") {
+		t.Error("expected a workflow in another group to not hold the group")
+	}
+	completed := newConcurrencyGroupTestTerraform("a", "completed", tfv1alpha2.PhaseCompleted)
+	if canHoldConcurrencyGroup(completed, "prod-account") {
+		t.Error("expected a completed workflow to not hold the group")
+	}
+}
+
+func TestConcurrencyGroupRestartedWorkflow(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	tests := []struct {
+		name string
+		// secondFirst lets the queued member reconcile after the failure before the workflow restarts
+		secondFirst bool
+		wantHolder  string
+	}{
+		{name: "restart before the queued member", wantHolder: "a/first"},
+		{name: "restart after the queued member", secondFirst: true, wantHolder: "b/second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := newConcurrencyGroupTestTerraform("a", "first", tfv1alpha2.PhaseInitializing)
+			second := newConcurrencyGroupTestTerraform("b", "second", tfv1alpha2.PhaseInitializing)
+			r := ReconcileTerraform{
+				Client:                    fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second).Build(),
+				Clientset:                 kubernetesfake.NewSimpleClientset(),
+				Recorder:                  record.NewFakeRecorder(10),
+				ConcurrencyGroupNamespace: "tf-system",
+			}
+			wait := func(tf *tfv1alpha2.Terraform) bool {
+				blocked, err := r.waitForConcurrencyGroup(ctx, tf, logr.Discard())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return blocked
+			}
+
+			if wait(first) || !wait(second) {
+				t.Fatal("expected a/first to run and b/second to be queued")
+			}
+			first.Status.Phase = tfv1alpha2.PhaseRunning
+			first.Status.Stage = tfv1alpha2.Stage{TaskType: tfv1alpha2.RunApply, State: tfv1alpha2.StateInProgress}
+
+			// The apply fails, which releases the group, and the workflow restarts from the plan
+			first.Status.Stage.State = tfv1alpha2.StateFailed
+			if err := r.releaseConcurrencyGroup(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+			first.Status.ConcurrencyGroupHolder = ""
+			if err := r.Client.Status().Update(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+			if tt.secondFirst && wait(second) {
+				t.Fatal("expected b/second to take the released group")
+			}
+			first.Status.Stage = tfv1alpha2.Stage{TaskType: tfv1alpha2.RunPlan, State: tfv1alpha2.StateInitializing, Reason: "RESTARTED_WORKFLOW"}
+			if err := r.Client.Status().Update(ctx, first); err != nil {
+				t.Fatal(err)
+			}
+
+			firstBlocked, secondBlocked := wait(first), wait(second)
+			if firstBlocked == secondBlocked {
+				t.Fatalf("expected exactly one member to run, got a/first blocked %v and b/second blocked %v", firstBlocked, secondBlocked)
+			}
+			for _, tf := range []*tfv1alpha2.Terraform{first, second} {
+				if tf.Status.ConcurrencyGroupHolder != tt.wantHolder {
+					t.Errorf("%s: expected the holder %s, got %s", tf.Name, tt.wantHolder, tf.Status.ConcurrencyGroupHolder)
+				}
+			}
+			if second.Status.Phase != tfv1alpha2.PhaseQueued && secondBlocked {
+				t.Errorf("expected b/second to stay queued, got %s", second.Status.Phase)
+			}
+			if first.Status.Phase != tfv1alpha2.PhaseRunning {
+				t.Errorf("expected the restarted workflow to keep its phase, got %s", first.Status.Phase)
+			}
+		})
+	}
+}
+
+func TestConcurrencyGroupQueuedDelete(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	first := newConcurrencyGroupTestTerraform("a", "first", tfv1alpha2.PhaseInitializing)
+	deleted := newConcurrencyGroupTestTerraform("b", "deleted", tfv1alpha2.PhaseInitDelete)
+	deleted.Status.Stage.TaskType = tfv1alpha2.RunSetupDelete
+	recorder := record.NewFakeRecorder(10)
+	r := ReconcileTerraform{
+		Client:                    fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, deleted).Build(),
+		Clientset:                 kubernetesfake.NewSimpleClientset(),
+		Recorder:                  recorder,
+		ConcurrencyGroupNamespace: "tf-system",
+	}
+	if blocked, err := r.waitForConcurrencyGroup(ctx, first, logr.Discard()); err != nil || blocked {
+		t.Fatalf("expected a/first to run, got %v %v", blocked, err)
+	}
+	<-recorder.Events
+
+	// The delete keeps its phase while it waits so it is not restarted on every reconcile
+	for i := 0; i < 3; i++ {
+		blocked, err := r.waitForConcurrencyGroup(ctx, deleted, logr.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !blocked {
+			t.Fatal("expected b/deleted to wait on a/first")
+		}
+		if deleted.Status.Phase != tfv1alpha2.PhaseInitDelete || !deleted.Status.Queued {
+			t.Errorf("expected the queued delete to stay in the %s phase, got %s queued %v", tfv1alpha2.PhaseInitDelete, deleted.Status.Phase, deleted.Status.Queued)
+		}
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single Queued event, got %d", len(recorder.Events))
+	}
+}
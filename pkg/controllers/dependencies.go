@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// dependencyKey returns the namespaced name of the dependency. Dependencies without a namespace are in the
+// namespace of the resource that depends on them.
+func dependencyKey(tf *tfv1alpha2.Terraform, dependency tfv1alpha2.Dependency) types.NamespacedName {
+	namespace := dependency.Namespace
+	if namespace == "" {
+		namespace = tf.Namespace
+	}
+	return types.NamespacedName{Namespace: namespace, Name: dependency.Name}
+}
+
+// validateDependencies rejects dependencies in other namespaces unless the controller allows them. The
+// controller reads the outputs of the dependencies with its own privileges, so a dependency in another
+// namespace would give the resource access to outputs its creator may not be allowed to read.
+func (r ReconcileTerraform) validateDependencies(tf *tfv1alpha2.Terraform) error {
+	if r.AllowCrossNamespaceDependencies {
+		return nil
+	}
+	for _, dependency := range tf.Spec.DependsOn {
+		if key := dependencyKey(tf, dependency); key.Namespace != tf.Namespace {
+			return fmt.Errorf("dependency '%s' is not in the namespace of the resource and the controller does not allow dependencies across namespaces", key)
+		}
+	}
+	return nil
+}
+
+// dependsOn returns true when tf has a dependency on the resource
+func dependsOn(tf *tfv1alpha2.Terraform, key types.NamespacedName) bool {
+	for _, dependency := range tf.Spec.DependsOn {
+		if dependencyKey(tf, dependency) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isDependencyReady returns true when the dependency has completed the workflow of its current generation.
+func isDependencyReady(dependency *tfv1alpha2.Terraform) bool {
+	if dependency.GetDeletionTimestamp() != nil {
+		return false
+	}
+	return dependency.Status.Phase == tfv1alpha2.PhaseCompleted && dependency.Status.Stage.Generation == dependency.Generation
+}
+
+// getUnmetDependencies returns the resources the workflow has to wait on. A create workflow waits on
+// dependencies that have not completed. A destroy workflow waits on the resources that depend on it.
+func (r ReconcileTerraform) getUnmetDependencies(ctx context.Context, tf *tfv1alpha2.Terraform) ([]string, error) {
+	waitingOn := []string{}
+	if tf.GetDeletionTimestamp() != nil {
+		tfList := &tfv1alpha2.TerraformList{}
+		err := r.Client.List(ctx, tfList)
+		if err != nil {
+			return waitingOn, err
+		}
+		key := types.NamespacedName{Namespace: tf.Namespace, Name: tf.Name}
+		for _, item := range tfList.Items {
+			if item.Namespace == tf.Namespace && item.Name == tf.Name {
+				continue
+			}
+			// Resources in other namespaces can not hold up the destroy unless the controller allows
+			// dependencies across namespaces
+			if item.Namespace != tf.Namespace && !r.AllowCrossNamespaceDependencies {
+				continue
+			}
+			if dependsOn(&item, key) {
+				waitingOn = append(waitingOn, fmt.Sprintf("%s/%s", item.Namespace, item.Name))
+			}
+		}
+		sort.Strings(waitingOn)
+		return waitingOn, nil
+	}
+
+	if err := r.validateDependencies(tf); err != nil {
+		return waitingOn, err
+	}
+	for _, dependency := range tf.Spec.DependsOn {
+		key := dependencyKey(tf, dependency)
+		dependencyTf := &tfv1alpha2.Terraform{}
+		err := r.Client.Get(ctx, key, dependencyTf)
+		if err != nil && !errors.IsNotFound(err) {
+			return waitingOn, err
+		}
+		if err != nil || !isDependencyReady(dependencyTf) {
+			waitingOn = append(waitingOn, key.String())
+		}
+	}
+	return waitingOn, nil
+}
+
+// getDependencyOutputs reads the outputs mapped from the dependencies and returns them by the name of the
+// variable that receives them.
+func (r ReconcileTerraform) getDependencyOutputs(ctx context.Context, tf *tfv1alpha2.Terraform) (map[string]string, error) {
+	data := make(map[string]string)
+	if err := r.validateDependencies(tf); err != nil {
+		return data, err
+	}
+	for _, dependency := range tf.Spec.DependsOn {
+		if len(dependency.Outputs) == 0 {
+			continue
+		}
+		key := dependencyKey(tf, dependency)
+		dependencyTf := &tfv1alpha2.Terraform{}
+		err := r.Client.Get(ctx, key, dependencyTf)
+		if err != nil {
+			return data, fmt.Errorf("failed to get dependency '%s': %s", key, err)
+		}
+
+		outputsSecretName := dependencyTf.Status.PodNamePrefix + "-outputs"
+		if dependencyTf.Spec.OutputsSecret != "" {
+			outputsSecretName = dependencyTf.Spec.OutputsSecret
+		}
+		outputs := make(map[string][]byte)
+		secret, err := r.loadSecret(ctx, outputsSecretName, key.Namespace)
+		if err == nil {
+			outputs = secret.Data
+		}
+
+		for _, output := range dependency.Outputs {
+			variable := output.Variable
+			if variable == "" {
+				variable = output.Name
+			}
+			if value, found := outputs[output.Name]; found {
+				data[variable] = string(value)
+			} else if value, found := dependencyTf.Status.Outputs[output.Name]; found {
+				data[variable] = value
+			} else {
+				return data, fmt.Errorf("output '%s' was not found in dependency '%s'", output.Name, key)
+			}
+		}
+	}
+	return data, nil
+}
+
+// mapDependencyRequests returns the resources that need to be reconciled when tf changes. These are the
+// resources that depend on tf, which may be waiting on tf to complete, and the dependencies of tf, which
+// may be waiting on tf to be destroyed.
+func (r ReconcileTerraform) mapDependencyRequests(obj client.Object) []reconcile.Request {
+	tf, ok := obj.(*tfv1alpha2.Terraform)
+	if !ok {
+		return []reconcile.Request{}
+	}
+	requests := []reconcile.Request{}
+	for _, dependency := range tf.Spec.DependsOn {
+		requests = append(requests, reconcile.Request{NamespacedName: dependencyKey(tf, dependency)})
+	}
+
+	tfList := &tfv1alpha2.TerraformList{}
+	err := r.Client.List(context.TODO(), tfList)
+	if err != nil {
+		r.Log.Error(err, "Failed to list resources that depend on the resource")
+		return requests
+	}
+	key := types.NamespacedName{Namespace: tf.Namespace, Name: tf.Name}
+	for _, item := range tfList.Items {
+		if dependsOn(&item, key) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name}})
+		}
+	}
+	return requests
+}
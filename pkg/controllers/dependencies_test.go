@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDependencies(t *testing.T) {
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	network := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "network", Generation: 2},
+		Status: tfv1alpha2.TerraformStatus{
+			PodNamePrefix: "network-abc",
+			Phase:         tfv1alpha2.PhaseCompleted,
+			Stage:         tfv1alpha2.Stage{Generation: 2},
+			Outputs:       map[string]string{"region": "us-east-1"},
+		},
+	}
+	networkOutputs := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "network-abc-outputs"},
+		Data:       map[string][]byte{"vpc_id": []byte("vpc-123")},
+	}
+	cluster := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "cluster", Generation: 1},
+		Status: tfv1alpha2.TerraformStatus{
+			Phase: tfv1alpha2.PhaseRunning,
+			Stage: tfv1alpha2.Stage{Generation: 1},
+		},
+	}
+	app := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			DependsOn: []tfv1alpha2.Dependency{
+				{
+					Name:      "network",
+					Namespace: "infra",
+					Outputs: []tfv1alpha2.DependencyOutput{
+						{Name: "vpc_id"},
+						{Name: "region", Variable: "aws_region"},
+					},
+				},
+				{Name: "cluster", Namespace: "infra"},
+			},
+		},
+	}
+	r := ReconcileTerraform{
+		Client:                          fake.NewClientBuilder().WithScheme(scheme).WithObjects(network, networkOutputs, cluster, app).Build(),
+		AllowCrossNamespaceDependencies: true,
+	}
+
+	waitingOn, err := r.getUnmetDependencies(ctx, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"infra/cluster"}; !reflect.DeepEqual(waitingOn, want) {
+		t.Errorf("expected to wait on %v, got %v", want, waitingOn)
+	}
+
+	outputs, err := r.getDependencyOutputs(ctx, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"vpc_id": "vpc-123", "aws_region": "us-east-1"}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("expected outputs %v, got %v", want, outputs)
+	}
+
+	now := metav1.Now()
+	network.DeletionTimestamp = &now
+	waitingOn, err = r.getUnmetDependencies(ctx, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"apps/app"}; !reflect.DeepEqual(waitingOn, want) {
+		t.Errorf("expected destroy to wait on %v, got %v", want, waitingOn)
+	}
+}
+
+func TestCrossNamespaceDependencies(t *testing.T) {
+	ctx := context.TODO()
+	network := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "network", Generation: 1},
+		Status: tfv1alpha2.TerraformStatus{
+			PodNamePrefix: "network-abc",
+			Phase:         tfv1alpha2.PhaseCompleted,
+			Stage:         tfv1alpha2.Stage{Generation: 1},
+		},
+	}
+	networkOutputs := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "infra", Name: "network-abc-outputs"},
+		Data:       map[string][]byte{"db_password": []byte("hunter22")},
+	}
+	app := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			DependsOn: []tfv1alpha2.Dependency{
+				{Name: "network", Namespace: "infra", Outputs: []tfv1alpha2.DependencyOutput{{Name: "db_password"}}},
+			},
+		},
+	}
+	r := newTestReconciler(network, networkOutputs, app)
+
+	if err := r.validateDependencies(app); err == nil {
+		t.Error("expected the dependency in another namespace to be rejected")
+	}
+	if _, err := r.getUnmetDependencies(ctx, app); err == nil {
+		t.Error("expected the dependency in another namespace to not be checked")
+	}
+	if outputs, err := r.getDependencyOutputs(ctx, app); err == nil || len(outputs) != 0 {
+		t.Errorf("expected the outputs in another namespace to not be read, got %v %v", outputs, err)
+	}
+
+	// A resource in another namespace can not hold up the destroy of the dependency
+	now := metav1.Now()
+	network.DeletionTimestamp = &now
+	if waitingOn, err := r.getUnmetDependencies(ctx, network); err != nil || len(waitingOn) != 0 {
+		t.Errorf("expected the destroy to not wait on other namespaces, got %v %v", waitingOn, err)
+	}
+
+	// Dependencies in the namespace of the resource are always allowed
+	app.Spec.DependsOn[0].Namespace = "apps"
+	if err := r.validateDependencies(app); err != nil {
+		t.Error(err)
+	}
+}
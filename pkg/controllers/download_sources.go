@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+// objectSourcesPath is where the setup task mounts the ConfigMaps and Secrets used as download sources
+const objectSourcesPath = "/tmp/tfo-resources"
+
+// objectSource is a ConfigMap or Secret used as a download source, ie `configmap::<name>[/<key>]` or
+// `secret::<name>[/<key>]`
+type objectSource struct {
+	Kind string
+	Name string
+	Key  string
+}
+
+// parseObjectSource returns false when the address is not a ConfigMap or Secret address
+func parseObjectSource(address string) (objectSource, bool) {
+	forced, rest := getForcedGetter(strings.TrimSpace(address))
+	kind := strings.ToLower(forced)
+	if kind != inputKindConfigMap && kind != inputKindSecret {
+		return objectSource{}, false
+	}
+	source := objectSource{Kind: kind, Name: rest}
+	if i := strings.Index(rest, "/"); i != -1 {
+		source.Name, source.Key = rest[:i], rest[i+1:]
+	}
+	if source.Name == "" {
+		return objectSource{}, false
+	}
+	return source, true
+}
+
+// mountPath is the path the object is mounted at in the setup task
+func (s objectSource) mountPath() string {
+	return path.Join(objectSourcesPath, s.Kind, s.Name)
+}
+
+func (s objectSource) parsedAddress(path string, useAsVar bool) ParsedAddress {
+	u := url.URL{Scheme: "file", Path: s.mountPath()}
+	files := []string{"."}
+	if s.Key != "" {
+		u.Path = u.Path + "/" + s.Key
+		files = []string{s.Key}
+	}
+	return ParsedAddress{
+		DetectedScheme: s.Kind,
+		Path:           path,
+		UseAsVar:       useAsVar,
+		Url:            u.String(),
+		Address:        u.String(),
+		Files:          files,
+		UrlScheme:      u.Scheme,
+		Uri:            u.Path,
+	}
+}
+
+// setupScriptAddressPrefixes are the addresses the bundled setup script downloads. The other getters, eg
+// s3, gcs and oci, are only implemented by the runner.
+var setupScriptAddressPrefixes = []string{"file://", "git::", "http://", "https://"}
+
+// usesDefaultSetupScript returns true when the setup tasks run the bundled setup script, ie without the
+// runner or a script configured in taskOptions
+func usesDefaultSetupScript(tf *tfv1alpha2.Terraform, runnerImage string) bool {
+	if runnerImage != "" {
+		return false
+	}
+	for _, taskOption := range tf.Spec.TaskOptions {
+		if taskOption.Script == (tfv1alpha2.StageScript{}) {
+			continue
+		}
+		if tfv1alpha2.ListContainsTask(taskOption.Affects, tfv1alpha2.RunSetup) || tfv1alpha2.ListContainsTask(taskOption.Affects, tfv1alpha2.RunSetupDelete) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateSetupScriptAddress returns an error when the bundled setup script can not download the address
+func validateSetupScriptAddress(parsed ParsedAddress) error {
+	for _, prefix := range setupScriptAddressPrefixes {
+		if strings.HasPrefix(parsed.Address, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' can only be downloaded by the runner, which requires the controller's --runner-image", parsed.Address)
+}
+
+// validateChecksumSource returns an error when the address has a checksum that can not be verified. Only
+// files and archives are verified, the git repos, OCI artifacts and the ConfigMaps and Secrets without a key
+// are directories.
+func validateChecksumSource(parsed ParsedAddress) error {
+	if parsed.Checksum == "" {
+		return nil
+	}
+	switch parsed.DetectedScheme {
+	case "git", "oci":
+		return fmt.Errorf("the checksum of '%s' can not be verified, only files and archives have a checksum", parsed.Address)
+	case inputKindConfigMap, inputKindSecret:
+		if len(parsed.Files) == 1 && parsed.Files[0] == "." {
+			return fmt.Errorf("the checksum of '%s' can not be verified, only a key of the %s has a checksum", parsed.Address, parsed.DetectedScheme)
+		}
+	}
+	return nil
+}
+
+// getObjectSources returns the ConfigMaps and Secrets used by the module source and the resource downloads
+func getObjectSources(tf *tfv1alpha2.Terraform) []objectSource {
+	addresses := []string{tf.Spec.TerraformModule.Source}
+	if tf.Spec.Setup != nil {
+		for _, s := range tf.Spec.Setup.ResourceDownloads {
+			addresses = append(addresses, s.Address)
+		}
+	}
+	sources := []objectSource{}
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		source, ok := parseObjectSource(address)
+		if !ok {
+			continue
+		}
+		// The whole object is mounted even when only a key is used
+		source.Key = ""
+		if seen[source.mountPath()] {
+			continue
+		}
+		seen[source.mountPath()] = true
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// setAddressRef sets the `ref` query of the go-getter address, eg to pin a git address to a commit
+func setAddressRef(address, ref string) (string, error) {
+	forced, rest := getForcedGetter(address)
+	source, subdir := getter.SourceDirSubdir(rest)
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("could not parse '%s': %v", address, err)
+	}
+	q := u.Query()
+	q.Set("ref", ref)
+	u.RawQuery = q.Encode()
+	result := u.String()
+	if subdir != "" {
+		result = strings.Replace(result, "?", "//"+subdir+"?", 1)
+	}
+	if forced != "" {
+		result = forced + "::" + result
+	}
+	return result, nil
+}
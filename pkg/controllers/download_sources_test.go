@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetParsedAddressSources(t *testing.T) {
+	tests := []struct {
+		address string
+		scheme  string
+		want    string
+		files   []string
+		// setupScript is true when the bundled setup script downloads the address without the runner
+		setupScript bool
+	}{
+		{address: "https://example.com/modules/vpc.tar.gz", scheme: "https", want: "https://example.com/modules/vpc.tar.gz", files: []string{"."}, setupScript: true},
+		{address: "s3://modules/vpc.zip", scheme: "s3", want: "s3::https://s3.amazonaws.com/modules/vpc.zip", files: []string{"."}},
+		{address: "gs://modules/vpc.zip", scheme: "gcs", want: "gcs::https://www.googleapis.com/storage/v1/modules/vpc.zip", files: []string{"."}},
+		{address: "oci://ghcr.io/org/vpc:1.0.0", scheme: "oci", want: "oci://ghcr.io/org/vpc:1.0.0", files: []string{"."}},
+		{address: "github.com/org/repo//modules/vpc?ref=main", scheme: "git", want: "git::https://github.com/org/repo.git//modules/vpc?ref=main", files: []string{"modules/vpc"}, setupScript: true},
+		{address: "git@github.com:org/repo.git", scheme: "git", want: "git::ssh://git@github.com/org/repo.git", files: []string{"."}, setupScript: true},
+		{address: "configmap::vpc-module", scheme: "configmap", want: "file:///tmp/tfo-resources/configmap/vpc-module", files: []string{"."}, setupScript: true},
+		{address: "secret::tfvars/prod.tfvars", scheme: "secret", want: "file:///tmp/tfo-resources/secret/tfvars/prod.tfvars", files: []string{"prod.tfvars"}, setupScript: true},
+	}
+	for _, tt := range tests {
+		got, err := getParsedAddress(tt.address, "", false, map[string]scmType{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+		if got.DetectedScheme != tt.scheme || got.Address != tt.want {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.address, tt.scheme, tt.want, got.DetectedScheme, got.Address)
+		}
+		if len(got.Files) != len(tt.files) || got.Files[0] != tt.files[0] {
+			t.Errorf("%s: expected files %v, got %v", tt.address, tt.files, got.Files)
+		}
+		if err := validateSetupScriptAddress(got); (err == nil) != tt.setupScript {
+			t.Errorf("%s: expected the setup script to support it %t, got %v", tt.address, tt.setupScript, err)
+		}
+	}
+}
+
+func TestValidateChecksumSource(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{address: "https://example.com/modules/vpc.tar.gz", valid: true},
+		{address: "s3://modules/vpc.zip", valid: true},
+		{address: "secret::tfvars/prod.tfvars", valid: true},
+		{address: "configmap::vpc-module", valid: false},
+		{address: "github.com/org/repo//modules/vpc?ref=main", valid: false},
+		{address: "git::https://example.com/org/repo.git", valid: false},
+		{address: "oci://ghcr.io/org/vpc:1.0.0", valid: false},
+	}
+	for _, tt := range tests {
+		got, err := getParsedAddress(tt.address, "", false, map[string]scmType{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.address, err)
+			continue
+		}
+		if err := validateChecksumSource(got); err != nil {
+			t.Errorf("%s: expected no error without a checksum, got %v", tt.address, err)
+		}
+		got.Checksum = "sha256:abcdef"
+		if err := validateChecksumSource(got); (err == nil) != tt.valid {
+			t.Errorf("%s: expected the checksum to be valid %t, got %v", tt.address, tt.valid, err)
+		}
+	}
+}
+
+func TestUsesDefaultSetupScript(t *testing.T) {
+	tf := &tfv1alpha2.Terraform{}
+	if !usesDefaultSetupScript(tf, "") {
+		t.Error("expected the bundled setup script without a runner image or setup script")
+	}
+	if usesDefaultSetupScript(tf, "runner:1.0.0") {
+		t.Error("expected the runner to run the setup with a runner image")
+	}
+	tf.Spec.TaskOptions = []tfv1alpha2.TaskOption{
+		{Affects: []tfv1alpha2.TaskName{"*"}, Env: []corev1.EnvVar{{Name: "A", Value: "1"}}},
+		{Affects: []tfv1alpha2.TaskName{tfv1alpha2.RunSetup}, Script: tfv1alpha2.StageScript{Inline: "aws s3 cp s3://modules/vpc.zip ."}},
+	}
+	if usesDefaultSetupScript(tf, "") {
+		t.Error("expected the configured setup script to run instead")
+	}
+}
+
+func TestSetAddressRef(t *testing.T) {
+	tests := []struct{ address, want string }{
+		{"git::https://github.com/org/repo.git?ref=main", "git::https://github.com/org/repo.git?ref=abc123"},
+		{"git::https://github.com/org/repo.git//modules/vpc?ref=main", "git::https://github.com/org/repo.git//modules/vpc?ref=abc123"},
+		{"git::ssh://git@github.com/org/repo.git", "git::ssh://git@github.com/org/repo.git?ref=abc123"},
+	}
+	for _, tt := range tests {
+		got, err := setAddressRef(tt.address, "abc123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestObjectSourcesAreMountedInSetup(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformModule: tfv1alpha2.Module{Source: "configmap::vpc-module"},
+		Setup: &tfv1alpha2.Setup{
+			ResourceDownloads: []tfv1alpha2.ResourceDownload{
+				{Address: "secret::tfvars/prod.tfvars", UseAsVar: true},
+				{Address: "secret::tfvars/common.tfvars", UseAsVar: true},
+				{Address: "https://example.com/policies.tar.gz", Checksum: "sha256:abcd"},
+			},
+		},
+	})
+
+	pod := newTaskOptions(tf, tfv1alpha2.RunSetup, 1, nil).generatePod()
+	mounts := map[string]bool{}
+	for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+		mounts[mount.MountPath] = true
+	}
+	for _, want := range []string{"/tmp/tfo-resources/configmap/vpc-module", "/tmp/tfo-resources/secret/tfvars"} {
+		if !mounts[want] {
+			t.Errorf("expected %s to be mounted, got %v", want, mounts)
+		}
+	}
+	sourceVolumes := 0
+	for _, volume := range pod.Spec.Volumes {
+		if strings.HasPrefix(volume.Name, "tfo-resource-") {
+			sourceVolumes++
+		}
+	}
+	if sourceVolumes != 2 {
+		t.Errorf("expected the secret to be mounted once, got %d volumes", sourceVolumes)
+	}
+
+	for _, mount := range taskContainer(tf, tfv1alpha2.RunPlan).VolumeMounts {
+		if mount.MountPath == "/tmp/tfo-resources/configmap/vpc-module" {
+			t.Error("expected the sources to only be mounted in the setup task")
+		}
+	}
+
+	references := getInputReferences(tf)
+	found := 0
+	for _, reference := range references {
+		if reference == "configmap/default/vpc-module" || reference == "secret/default/tfvars" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected the sources to be watched, got %v", references)
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getEngine returns the engine of the resource and its version. Resources without an engine use
+// terraform at spec.terraformVersion. The version resolved for the generation is used once it is resolved.
+func getEngine(tf *tfv1alpha2.Terraform) (tfv1alpha2.EngineName, string) {
+	name, version := getRequestedEngine(tf)
+	if tf.Status.ResolvedEngineVersion != "" {
+		version = tf.Status.ResolvedEngineVersion
+	}
+	return name, version
+}
+
+// getRequestedEngine returns the engine of the resource and the version, or version constraint, of the spec.
+// Without a version the tag of a custom image is the version.
+func getRequestedEngine(tf *tfv1alpha2.Terraform) (tfv1alpha2.EngineName, string) {
+	name := tfv1alpha2.EngineTerraform
+	version := ""
+	if engine := tf.Spec.Engine; engine != nil {
+		if engine.Name != "" {
+			name = engine.Name
+		}
+		version = engine.Version
+	}
+	if version == "" && name == tfv1alpha2.EngineTerraform {
+		version = tf.Spec.TerraformVersion
+	}
+	if version == "" {
+		if images := tf.Spec.Images; images != nil && images.Terraform != nil {
+			_, version = splitImageTag(images.Terraform.Image)
+		}
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return name, version
+}
+
+// engineBinary is the name of the engine's binary in the task image
+func engineBinary(engine tfv1alpha2.EngineName) string {
+	if engine == tfv1alpha2.EngineOpenTofu {
+		return "tofu"
+	}
+	return "terraform"
+}
+
+// engineImageRepo is the default image of the terraform tasks of the engine
+func engineImageRepo(engine tfv1alpha2.EngineName) string {
+	if engine == tfv1alpha2.EngineOpenTofu {
+		return tfv1alpha2.OpenTofuTaskImageRepoDefault
+	}
+	return tfv1alpha2.TerraformTaskImageRepoDefault
+}
+
+// terraformImageRepo is the image of the terraform tasks without its tag
+func terraformImageRepo(tf *tfv1alpha2.Terraform, engine tfv1alpha2.EngineName) string {
+	images := tf.Spec.Images
+	if images == nil || images.Terraform == nil || images.Terraform.Image == "" {
+		return engineImageRepo(engine)
+	}
+	repo, _ := splitImageTag(images.Terraform.Image)
+	return repo
+}
+
+// terraformImageDigest is the digest, eg `sha256:<hex>`, of the custom image of the terraform tasks
+func terraformImageDigest(tf *tfv1alpha2.Terraform) string {
+	images := tf.Spec.Images
+	if images == nil || images.Terraform == nil {
+		return ""
+	}
+	_, digest := splitImageDigest(images.Terraform.Image)
+	return digest
+}
+
+// terraformImage is the image of the terraform tasks tagged with the engine's version. An image pinned to a
+// digest is used as is.
+func terraformImage(tf *tfv1alpha2.Terraform, engine tfv1alpha2.EngineName, version string) string {
+	if terraformImageDigest(tf) != "" {
+		return tf.Spec.Images.Terraform.Image
+	}
+	return fmt.Sprintf("%s:%s", terraformImageRepo(tf, engine), version)
+}
+
+// splitImageDigest splits the digest off the image
+func splitImageDigest(image string) (string, string) {
+	if i := strings.Index(image, "@"); i != -1 {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+// splitImageTag splits the tag off the image. The port of the registry, eg `registry:5000/image`, is not a
+// tag and the digest is not part of the tag.
+func splitImageTag(image string) (string, string) {
+	image, _ = splitImageDigest(image)
+	i := strings.LastIndex(image, ":")
+	if i == -1 || strings.Contains(image[i:], "/") {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
+
+// cliConfigFileName is the name of the mounted CLI config, the .terraformrc or .tofurc, of the engine
+func cliConfigFileName(engine tfv1alpha2.EngineName) string {
+	if engine == tfv1alpha2.EngineOpenTofu {
+		return "tofurc"
+	}
+	return cliConfigKey
+}
+
+// validateEngine checks the engine options that can not be checked by the CRD schema
+func validateEngine(tf *tfv1alpha2.Terraform) error {
+	engine := tf.Spec.Engine
+	if digest := terraformImageDigest(tf); digest != "" && ((engine != nil && engine.Version != "") || tf.Spec.TerraformVersion != "") {
+		return fmt.Errorf("the terraform image is pinned to '%s', its tag can not be replaced with the engine version", digest)
+	}
+	if engine == nil || engine.StateEncryption == nil {
+		return nil
+	}
+	if name, _ := getEngine(tf); name != tfv1alpha2.EngineOpenTofu {
+		return fmt.Errorf("state encryption is not supported by the %s engine", name)
+	}
+	if ref := engine.StateEncryption.ConfigSecretRef; ref.Name == "" || ref.Key == "" {
+		return fmt.Errorf("state encryption requires the name and key of the config secret")
+	}
+	return nil
+}
+
+// engineEnvs tells the tasks which engine to run. The state encryption config is read from its secret.
+func (r TaskOptions) engineEnvs() []corev1.EnvVar {
+	envs := []corev1.EnvVar{
+		{
+			Name:  "TFO_ENGINE",
+			Value: string(r.engine),
+		},
+		{
+			Name:  "TFO_ENGINE_BINARY",
+			Value: engineBinary(r.engine),
+		},
+		{
+			Name:  "TFO_ENGINE_VERSION",
+			Value: r.terraformVersion,
+		},
+	}
+	if r.stateEncryption != nil {
+		ref := r.stateEncryption.ConfigSecretRef
+		envs = append(envs, corev1.EnvVar{
+			Name: "TF_ENCRYPTION",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &ref,
+			},
+		})
+	}
+	return envs
+}
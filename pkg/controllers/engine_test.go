@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newTestOpenTofuTerraform() *tfv1alpha2.Terraform {
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformVersion: "1.5.7",
+		Engine: &tfv1alpha2.Engine{
+			Name:    tfv1alpha2.EngineOpenTofu,
+			Version: "1.7.2",
+			StateEncryption: &tfv1alpha2.StateEncryption{
+				ConfigSecretRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "tofu-encryption"},
+					Key:                  "config.hcl",
+				},
+			},
+		},
+	})
+}
+
+func TestValidateEngine(t *testing.T) {
+	tf := newTestOpenTofuTerraform()
+	if err := validateEngine(tf); err != nil {
+		t.Fatal(err)
+	}
+	tf.Spec.Engine.Name = tfv1alpha2.EngineTerraform
+	if err := validateEngine(tf); err == nil {
+		t.Error("expected state encryption to require opentofu")
+	}
+
+	tf = newTestTerraform(tfv1alpha2.TerraformSpec{
+		Images: &tfv1alpha2.Images{Terraform: &tfv1alpha2.ImageConfig{Image: "registry.example.com/terraform:1.5.7@sha256:abc"}},
+	})
+	if err := validateEngine(tf); err != nil {
+		t.Fatal(err)
+	}
+	tf.Spec.TerraformVersion = "1.6.0"
+	if err := validateEngine(tf); err == nil {
+		t.Error("expected a digest to be rejected together with a version")
+	}
+}
+
+func TestEngineTaskOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		tf     *tfv1alpha2.Terraform
+		engine tfv1alpha2.EngineName
+		image  string
+		envs   map[string]string
+	}{
+		{
+			name:   "without an engine",
+			tf:     newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.5.7"}),
+			engine: tfv1alpha2.EngineTerraform,
+			image:  tfv1alpha2.TerraformTaskImageRepoDefault + ":1.5.7",
+			envs:   map[string]string{"TFO_ENGINE_BINARY": "terraform", "TFO_TERRAFORM_VERSION": "1.5.7"},
+		},
+		{
+			name:   "opentofu",
+			tf:     newTestOpenTofuTerraform(),
+			engine: tfv1alpha2.EngineOpenTofu,
+			image:  tfv1alpha2.OpenTofuTaskImageRepoDefault + ":1.7.2",
+			envs:   map[string]string{"TFO_ENGINE": "opentofu", "TFO_ENGINE_BINARY": "tofu", "TFO_ENGINE_VERSION": "1.7.2"},
+		},
+	}
+	for _, tt := range tests {
+		runOpts := newTaskOptions(tt.tf, tfv1alpha2.RunPlan, 1, nil)
+		if runOpts.engine != tt.engine || runOpts.image != tt.image || runOpts.resourceLabels["terraforms.tf.isaaguilar.com/engine"] != string(tt.engine) {
+			t.Errorf("%s: expected %s at %s, got %s %s", tt.name, tt.engine, tt.image, runOpts.engine, runOpts.image)
+		}
+		envs := taskEnvs(tt.tf, tfv1alpha2.RunPlan)
+		for name, want := range tt.envs {
+			if envs[name] != want {
+				t.Errorf("%s: expected %s=%q, got %q", tt.name, name, want, envs[name])
+			}
+		}
+	}
+}
+
+func TestEngineCLIConfig(t *testing.T) {
+	runOpts := newTaskOptions(newTestOpenTofuTerraform(), tfv1alpha2.RunPlan, 1, nil)
+	runOpts.cliConfig = true
+	if envs := podEnvs(runOpts.generatePod().Spec.Containers[0]); envs["TF_CLI_CONFIG_FILE"] != cliConfigPath+"/tofurc" {
+		t.Errorf("expected the tofurc, got %s", envs["TF_CLI_CONFIG_FILE"])
+	}
+}
+
+func TestEngineStateEncryption(t *testing.T) {
+	tf := newTestOpenTofuTerraform()
+	if !utils.ListContainsStr(getInputReferences(tf), "secret/default/tofu-encryption") {
+		t.Errorf("expected the encryption secret to be watched, got %v", getInputReferences(tf))
+	}
+	// Only the terraform tasks decrypt the state
+	if got := tasksWithEnv(tf, "TF_ENCRYPTION"); !reflect.DeepEqual(got, terraformTaskNames) {
+		t.Errorf("expected only the terraform tasks to get the encryption config, got %v", got)
+	}
+	for _, env := range taskContainer(tf, tfv1alpha2.RunPlan).Env {
+		if env.Name == "TF_ENCRYPTION" && (env.ValueFrom == nil || env.ValueFrom.SecretKeyRef.Name != "tofu-encryption") {
+			t.Errorf("expected the encryption config to be read from its secret, got %v", env)
+		}
+	}
+}
+
+func TestEngineImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		engine        *tfv1alpha2.Engine
+		version       string
+		want          string
+		engineVersion string
+	}{
+		{name: "default image", version: "1.5.7", want: tfv1alpha2.TerraformTaskImageRepoDefault + ":1.5.7", engineVersion: "1.5.7"},
+		{name: "custom tag without a version", image: "registry.example.com/terraform:1.5.7-custom", want: "registry.example.com/terraform:1.5.7-custom", engineVersion: "1.5.7-custom"},
+		{name: "custom tag with a version", image: "registry.example.com/terraform:1.5.7-custom", version: "1.6.0", want: "registry.example.com/terraform:1.6.0", engineVersion: "1.6.0"},
+		{name: "registry port without a tag", image: "registry.example.com:5000/terraform", want: "registry.example.com:5000/terraform:latest", engineVersion: "latest"},
+		{name: "registry port with a tag", image: "registry.example.com:5000/terraform:1.5.7", want: "registry.example.com:5000/terraform:1.5.7", engineVersion: "1.5.7"},
+		{name: "digest with a tag", image: "registry.example.com/terraform:1.5.7@sha256:abc", want: "registry.example.com/terraform:1.5.7@sha256:abc", engineVersion: "1.5.7"},
+		{name: "digest without a tag", image: "registry.example.com:5000/terraform@sha256:abc", want: "registry.example.com:5000/terraform@sha256:abc", engineVersion: "latest"},
+		{name: "engine version", image: "registry.example.com:5000/tofu:1.6.0", engine: &tfv1alpha2.Engine{Name: tfv1alpha2.EngineOpenTofu, Version: "1.7.2"}, want: "registry.example.com:5000/tofu:1.7.2", engineVersion: "1.7.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: tt.version, Engine: tt.engine})
+			if tt.image != "" {
+				tf.Spec.Images = &tfv1alpha2.Images{Terraform: &tfv1alpha2.ImageConfig{Image: tt.image}}
+			}
+			runOpts := newTaskOptions(tf, tfv1alpha2.RunPlan, 1, nil)
+			if runOpts.image != tt.want {
+				t.Errorf("expected the image %s, got %s", tt.want, runOpts.image)
+			}
+			if got := runOpts.resourceLabels["terraforms.tf.isaaguilar.com/engineVersion"]; got != tt.engineVersion {
+				t.Errorf("expected the engine version label %s, got %s", tt.engineVersion, got)
+			}
+			// The terraformVersion label is only set for terraform
+			got, found := runOpts.resourceLabels["terraforms.tf.isaaguilar.com/terraformVersion"]
+			if isTerraform := tt.engine == nil; found != isTerraform || (found && got != tt.engineVersion) {
+				t.Errorf("expected the terraformVersion label %t, got %q", isTerraform, got)
+			}
+		})
+	}
+}
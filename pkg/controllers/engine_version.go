@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/go-version"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxImageTagPages caps the number of pages of tags read from an image registry
+const maxImageTagPages = 50
+
+var (
+	// authParamPattern finds the parameters of a WWW-Authenticate challenge
+	authParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	// nextLinkPattern finds the next page of a paginated registry response
+	nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+	// invalidLabelValueChars are the characters, eg the `+` of build metadata, that label values can not have
+	invalidLabelValueChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+)
+
+// versionLabelValue makes the version, or image tag, a valid label value. Label values have at most 63
+// characters and begin and end with an alphanumeric character while tags can have up to 128 characters.
+func versionLabelValue(v string) string {
+	v = invalidLabelValueChars.ReplaceAllString(v, "_")
+	if len(v) > validation.LabelValueMaxLength {
+		v = v[:validation.LabelValueMaxLength]
+	}
+	return strings.TrimFunc(v, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isVersionConstraint returns true for versions like `~> 1.5` that are not versions themselves. Versions
+// that are neither, like image tags, are used as is.
+func isVersionConstraint(v string) bool {
+	if _, err := version.NewVersion(v); err == nil {
+		return false
+	}
+	_, err := version.NewConstraint(v)
+	return err == nil
+}
+
+// resolveEngineVersion pins the version of the engine for the generation. Version constraints, and `latest`
+// when the controller knows the available versions, are resolved to the newest available version.
+func (r ReconcileTerraform) resolveEngineVersion(ctx context.Context, tf *tfv1alpha2.Terraform) error {
+	if tf.Status.ResolvedEngineVersion != "" {
+		return nil
+	}
+	engine, requested := getRequestedEngine(tf)
+	if terraformImageDigest(tf) != "" {
+		// The digest pins the image, the version is its tag
+		tf.Status.ResolvedEngineVersion = requested
+		return nil
+	}
+	constraint := requested
+	if requested == "latest" {
+		if r.StrictEngineVersions {
+			return fmt.Errorf("the latest version of %s is not allowed, set a version or a version constraint", engine)
+		}
+		constraint = ""
+	} else if !isVersionConstraint(requested) {
+		tf.Status.ResolvedEngineVersion = requested
+		return nil
+	}
+
+	if r.EngineVersionsConfigMap == nil && !r.EngineVersionsFromRegistry {
+		if requested == "latest" {
+			tf.Status.ResolvedEngineVersion = requested
+			return nil
+		}
+		return fmt.Errorf("the %s version constraint '%s' can not be resolved since the controller does not list the available versions", engine, requested)
+	}
+	available, err := r.listEngineVersions(ctx, tf, engine)
+	if err != nil {
+		return fmt.Errorf("could not list the versions of %s: %v", engine, err)
+	}
+	selected, err := selectVersion(available, constraint)
+	if err != nil {
+		return fmt.Errorf("could not select a version of %s: %v", engine, err)
+	}
+	r.Recorder.Event(tf, "Normal", "EngineVersionResolved", fmt.Sprintf("Using %s %s for '%s'", engine, selected, requested))
+	tf.Status.ResolvedEngineVersion = selected
+	return nil
+}
+
+// listEngineVersions returns the versions of the engine listed in the controller's ConfigMap or, when it is
+// not configured, the tags of the resource's terraform image
+func (r ReconcileTerraform) listEngineVersions(ctx context.Context, tf *tfv1alpha2.Terraform, engine tfv1alpha2.EngineName) ([]string, error) {
+	if r.EngineVersionsConfigMap != nil {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, *r.EngineVersionsConfigMap, configMap); err != nil {
+			return nil, err
+		}
+		versions := strings.FieldsFunc(configMap.Data[string(engine)], func(c rune) bool {
+			return c == ',' || unicode.IsSpace(c)
+		})
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("the ConfigMap '%s' does not list any version", r.EngineVersionsConfigMap)
+		}
+		return versions, nil
+	}
+	httpClient, err := r.getHTTPClient(ctx, tf)
+	if err != nil {
+		return nil, err
+	}
+	return listImageTags(ctx, httpClient, terraformImageRepo(tf, engine))
+}
+
+// imageRegistryRepo splits an image, without its tag, into the host of its registry and its repository.
+// Images without a registry are on docker hub.
+func imageRegistryRepo(image string) (string, string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		image = "library/" + image
+	}
+	return "registry-1.docker.io", image
+}
+
+// listImageTags lists the tags of the image with the registry api. Registries that require a token, like
+// docker hub and ghcr.io, are asked for an anonymous pull token.
+func listImageTags(ctx context.Context, httpClient *http.Client, image string) ([]string, error) {
+	host, repo := imageRegistryRepo(image)
+	next := &url.URL{Scheme: "https", Host: host, Path: "/v2/" + repo + "/tags/list"}
+	token := ""
+	tags := []string{}
+	for page := 0; next != nil && page < maxImageTagPages; page++ {
+		resp, err := imageRegistryGet(ctx, httpClient, next.String(), token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			token, err = getImagePullToken(ctx, httpClient, challenge)
+			if err != nil {
+				return nil, err
+			}
+			page--
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s returned %s", next, resp.Status)
+		}
+		list := struct {
+			Tags []string `json:"tags"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read the tags of '%s': %v", image, err)
+		}
+		tags = append(tags, list.Tags...)
+
+		current := next
+		next = nil
+		if match := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			link, err := url.Parse(match[1])
+			if err != nil {
+				return nil, err
+			}
+			next = current.ResolveReference(link)
+		}
+	}
+	return tags, nil
+}
+
+func imageRegistryGet(ctx context.Context, httpClient *http.Client, u, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpClient.Do(req)
+}
+
+// getImagePullToken gets an anonymous token from the realm of the registry's bearer challenge
+func getImagePullToken(ctx context.Context, httpClient *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("the registry requires unsupported credentials '%s'", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range authParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(match[1])] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("the registry's challenge '%s' has no realm", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+	resp, err := imageRegistryGet(ctx, httpClient, realm.String(), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("GET %s returned %s", realm, resp.Status)
+	}
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not read the token of the registry: %v", err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", fmt.Errorf("the registry did not return a token")
+	}
+	return body.Token, nil
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveEngineVersion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tf-system", Name: "engine-versions"},
+		Data:       map[string]string{"terraform": "1.4.6\n1.5.5, 1.5.7\n1.6.0-rc1 1.6.0\n"},
+	}
+	r := ReconcileTerraform{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{})
+	resolve := func(version string) (string, error) {
+		tf.Spec.TerraformVersion = version
+		tf.Status.ResolvedEngineVersion = ""
+		err := r.resolveEngineVersion(context.TODO(), tf)
+		return tf.Status.ResolvedEngineVersion, err
+	}
+
+	// Versions and tags are used as is
+	for _, version := range []string{"1.5.7", "1.5", "1.5.7-alpine", "light", "latest", ""} {
+		want := version
+		if want == "" {
+			want = "latest"
+		}
+		if got, err := resolve(version); err != nil || got != want {
+			t.Errorf("expected %q to be used as is, got %q %v", version, got, err)
+		}
+	}
+	if _, err := resolve("~> 1.5.0"); err == nil || !strings.Contains(err.Error(), "does not list the available versions") {
+		t.Errorf("expected the constraint to require the available versions, got %v", err)
+	}
+	r.StrictEngineVersions = true
+	for _, version := range []string{"latest", ""} {
+		if _, err := resolve(version); err == nil {
+			t.Errorf("expected %q to be rejected by the strict mode", version)
+		}
+	}
+	r.StrictEngineVersions = false
+
+	r.EngineVersionsConfigMap = &types.NamespacedName{Namespace: "tf-system", Name: "engine-versions"}
+	for version, want := range map[string]string{"~> 1.5.0": "1.5.7", ">= 1.4, < 1.5": "1.4.6", "latest": "1.6.0", "1.2.3": "1.2.3"} {
+		if got, err := resolve(version); err != nil || got != want {
+			t.Errorf("expected %q to resolve to %s, got %q %v", version, want, got, err)
+		}
+	}
+	if _, err := resolve("~> 2.0"); err == nil {
+		t.Errorf("expected a constraint without a matching version to fail")
+	}
+
+	// The version is pinned for the generation
+	if _, err := resolve("~> 1.5.0"); err != nil {
+		t.Fatal(err)
+	}
+	configMap.Data["terraform"] = "1.5.8"
+	if err := r.Client.Update(context.TODO(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.resolveEngineVersion(context.TODO(), tf); err != nil || tf.Status.ResolvedEngineVersion != "1.5.7" {
+		t.Errorf("expected the resolved version to be kept, got %s %v", tf.Status.ResolvedEngineVersion, err)
+	}
+	runOpts := newTaskOptions(tf, tfv1alpha2.RunPlan, 1, nil)
+	if runOpts.image != tfv1alpha2.TerraformTaskImageRepoDefault+":1.5.7" || runOpts.resourceLabels["terraforms.tf.isaaguilar.com/terraformVersion"] != "1.5.7" {
+		t.Errorf("expected the tasks to run the resolved version, got %s %v", runOpts.image, runOpts.resourceLabels)
+	}
+	newStage(tf, tfv1alpha2.RunSetup, "GENERATION_CHANGE", tfv1alpha2.CanNotBeInterrupt, tfv1alpha2.StateInitializing)
+	if tf.Status.ResolvedEngineVersion != "" {
+		t.Errorf("expected a new generation to resolve the version again")
+	}
+
+	tf.Spec.Engine = &tfv1alpha2.Engine{Name: tfv1alpha2.EngineOpenTofu, Version: "~> 1.7"}
+	if err := r.resolveEngineVersion(context.TODO(), tf); err == nil {
+		t.Errorf("expected an engine missing from the ConfigMap to fail")
+	}
+}
+
+func TestListImageTags(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if req.URL.Query().Get("scope") != "repository:org/tofutask:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token": "t0ken"}`)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:org/tofutask:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Path != "/v2/org/tofutask/tags/list" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/org/tofutask/tags/list?last=1.7.0&n=2>; rel="next"`)
+			fmt.Fprint(w, `{"name": "org/tofutask", "tags": ["1.6.2", "1.7.0"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "org/tofutask", "tags": ["1.7.2", "latest"]}`)
+	}))
+	defer server.Close()
+	defaultClient := registryHTTPClient
+	registryHTTPClient = server.Client()
+	defer func() { registryHTTPClient = defaultClient }()
+
+	host := server.Listener.Addr().String()
+	tags, err := listImageTags(context.TODO(), server.Client(), host+"/org/tofutask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tags, ","); got != "1.6.2,1.7.0,1.7.2,latest" {
+		t.Errorf("expected the tags of every page, got %s", got)
+	}
+
+	r := ReconcileTerraform{Recorder: record.NewFakeRecorder(10), EngineVersionsFromRegistry: true}
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		Engine: &tfv1alpha2.Engine{Name: tfv1alpha2.EngineOpenTofu, Version: "~> 1.7.0"},
+		Images: &tfv1alpha2.Images{Terraform: &tfv1alpha2.ImageConfig{Image: host + "/org/tofutask:1.6.2"}},
+	})
+	if err := r.resolveEngineVersion(context.TODO(), tf); err != nil || tf.Status.ResolvedEngineVersion != "1.7.2" {
+		t.Errorf("expected the constraint to resolve to the newest tag, got %s %v", tf.Status.ResolvedEngineVersion, err)
+	}
+
+	for image, want := range map[string][2]string{
+		"hashicorp/terraform":              {"registry-1.docker.io", "hashicorp/terraform"},
+		"alpine":                           {"registry-1.docker.io", "library/alpine"},
+		"ghcr.io/galleybytes/tftaskv1":     {"ghcr.io", "galleybytes/tftaskv1"},
+		"localhost/tftask":                 {"localhost", "tftask"},
+		"registry.example.com:5000/a/b/tf": {"registry.example.com:5000", "a/b/tf"},
+	} {
+		if host, repo := imageRegistryRepo(image); host != want[0] || repo != want[1] {
+			t.Errorf("%s: expected %v, got %s %s", image, want, host, repo)
+		}
+	}
+}
+
+func TestVersionLabelValue(t *testing.T) {
+	longTag := "1.5.7-" + strings.Repeat("custom-", 17) + "build"
+	tests := []struct{ version, want string }{
+		{"1.5.7", "1.5.7"},
+		{"1.6.0+ent", "1.6.0_ent"},
+		{"latest", "latest"},
+		{longTag, strings.TrimRight(longTag[:63], "-")},
+		{"-rc.1-", "rc.1"},
+	}
+	for _, tt := range tests {
+		got := versionLabelValue(tt.version)
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.version, tt.want, got)
+		}
+		if errs := validation.IsValidLabelValue(got); len(errs) != 0 {
+			t.Errorf("%s: expected a valid label value, got %v", tt.version, errs)
+		}
+	}
+
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.6.0+ent"})
+	runOpts := newTaskOptions(tf, tfv1alpha2.RunInit, 1, nil)
+	for _, label := range []string{"terraforms.tf.isaaguilar.com/engineVersion", "terraforms.tf.isaaguilar.com/terraformVersion"} {
+		if got := runOpts.resourceLabels[label]; got != "1.6.0_ent" {
+			t.Errorf("expected the %s label to be sanitized, got %s", label, got)
+		}
+	}
+}
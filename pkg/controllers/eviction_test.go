@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRestartAfterEviction(t *testing.T) {
+	tests := []struct {
+		name              string
+		phase             tfv1alpha2.StatusPhase
+		task              tfv1alpha2.TaskName
+		terminationReason string
+		podExists         bool
+		wantReason        string
+		wantTask          tfv1alpha2.TaskName
+	}{
+		{name: "evicted apply", phase: tfv1alpha2.PhaseRunning, task: tfv1alpha2.RunApply, terminationReason: "Evicted", podExists: true, wantReason: "RESTARTED_AFTER_EVICTION", wantTask: tfv1alpha2.RunInit},
+		{name: "evicted plan", phase: tfv1alpha2.PhaseRunning, task: tfv1alpha2.RunPlan, terminationReason: "Evicted", podExists: true, wantReason: "RESTARTED_AFTER_EVICTION", wantTask: tfv1alpha2.RunInit},
+		{name: "evicted apply-delete", phase: tfv1alpha2.PhaseDeleting, task: tfv1alpha2.RunApplyDelete, terminationReason: "Evicted", podExists: true, wantReason: "RESTARTED_AFTER_EVICTION", wantTask: tfv1alpha2.RunInitDelete},
+		{name: "evicted interruptible task", phase: tfv1alpha2.PhaseRunning, task: tfv1alpha2.RunPostPlan, terminationReason: "Evicted", podExists: true},
+		{name: "failed apply", phase: tfv1alpha2.PhaseRunning, task: tfv1alpha2.RunApply, terminationReason: "Error", podExists: true},
+		{name: "deleted apply pod", phase: tfv1alpha2.PhaseRunning, task: tfv1alpha2.RunApply, terminationReason: "Error", wantReason: "RESTARTED_WORKFLOW", wantTask: tfv1alpha2.RunPlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = tfv1alpha2.AddToScheme(scheme)
+			tf := &tfv1alpha2.Terraform{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", Generation: 1},
+				Status: tfv1alpha2.TerraformStatus{
+					Phase: tt.phase,
+					Stage: tfv1alpha2.Stage{
+						Generation:        1,
+						TaskType:          tt.task,
+						Interruptible:     isTaskInterruptable(tt.task),
+						State:             tfv1alpha2.StateFailed,
+						TerminationReason: tt.terminationReason,
+						PodName:           "app-abc-v1-" + tt.task.String(),
+					},
+				},
+			}
+			objects := []runtime.Object{tf}
+			if tt.podExists {
+				objects = append(objects, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: tf.Status.Stage.PodName}})
+			}
+			r := ReconcileTerraform{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			stage := r.checkSetNewStage(context.TODO(), tf)
+			if tt.wantReason == "" {
+				if stage != nil {
+					t.Errorf("expected the failed task to not restart, got %s %s", stage.Reason, stage.TaskType)
+				}
+				return
+			}
+			if stage == nil {
+				t.Fatalf("expected a %s stage", tt.wantReason)
+			}
+			if stage.Reason != tt.wantReason || stage.TaskType != tt.wantTask {
+				t.Errorf("expected %s %s, got %s %s", tt.wantReason, tt.wantTask, stage.Reason, stage.TaskType)
+			}
+			if stage.Interruptible != isTaskInterruptable(tt.wantTask) {
+				t.Errorf("expected the %s stage to be %v, got %v", tt.wantTask, isTaskInterruptable(tt.wantTask), stage.Interruptible)
+			}
+		})
+	}
+}
+
+func TestUninterruptibleTasks(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.5.7"})
+
+	tests := []struct {
+		task            tfv1alpha2.TaskName
+		uninterruptible bool
+	}{
+		{task: tfv1alpha2.RunSetup},
+		{task: tfv1alpha2.RunInit, uninterruptible: true},
+		{task: tfv1alpha2.RunPlan, uninterruptible: true},
+		{task: tfv1alpha2.RunPostPlan},
+		{task: tfv1alpha2.RunApply, uninterruptible: true},
+		{task: tfv1alpha2.RunSetupDelete},
+		{task: tfv1alpha2.RunApplyDelete, uninterruptible: true},
+	}
+	for _, tt := range tests {
+		pod := newTaskOptions(tf, tt.task, 1, nil).generatePod()
+		_, labeled := pod.Labels["terraforms.tf.isaaguilar.com/uninterruptible"]
+		safeToEvict, annotated := pod.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"]
+		if labeled != tt.uninterruptible || annotated != tt.uninterruptible {
+			t.Errorf("%s: expected the uninterruptible label and annotation %t, got %t and %t", tt.task, tt.uninterruptible, labeled, annotated)
+		}
+		if annotated && safeToEvict != "false" {
+			t.Errorf("%s: expected the pod to not be safe to evict, got %s", tt.task, safeToEvict)
+		}
+	}
+}
+
+func TestTaskPodDisruptionBudget(t *testing.T) {
+	// The budget only selects the uninterruptible pods of the resource
+	runOpts := newTaskOptions(newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.5.7"}), tfv1alpha2.RunApply, 1, nil)
+	pdb := runOpts.generatePodDisruptionBudget()
+	if pdb.Name != runOpts.prefixedName || pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.IntValue() != 0 {
+		t.Errorf("expected a budget named %s without unavailable pods, got %s %v", runOpts.prefixedName, pdb.Name, pdb.Spec.MaxUnavailable)
+	}
+	pod := runOpts.generatePod()
+	for key, value := range pdb.Spec.Selector.MatchLabels {
+		if pod.Labels[key] != value {
+			t.Errorf("expected the apply pod to be selected by %s=%s, got %q", key, value, pod.Labels[key])
+		}
+	}
+	if _, found := pdb.Spec.Selector.MatchLabels["terraforms.tf.isaaguilar.com/uninterruptible"]; !found {
+		t.Errorf("expected the budget to only select uninterruptible pods, got %v", pdb.Spec.Selector.MatchLabels)
+	}
+}
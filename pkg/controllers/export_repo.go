@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/gitclient"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	// planSummaryLine matches the lines of a plan that describe what changes, eg
+	// "  # aws_instance.web will be created" and "Plan: 1 to add, 0 to change, 0 to destroy."
+	planSummaryLine = regexp.MustCompile(`^\s*# \S+ (will|must) be |^Plan: |^No changes\.|^Changes to Outputs:`)
+)
+
+// getExportPaths returns the paths in the export repo of the tfvars, backend and plan summary files
+func getExportPaths(tf *tfv1alpha2.Terraform) (string, string, string) {
+	dir := filepath.Join(tf.Namespace, tf.Name)
+	tfvarsFile := tf.Spec.ExportRepo.TFVarsFile
+	if tfvarsFile == "" {
+		tfvarsFile = filepath.Join(dir, "terraform.tfvars")
+	}
+	confFile := tf.Spec.ExportRepo.ConfFile
+	if confFile == "" {
+		confFile = filepath.Join(dir, "backend.tf")
+	}
+	planSummaryFile := tf.Spec.ExportRepo.PlanSummaryFile
+	if planSummaryFile == "" {
+		planSummaryFile = filepath.Join(dir, "plan.txt")
+	}
+	clean := func(path string) string {
+		// Paths are relative to the root of the repo
+		return filepath.Clean(strings.TrimLeft(path, "/"))
+	}
+	return clean(tfvarsFile), clean(confFile), clean(planSummaryFile)
+}
+
+// getExportedTFVars joins the tfvars files rendered for the generation in the order terraform loads them.
+// Variables from Secrets are never exported.
+func getExportedTFVars(data map[string]string) string {
+	keys := []string{}
+	for key := range data {
+		if strings.HasSuffix(key, ".tfvars") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	tfvars := ""
+	for _, key := range keys {
+		tfvars += fmt.Sprintf("# %s\n%s", key, data[key])
+		if !strings.HasSuffix(tfvars, "\n") {
+			tfvars += "\n"
+		}
+	}
+	return tfvars
+}
+
+// getPlanSummary returns the lines of the plan task's log that describe the changes
+func getPlanSummary(log string) string {
+	lines := []string{}
+	for _, line := range strings.Split(ansiEscape.ReplaceAllString(log, ""), "\n") {
+		if planSummaryLine.MatchString(line) {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// exportRepo commits the generation's variables, backend and plan summary to the export repo and returns
+// the sha of the commit.
+func (r ReconcileTerraform) exportRepo(ctx context.Context, tf *tfv1alpha2.Terraform, pod *corev1.Pod, runOpts TaskOptions) (string, error) {
+	exportRepo := tf.Spec.ExportRepo
+	scmMap := make(map[string]scmType)
+	for _, v := range tf.Spec.SCMAuthMethods {
+		if v.Git != nil {
+			scmMap[v.Host] = gitScmType
+		}
+	}
+	parsedAddress, err := getParsedAddress(exportRepo.Address, "", false, scmMap)
+	if err != nil {
+		return "", err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: tf.Namespace, Name: runOpts.versionedName}, configMap)
+	if err != nil {
+		return "", err
+	}
+	planSummary := ""
+	if r.Clientset != nil {
+		b, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container: "task",
+		}).DoRaw(ctx)
+		if err != nil {
+			return "", err
+		}
+		planSummary = maskSensitiveValues(getPlanSummary(string(b)), r.getSensitiveValues(ctx, tf, runOpts))
+	}
+
+	dir, err := ioutil.TempDir("", "tfo-export-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	gitRepo, err := r.newGitRepo(ctx, tf, parsedAddress)
+	if err != nil {
+		return "", err
+	}
+	gitRepo.SetAuthor(exportRepo.GitUsername, exportRepo.GitEmail)
+	err = gitRepo.CloneBranch(parsedAddress.Repo, dir, exportRepo.Branch)
+	if err != nil {
+		return "", err
+	}
+
+	tfvarsFile, confFile, planSummaryFile := getExportPaths(tf)
+	files := map[string]string{
+		tfvarsFile:      getExportedTFVars(configMap.Data),
+		confFile:        configMap.Data["backend_override.tf"],
+		planSummaryFile: planSummary,
+	}
+	filenames := []string{}
+	for filename, content := range files {
+		if strings.HasPrefix(filename, "..") {
+			return "", fmt.Errorf("export path '%s' is outside of the repo", filename)
+		}
+		err := os.MkdirAll(filepath.Join(dir, filepath.Dir(filename)), 0755)
+		if err != nil {
+			return "", err
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, filename), []byte(content), 0644)
+		if err != nil {
+			return "", err
+		}
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	message := fmt.Sprintf("Export %s/%s generation %d", tf.Namespace, tf.Name, tf.Generation)
+	err = gitRepo.Commit(filenames, message)
+	if err == gitclient.ErrNoChanges {
+		return gitRepo.HashString()
+	}
+	if err != nil {
+		return "", err
+	}
+	err = gitRepo.Push("")
+	if err != nil {
+		return "", err
+	}
+	return gitRepo.HashString()
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetExportPaths(t *testing.T) {
+	tf := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			ExportRepo: &tfv1alpha2.ExportRepo{Address: "git@github.com:org/exports.git", ConfFile: "/envs/app/backend.tf"},
+		},
+	}
+	tfvarsFile, confFile, planSummaryFile := getExportPaths(tf)
+	if tfvarsFile != "default/app/terraform.tfvars" {
+		t.Errorf("expected the default tfvars file, got %s", tfvarsFile)
+	}
+	if confFile != "envs/app/backend.tf" {
+		t.Errorf("expected the configured conf file, got %s", confFile)
+	}
+	if planSummaryFile != "default/app/plan.txt" {
+		t.Errorf("expected the default plan summary file, got %s", planSummaryFile)
+	}
+}
+
+func TestGetExportedTFVars(t *testing.T) {
+	data := map[string]string{
+		"backend_override.tf":               "terraform {}",
+		"tfo-variables.auto.tfvars":         "region = \"us-east-1\"\n",
+		"tfo-variables-file-00.auto.tfvars": "instance_type = \"m5.large\"",
+	}
+	want := "# tfo-variables-file-00.auto.tfvars\ninstance_type = \"m5.large\"\n# tfo-variables.auto.tfvars\nregion = \"us-east-1\"\n"
+	if got := getExportedTFVars(data); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestGetPlanSummary(t *testing.T) {
+	log := "Initializing the backend...\n" +
+		"Terraform will perform the following actions:\n\n" +
+		"\x1b[1m  # aws_instance.web\x1b[0m will be created\n" +
+		"  + resource \"aws_instance\" \"web\" {\n" +
+		"      + ami = \"ami-123\"\n" +
+		"    }\n\n" +
+		"\x1b[1m  # aws_s3_bucket.logs\x1b[0m must be replaced\n" +
+		"\x1b[1mPlan:\x1b[0m 2 to add, 0 to change, 1 to destroy.\n"
+	want := "# aws_instance.web will be created\n# aws_s3_bucket.logs must be replaced\nPlan: 2 to add, 0 to change, 1 to destroy.\n"
+	if got := getPlanSummary(log); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := getPlanSummary("No changes. Your infrastructure matches the configuration.\n"); got != "No changes. Your infrastructure matches the configuration.\n" {
+		t.Errorf("expected the no changes line, got %q", got)
+	}
+}
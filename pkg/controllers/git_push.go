@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/gitclient"
+	"github.com/isaaguilar/terraform-operator/pkg/webhook/gitpush"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gitPushAnnotation is set by the git webhook to the `<ref>@<commit>` of a push that affects the resource.
+// The workflow is re-run when it does not match status.lastGitPush.
+const gitPushAnnotation = "terraforms.tf.isaaguilar.com/gitPush"
+
+// hasPendingGitPush returns true when a push was received after the current run started
+func hasPendingGitPush(tf *tfv1alpha2.Terraform) bool {
+	push := tf.Annotations[gitPushAnnotation]
+	return push != "" && push != tf.Status.LastGitPush
+}
+
+// normalizeRepoURL reduces the different urls of a repo, eg https, ssh and scp-like urls, to `host/path`
+func normalizeRepoURL(repo string) string {
+	repo = strings.TrimPrefix(strings.TrimSpace(repo), "git::")
+	if !strings.Contains(repo, "://") {
+		// scp-like, eg git@github.com:org/repo.git
+		repo = "ssh://" + strings.Replace(repo, ":", "/", 1)
+	}
+	u, err := url.Parse(repo)
+	if err != nil {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Hostname() + "/" + path)
+}
+
+// getGitSources returns the git sources of the module and resource downloads
+func getGitSources(tf *tfv1alpha2.Terraform) []ParsedAddress {
+	scmMap := make(map[string]scmType)
+	for _, v := range tf.Spec.SCMAuthMethods {
+		if v.Git != nil {
+			scmMap[v.Host] = gitScmType
+		}
+	}
+	addresses := []string{}
+	if tf.Spec.TerraformModule.Source != "" {
+		addresses = append(addresses, tf.Spec.TerraformModule.Source)
+	}
+	if tf.Spec.Setup != nil {
+		for _, s := range tf.Spec.Setup.ResourceDownloads {
+			addresses = append(addresses, strings.TrimSpace(s.Address))
+		}
+	}
+	sources := []ParsedAddress{}
+	for _, address := range addresses {
+		parsedAddress, err := getParsedAddress(address, "", false, scmMap)
+		if err != nil || parsedAddress.DetectedScheme != "git" {
+			continue
+		}
+		sources = append(sources, parsedAddress)
+	}
+	return sources
+}
+
+// isAffectedByPush returns true when one of the git sources follows the pushed ref of the repo. Sources
+// without a ref follow the default branch and sources pinned to a commit are never affected.
+func isAffectedByPush(tf *tfv1alpha2.Terraform, event gitpush.PushEvent) bool {
+	repos := map[string]bool{}
+	for _, u := range event.RepoURLs {
+		repos[normalizeRepoURL(u)] = true
+	}
+	refName := strings.TrimPrefix(strings.TrimPrefix(event.Ref, "refs/heads/"), "refs/tags/")
+	for _, source := range getGitSources(tf) {
+		if gitclient.IsCommitHash(source.Hash) || !repos[normalizeRepoURL(source.Repo)] {
+			continue
+		}
+		if source.Hash == "" {
+			if event.DefaultBranch != "" && event.Ref == "refs/heads/"+event.DefaultBranch {
+				return true
+			}
+			continue
+		}
+		if source.Hash == refName || source.Hash == event.Ref {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggerGitPush marks the resources affected by a git push so the controller re-runs their workflow
+func (r ReconcileTerraform) TriggerGitPush(ctx context.Context, event gitpush.PushEvent) ([]string, error) {
+	triggered := []string{}
+	tfList := &tfv1alpha2.TerraformList{}
+	err := r.Client.List(ctx, tfList)
+	if err != nil {
+		return triggered, err
+	}
+	push := fmt.Sprintf("%s@%s", event.Ref, event.Commit)
+	for i := range tfList.Items {
+		tf := &tfList.Items[i]
+		if !isAffectedByPush(tf, event) || tf.Annotations[gitPushAnnotation] == push {
+			continue
+		}
+		patch := client.MergeFrom(tf.DeepCopy())
+		if tf.Annotations == nil {
+			tf.Annotations = make(map[string]string)
+		}
+		tf.Annotations[gitPushAnnotation] = push
+		err := r.Client.Patch(ctx, tf, patch)
+		if err != nil {
+			return triggered, err
+		}
+		triggered = append(triggered, fmt.Sprintf("%s/%s", tf.Namespace, tf.Name))
+	}
+	return triggered, nil
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/webhook/gitpush"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNormalizeRepoURL(t *testing.T) {
+	for _, u := range []string{
+		"https://github.com/Octo-Org/infra.git",
+		"git@github.com:octo-org/infra.git",
+		"ssh://git@github.com/octo-org/infra.git",
+		"ssh://git@github.com:22/octo-org/infra",
+		"https://github.com/octo-org/infra/",
+	} {
+		if got := normalizeRepoURL(u); got != "github.com/octo-org/infra" {
+			t.Errorf("%s: expected github.com/octo-org/infra, got %s", u, got)
+		}
+	}
+}
+
+func TestTriggerGitPush(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	newTerraform := func(name, source string, downloads ...string) *tfv1alpha2.Terraform {
+		tf := &tfv1alpha2.Terraform{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: tfv1alpha2.TerraformSpec{
+				TerraformModule: tfv1alpha2.Module{Source: source},
+				Setup:           &tfv1alpha2.Setup{},
+			},
+		}
+		for _, address := range downloads {
+			tf.Spec.Setup.ResourceDownloads = append(tf.Spec.Setup.ResourceDownloads, tfv1alpha2.ResourceDownload{Address: address})
+		}
+		return tf
+	}
+	objects := []*tfv1alpha2.Terraform{
+		newTerraform("main", "https://github.com/octo-org/infra.git?ref=main"),
+		newTerraform("release", "git@github.com:octo-org/infra.git?ref=release"),
+		newTerraform("pinned", "https://github.com/octo-org/infra.git?ref=59b20b8d5c6ff8d09518454d4dd8b7a30f095ab5"),
+		newTerraform("download", "https://github.com/octo-org/other.git?ref=v1", "git::ssh://git@github.com/octo-org/infra.git//vars?ref=main"),
+		newTerraform("other", "https://github.com/octo-org/other.git?ref=main"),
+		// Sources without a ref follow the default branch
+		newTerraform("unpinned", "https://github.com/octo-org/infra.git"),
+		newTerraform("unpinned-download", "https://github.com/octo-org/other.git?ref=v1", "git::ssh://git@github.com/octo-org/infra.git//vars"),
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, obj := range objects {
+		builder = builder.WithObjects(obj)
+	}
+	r := ReconcileTerraform{Client: builder.Build()}
+
+	event := gitpush.PushEvent{
+		Provider:      "github",
+		Ref:           "refs/heads/main",
+		Commit:        "59b20b8d5c6ff8d09518454d4dd8b7a30f095ab5",
+		RepoURLs:      []string{"https://github.com/octo-org/infra.git", "git@github.com:octo-org/infra.git"},
+		DefaultBranch: "main",
+	}
+	triggered, err := r.TriggerGitPush(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"default/download", "default/main", "default/unpinned", "default/unpinned-download"}
+	if !reflect.DeepEqual(triggered, want) {
+		t.Errorf("expected %v, got %v", want, triggered)
+	}
+
+	tf := &tfv1alpha2.Terraform{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "main"}, tf); err != nil {
+		t.Fatal(err)
+	}
+	if !hasPendingGitPush(tf) {
+		t.Errorf("expected a pending push, got annotations %v", tf.Annotations)
+	}
+	tf.Status.LastGitPush = tf.Annotations[gitPushAnnotation]
+	if hasPendingGitPush(tf) {
+		t.Error("expected the push to be handled")
+	}
+
+	// The same push is not triggered twice
+	triggered, err = r.TriggerGitPush(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggered) != 0 {
+		t.Errorf("expected nothing to be triggered, got %v", triggered)
+	}
+
+	// A push to another branch does not affect the sources following the default branch
+	event.Ref = "refs/heads/release"
+	triggered, err = r.TriggerGitPush(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"default/release"}
+	if !reflect.DeepEqual(triggered, want) {
+		t.Errorf("expected %v, got %v", want, triggered)
+	}
+}
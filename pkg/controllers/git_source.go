@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/gitclient"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// gitPollTick is how often the poller looks for resources that are due to check their git source
+	gitPollTick = 30 * time.Second
+
+	minGitPollInterval = time.Minute
+)
+
+// getGitPollInterval returns how often the resource's git source is polled. Returns 0 when polling is
+// disabled.
+func getGitPollInterval(tf *tfv1alpha2.Terraform) time.Duration {
+	if tf.Spec.TerraformModule.PollInterval == nil || tf.Spec.TerraformModule.PollInterval.Duration <= 0 {
+		return 0
+	}
+	if tf.Spec.TerraformModule.PollInterval.Duration < minGitPollInterval {
+		return minGitPollInterval
+	}
+	return tf.Spec.TerraformModule.PollInterval.Duration
+}
+
+// isGitPollDue returns true when the resource has not polled its git source within the poll interval.
+func (r ReconcileTerraform) isGitPollDue(tf *tfv1alpha2.Terraform) bool {
+	interval := getGitPollInterval(tf)
+	if interval == 0 || r.Cache == nil {
+		return false
+	}
+	key := fmt.Sprintf("%s/%s-git-poll", tf.Namespace, tf.Name)
+	if _, found := r.Cache.Get(key); found {
+		return false
+	}
+	r.Cache.Set(key, time.Now().String(), interval)
+	return true
+}
+
+// resolveModuleCommit resolves the ref of the module's git source to a commit sha using the scm auth
+// methods configured for the host. An empty string is returned when the module is not from git.
+func (r ReconcileTerraform) resolveModuleCommit(ctx context.Context, tf *tfv1alpha2.Terraform) (string, error) {
+	if tf.Spec.TerraformModule.Source == "" {
+		return "", nil
+	}
+	scmMap := make(map[string]scmType)
+	for _, v := range tf.Spec.SCMAuthMethods {
+		if v.Git != nil {
+			scmMap[v.Host] = gitScmType
+		}
+	}
+	parsedAddress, err := getParsedAddress(tf.Spec.TerraformModule.Source, "", false, scmMap)
+	if err != nil {
+		return "", err
+	}
+	if parsedAddress.DetectedScheme != "git" {
+		return "", nil
+	}
+	return r.resolveGitCommit(ctx, tf, parsedAddress)
+}
+
+func (r ReconcileTerraform) resolveGitCommit(ctx context.Context, tf *tfv1alpha2.Terraform, parsedAddress ParsedAddress) (string, error) {
+	if gitclient.IsCommitHash(parsedAddress.Hash) {
+		return parsedAddress.Hash, nil
+	}
+	gitRepo, err := r.newGitRepo(ctx, tf, parsedAddress)
+	if err != nil {
+		return "", err
+	}
+	return gitRepo.ResolveRef(parsedAddress.Repo, parsedAddress.Hash)
+}
+
+// newGitRepo returns a git client using the scm auth method configured for the host of the address
+func (r ReconcileTerraform) newGitRepo(ctx context.Context, tf *tfv1alpha2.Terraform, parsedAddress ParsedAddress) (gitclient.GitRepo, error) {
+	host := parsedAddress.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	password := ""
+	sshKey := []byte{}
+	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Git == nil || (m.Host != parsedAddress.Host && m.Host != host) {
+			continue
+		}
+		if parsedAddress.UrlScheme == "ssh" && m.Git.SSH != nil && m.Git.SSH.SSHKeySecretRef != nil {
+			if m.Git.SSH.RequireProxy {
+				return gitclient.GitRepo{}, fmt.Errorf("'%s' requires the ssh tunnel which the controller does not use", m.Host)
+			}
+			ref := m.Git.SSH.SSHKeySecretRef
+			key := ref.Key
+			if key == "" {
+				key = "id_rsa"
+			}
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = tf.Namespace
+			}
+			value, err := loadPassword(ctx, r.Client, key, ref.Name, namespace)
+			if err != nil {
+				return gitclient.GitRepo{}, err
+			}
+			sshKey = []byte(value)
+		}
+		if parsedAddress.UrlScheme != "ssh" && m.Git.HTTPS != nil && m.Git.HTTPS.TokenSecretRef != nil {
+			ref := m.Git.HTTPS.TokenSecretRef
+			key := ref.Key
+			if key == "" {
+				key = "token"
+			}
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = tf.Namespace
+			}
+			value, err := loadPassword(ctx, r.Client, key, ref.Name, namespace)
+			if err != nil {
+				return gitclient.GitRepo{}, err
+			}
+			password = value
+		}
+	}
+
+	gitRepo, err := gitclient.NewGitRepoWithSSHKey("git", password, sshKey, r.Log)
+	if err != nil {
+		return gitRepo, err
+	}
+	trustedCA, err := r.getTrustedCA(ctx, tf)
+	if err != nil {
+		return gitRepo, err
+	}
+	return gitRepo, gitRepo.SetTrustedCA(trustedCA)
+}
+
+// pollGitSources runs in the background and queues the resources with a git poll interval so they check
+// their source. Whether the poll is due is decided by the reconciler.
+func (r ReconcileTerraform) pollGitSources(ctx context.Context) error {
+	ticker := time.NewTicker(gitPollTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			tfList := &tfv1alpha2.TerraformList{}
+			err := r.Client.List(ctx, tfList)
+			if err != nil {
+				r.Log.Error(err, "Failed to list resources to poll")
+				continue
+			}
+			for i := range tfList.Items {
+				if getGitPollInterval(&tfList.Items[i]) == 0 {
+					continue
+				}
+				select {
+				case r.gitPollEvents <- event.GenericEvent{Object: &tfList.Items[i]}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
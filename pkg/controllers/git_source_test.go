@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	localcache "github.com/patrickmn/go-cache"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newGitPollTerraform(interval *metav1.Duration) *tfv1alpha2.Terraform {
+	return &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			TerraformModule: tfv1alpha2.Module{Source: "https://github.com/org/vpc.git", PollInterval: interval},
+		},
+	}
+}
+
+func TestGetGitPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval *metav1.Duration
+		want     time.Duration
+	}{
+		{name: "unset", interval: nil, want: 0},
+		{name: "zero", interval: &metav1.Duration{}, want: 0},
+		{name: "negative", interval: &metav1.Duration{Duration: -time.Minute}, want: 0},
+		{name: "below minimum", interval: &metav1.Duration{Duration: 10 * time.Second}, want: minGitPollInterval},
+		{name: "set", interval: &metav1.Duration{Duration: 5 * time.Minute}, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := getGitPollInterval(newGitPollTerraform(tt.interval)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestIsGitPollDue(t *testing.T) {
+	interval := &metav1.Duration{Duration: 5 * time.Minute}
+
+	if (ReconcileTerraform{}).isGitPollDue(newGitPollTerraform(interval)) {
+		t.Error("expected no poll without a cache")
+	}
+
+	r := ReconcileTerraform{Cache: localcache.New(time.Minute, time.Minute)}
+	if r.isGitPollDue(newGitPollTerraform(nil)) {
+		t.Error("expected no poll when polling is disabled")
+	}
+
+	tf := newGitPollTerraform(interval)
+	if !r.isGitPollDue(tf) {
+		t.Error("expected the first poll to be due")
+	}
+	if r.isGitPollDue(tf) {
+		t.Error("expected no poll within the interval")
+	}
+
+	other := newGitPollTerraform(interval)
+	other.Name = "other"
+	if !r.isGitPollDue(other) {
+		t.Error("expected each resource to poll on its own schedule")
+	}
+
+	r.Cache.Delete("default/app-git-poll")
+	if !r.isGitPollDue(tf) {
+		t.Error("expected a poll once the interval has passed")
+	}
+}
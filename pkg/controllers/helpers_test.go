@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// terraformTaskNames are the tasks that run the engine. The other tasks in otherTaskNames must not get
+// the settings that only apply to the engine.
+var (
+	terraformTaskNames = []tfv1alpha2.TaskName{
+		tfv1alpha2.RunInit, tfv1alpha2.RunInitDelete,
+		tfv1alpha2.RunPlan, tfv1alpha2.RunPlanDelete,
+		tfv1alpha2.RunApply, tfv1alpha2.RunApplyDelete,
+	}
+	otherTaskNames = []tfv1alpha2.TaskName{
+		tfv1alpha2.RunSetup, tfv1alpha2.RunSetupDelete,
+		tfv1alpha2.RunPreInit, tfv1alpha2.RunPostPlan, tfv1alpha2.RunPostApplyDelete,
+	}
+)
+
+// newTestTerraform returns a default/app resource with a pod name prefix so its task pods can be
+// generated. The module defaults to a git source when the spec does not set one.
+func newTestTerraform(spec tfv1alpha2.TerraformSpec) *tfv1alpha2.Terraform {
+	if spec.TerraformModule.Source == "" && spec.TerraformModule.Inline == "" && spec.TerraformModule.ConfigMapSelector == nil {
+		spec.TerraformModule.Source = "https://github.com/org/vpc.git"
+	}
+	tf := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", Generation: 1},
+		Spec:       spec,
+	}
+	tf.Status.PodNamePrefix = "app-abc"
+	return tf
+}
+
+// newTestReconciler returns a reconciler backed by a fake client holding the objects.
+func newTestReconciler(objects ...client.Object) ReconcileTerraform {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+	return ReconcileTerraform{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Recorder: record.NewFakeRecorder(10),
+		Log:      logr.Discard(),
+	}
+}
+
+// taskContainer returns the task container of the pod generated for the first run of the task. Sidecars
+// come before the task container.
+func taskContainer(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName) corev1.Container {
+	containers := newTaskOptions(tf, task, 1, nil).generatePod().Spec.Containers
+	return containers[len(containers)-1]
+}
+
+// taskEnvs returns the envs of the task container of the pod generated for the task
+func taskEnvs(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName) map[string]string {
+	return podEnvs(taskContainer(tf, task))
+}
+
+func podEnvs(container corev1.Container) map[string]string {
+	envs := map[string]string{}
+	for _, env := range container.Env {
+		envs[env.Name] = env.Value
+	}
+	return envs
+}
+
+func hasVolumeMount(container corev1.Container, name string) bool {
+	for _, mount := range container.VolumeMounts {
+		if mount.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tasksWithEnv returns which of the terraform and other tasks of the resource get the env
+func tasksWithEnv(tf *tfv1alpha2.Terraform, name string) []tfv1alpha2.TaskName {
+	tasks := []tfv1alpha2.TaskName{}
+	for _, task := range append(append([]tfv1alpha2.TaskName{}, terraformTaskNames...), otherTaskNames...) {
+		if _, found := taskEnvs(tf, task)[name]; found {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
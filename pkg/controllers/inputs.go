@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	inputKindConfigMap = "configmap"
+	inputKindSecret    = "secret"
+
+	// missingInputHash is recorded for inputs that do not exist so creating the input is seen as a change
+	missingInputHash = "missing"
+)
+
+// inputKey formats the key used in status.inputHashes
+func inputKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// getInputReferences returns the keys of the ConfigMaps and Secrets referenced by the resource.
+func getInputReferences(tf *tfv1alpha2.Terraform) []string {
+	references := map[string]bool{}
+	add := func(kind, namespace, name string) {
+		if name == "" {
+			return
+		}
+		if namespace == "" {
+			namespace = tf.Namespace
+		}
+		references[inputKey(kind, namespace, name)] = true
+	}
+
+	if selector := tf.Spec.TerraformModule.ConfigMapSelector; selector != nil {
+		add(inputKindConfigMap, "", selector.Name)
+	}
+	for _, taskOption := range tf.Spec.TaskOptions {
+		for _, envFrom := range taskOption.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add(inputKindConfigMap, "", envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				add(inputKindSecret, "", envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range taskOption.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				add(inputKindConfigMap, "", env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				add(inputKindSecret, "", env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		if selector := taskOption.Script.ConfigMapSelector; selector != nil {
+			add(inputKindConfigMap, "", selector.Name)
+		}
+	}
+	if trustedCA := tf.Spec.TrustedCA; trustedCA != nil {
+		if trustedCA.ConfigMapKeyRef != nil {
+			add(inputKindConfigMap, "", trustedCA.ConfigMapKeyRef.Name)
+		}
+		if trustedCA.SecretKeyRef != nil {
+			add(inputKindSecret, "", trustedCA.SecretKeyRef.Name)
+		}
+	}
+	if tf.Spec.CLIConfig != nil {
+		for _, credentials := range tf.Spec.CLIConfig.Credentials {
+			add(inputKindSecret, credentials.TokenSecretRef.Namespace, credentials.TokenSecretRef.Name)
+		}
+	}
+	if tf.Spec.Engine != nil && tf.Spec.Engine.StateEncryption != nil {
+		add(inputKindSecret, "", tf.Spec.Engine.StateEncryption.ConfigSecretRef.Name)
+	}
+	for _, source := range getObjectSources(tf) {
+		add(source.Kind, "", source.Name)
+	}
+	for _, credential := range tf.Spec.Credentials {
+		add(inputKindSecret, credential.SecretNameRef.Namespace, credential.SecretNameRef.Name)
+	}
+	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Registry != nil && m.Registry.TokenSecretRef != nil {
+			add(inputKindSecret, m.Registry.TokenSecretRef.Namespace, m.Registry.TokenSecretRef.Name)
+		}
+		if m.Git == nil {
+			continue
+		}
+		if m.Git.HTTPS != nil && m.Git.HTTPS.TokenSecretRef != nil {
+			add(inputKindSecret, m.Git.HTTPS.TokenSecretRef.Namespace, m.Git.HTTPS.TokenSecretRef.Name)
+		}
+		if m.Git.SSH != nil && m.Git.SSH.SSHKeySecretRef != nil {
+			add(inputKindSecret, m.Git.SSH.SSHKeySecretRef.Namespace, m.Git.SSH.SSHKeySecretRef.Name)
+		}
+	}
+	if tf.Spec.SSHTunnel != nil {
+		add(inputKindSecret, tf.Spec.SSHTunnel.SSHKeySecretRef.Namespace, tf.Spec.SSHTunnel.SSHKeySecretRef.Name)
+	}
+	if tf.Spec.Variables != nil {
+		for _, selector := range tf.Spec.Variables.Files {
+			add(inputKindConfigMap, "", selector.Name)
+		}
+		for _, variable := range tf.Spec.Variables.Values {
+			if variable.ValueFrom == nil {
+				continue
+			}
+			if variable.ValueFrom.ConfigMapKeyRef != nil {
+				add(inputKindConfigMap, "", variable.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if variable.ValueFrom.SecretKeyRef != nil {
+				add(inputKindSecret, "", variable.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	keys := []string{}
+	for key := range references {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hashData returns a sha256 of the data that does not depend on the order of the keys
+func hashData(data map[string][]byte) string {
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%d:", key, len(data[key]))
+		h.Write(data[key])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// getInputHashes returns the hash of the content of every ConfigMap and Secret referenced by the resource.
+func (r ReconcileTerraform) getInputHashes(ctx context.Context, tf *tfv1alpha2.Terraform) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for _, key := range getInputReferences(tf) {
+		parts := strings.SplitN(key, "/", 3)
+		kind, namespacedName := parts[0], types.NamespacedName{Namespace: parts[1], Name: parts[2]}
+		data := make(map[string][]byte)
+		var err error
+		if kind == inputKindConfigMap {
+			configMap := &corev1.ConfigMap{}
+			err = r.Client.Get(ctx, namespacedName, configMap)
+			for k, v := range configMap.Data {
+				data[k] = []byte(v)
+			}
+			for k, v := range configMap.BinaryData {
+				data[k] = v
+			}
+		} else {
+			secret := &corev1.Secret{}
+			err = r.Client.Get(ctx, namespacedName, secret)
+			data = secret.Data
+		}
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return hashes, err
+			}
+			hashes[key] = missingInputHash
+			continue
+		}
+		hashes[key] = hashData(data)
+	}
+	return hashes, nil
+}
+
+// getChangedInputs compares the hashes of the last run with the current hashes and returns the keys of the
+// inputs that changed.
+func getChangedInputs(previous, current map[string]string) []string {
+	changed := []string{}
+	for key, hash := range current {
+		if previousHash, found := previous[key]; !found || previousHash != hash {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// inputReferencesIndex indexes the resources by the ConfigMaps and Secrets they watch so that the events of
+// the inputs only look up the resources referencing them
+const inputReferencesIndex = "spec.inputReferences"
+
+// indexInputReferences returns the index values of a resource, which are empty unless it watches its inputs
+func indexInputReferences(obj client.Object) []string {
+	tf, ok := obj.(*tfv1alpha2.Terraform)
+	if !ok || !tf.Spec.WatchInputs {
+		return nil
+	}
+	return getInputReferences(tf)
+}
+
+// mapInputRequests returns a MapFunc that finds the resources watching the ConfigMap or Secret
+func (r ReconcileTerraform) mapInputRequests(kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		requests := []reconcile.Request{}
+		tfList := &tfv1alpha2.TerraformList{}
+		key := inputKey(kind, obj.GetNamespace(), obj.GetName())
+		err := r.Client.List(context.TODO(), tfList, client.MatchingFields{inputReferencesIndex: key})
+		if err != nil {
+			r.Log.Error(err, "Failed to list resources watching inputs")
+			return requests
+		}
+		for _, item := range tfList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name}})
+		}
+		return requests
+	}
+}
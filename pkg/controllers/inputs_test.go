@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetInputReferences(t *testing.T) {
+	tf := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			TerraformModule: tfv1alpha2.Module{
+				ConfigMapSelector: &tfv1alpha2.ConfigMapSelector{Name: "module"},
+			},
+			TaskOptions: []tfv1alpha2.TaskOption{
+				{
+					EnvFrom: []corev1.EnvFromSource{
+						{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}}},
+						{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "module"}}},
+					},
+				},
+			},
+			Credentials: []tfv1alpha2.Credentials{
+				{SecretNameRef: tfv1alpha2.SecretNameRef{Name: "aws", Namespace: "shared"}},
+			},
+		},
+	}
+	want := []string{"configmap/default/module", "secret/default/env", "secret/shared/aws"}
+	if got := getInputReferences(tf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Only the resources watching their inputs are indexed by them
+	if got := indexInputReferences(tf); len(got) != 0 {
+		t.Errorf("expected no index values without watchInputs, got %v", got)
+	}
+	tf.Spec.WatchInputs = true
+	if got := indexInputReferences(tf); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the index values %v, got %v", want, got)
+	}
+}
+
+func TestGetChangedInputs(t *testing.T) {
+	a := hashData(map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	b := hashData(map[string][]byte{"b": []byte("2"), "a": []byte("1")})
+	if a != b {
+		t.Errorf("expected the hash to not depend on the order of keys")
+	}
+	if c := hashData(map[string][]byte{"a": []byte("12")}); a == c {
+		t.Errorf("expected different data to have a different hash")
+	}
+
+	previous := map[string]string{"secret/default/env": a, "configmap/default/module": missingInputHash}
+	current := map[string]string{"secret/default/env": a, "configmap/default/module": b, "secret/default/new": a}
+	want := []string{"configmap/default/module", "secret/default/new"}
+	if got := getChangedInputs(previous, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	tunnelSidecarName = "tunnel"
+	tunnelSidecarHome = "/tmp/tunnel-home"
+
+	// tunnelStatusPath is the emptyDir the task signals the end of the task in. The bundled scripts and
+	// the runner create the done file when they exit.
+	tunnelStatusPath = "/tmp/tunnel-status"
+	tunnelDoneFile   = tunnelStatusPath + "/done"
+
+	// tunnelSidecarScript starts the tunnel and stops it once the task has created the done file. Scripts
+	// that do not create it, eg custom scripts that do not source common.sh, are done when the processes of
+	// the task container, which all have TFO_TASK in their environment, have been seen and are gone. The pod
+	// shares the process namespace so the sidecar can see them. There is no timeout since the task can take
+	// any time to start, eg to pull its image, and the pod's deadline stops a task that never does. A
+	// container that keeps running would keep the pod from completing.
+	tunnelSidecarScript = `mkdir -p "$HOME/.ssh"
+cp /tmp/ssh/* "$HOME/.ssh/"
+chmod 600 "$HOME/.ssh/"*
+tunnel() { ssh -F "$HOME/.ssh/config" -o ControlMaster=yes -o ControlPath="$HOME/.ssh/tunnel.sock" "$@" ` + sshTunnelHost + `; }
+task_running() { grep -qs TFO_TASK= /proc/[0-9]*/environ; }
+tunnel -f -N || exit 1
+touch "$HOME/ready"
+seen=false
+until [ -f "$TFO_TASK_DONE_FILE" ]; do
+  if task_running; then seen=true; elif $seen; then break; fi
+  tunnel -O check 2>/dev/null || tunnel -f -N
+  sleep 1
+done
+tunnel -O exit 2>/dev/null
+exit 0
+`
+
+	// tunnelSidecarReadyScript holds the start of the task container until the tunnel listens
+	tunnelSidecarReadyScript = `i=0
+until [ -f "$HOME/ready" ] || [ $i -ge 30 ]; do i=$((i+1)); sleep 1; done
+exit 0
+`
+)
+
+// usesTunnelSidecar returns true when the terraform tasks run the ssh tunnel as a sidecar
+func usesTunnelSidecar(tf *tfv1alpha2.Terraform) bool {
+	return tf.Spec.Network != nil && tf.Spec.Network.Tunnel != nil && tf.Spec.SSHTunnel != nil
+}
+
+// endpointAddress is the loopback address the endpoint's host resolves to in the task pod
+func endpointAddress(i int) string {
+	return fmt.Sprintf("127.0.1.%d", i+1)
+}
+
+// getTunnelForwards returns the LocalForward lines of the tunnel's ssh config
+func getTunnelForwards(tf *tfv1alpha2.Terraform) string {
+	if !usesTunnelSidecar(tf) {
+		return ""
+	}
+	forwards := ""
+	for i, endpoint := range tf.Spec.Network.Tunnel.Endpoints {
+		forwards += fmt.Sprintf("\tLocalForward %s:%d %s:%d\n", endpointAddress(i), endpoint.Port, endpoint.Host, endpoint.Port)
+	}
+	return forwards
+}
+
+// validateNetwork checks the network options that can not be checked by the CRD schema
+func validateNetwork(tf *tfv1alpha2.Terraform) error {
+	network := tf.Spec.Network
+	if network == nil || network.Tunnel == nil {
+		return nil
+	}
+	if tf.Spec.SSHTunnel == nil {
+		return fmt.Errorf("spec.network.tunnel requires spec.sshTunnel")
+	}
+	names := map[string]bool{}
+	for _, endpoint := range network.Tunnel.Endpoints {
+		if endpoint.Name == "" || endpoint.Host == "" || endpoint.Port < 1 || endpoint.Port > 65535 {
+			return fmt.Errorf("tunnel endpoints require a name, host and port, got '%s' %s:%d", endpoint.Name, endpoint.Host, endpoint.Port)
+		}
+		if names[endpoint.Name] {
+			return fmt.Errorf("tunnel endpoint '%s' is defined more than once", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+	}
+	return nil
+}
+
+// endpointEnvName formats the TFO_ENDPOINT_<NAME> variable of the endpoint
+func endpointEnvName(name string) string {
+	name = strings.ToUpper(name)
+	return "TFO_ENDPOINT_" + strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// getNetworkEnvs returns the proxy variables of the task. Both cases are set because tools disagree on
+// which one to read.
+func getNetworkEnvs(network *tfv1alpha2.Network, tunnelSidecar bool) []corev1.EnvVar {
+	if network == nil {
+		return nil
+	}
+	httpProxy := network.HTTPProxy
+	httpsProxy := network.HTTPSProxy
+	noProxy := []string{}
+	envs := []corev1.EnvVar{}
+	if tunnelSidecar {
+		if network.Tunnel.Proxy {
+			httpProxy = "socks5h://" + sshTunnelSocksAddress
+			httpsProxy = httpProxy
+		}
+		for _, endpoint := range network.Tunnel.Endpoints {
+			noProxy = append(noProxy, endpoint.Host)
+			envs = append(envs, corev1.EnvVar{
+				Name:  endpointEnvName(endpoint.Name),
+				Value: fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port),
+			})
+		}
+	}
+	if httpProxy == "" && httpsProxy == "" {
+		return envs
+	}
+	// The kubernetes api is used by the tasks, eg to save outputs, and must not go through the proxy
+	noProxy = append(noProxy, "localhost", "127.0.0.1", "$(KUBERNETES_SERVICE_HOST)", ".svc", ".cluster.local")
+	noProxy = append(noProxy, network.NoProxy...)
+	for _, env := range []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: httpProxy},
+		{Name: "HTTPS_PROXY", Value: httpsProxy},
+		{Name: "NO_PROXY", Value: strings.Join(noProxy, ",")},
+	} {
+		if env.Value == "" {
+			continue
+		}
+		envs = append(envs, env, corev1.EnvVar{Name: strings.ToLower(env.Name), Value: env.Value})
+	}
+	return envs
+}
+
+// tunnelStatusVolume is the volume the task signals the sidecar in when it is done
+func tunnelStatusVolume() (corev1.Volume, corev1.VolumeMount, corev1.EnvVar) {
+	volume := corev1.Volume{
+		Name: "tunnel-status",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: tunnelStatusPath,
+	}
+	env := corev1.EnvVar{
+		Name:  "TFO_TASK_DONE_FILE",
+		Value: tunnelDoneFile,
+	}
+	return volume, mount, env
+}
+
+// tunnelSidecar returns the container that runs the ssh tunnel
+func (r TaskOptions) tunnelSidecar(securityContext *corev1.SecurityContext, sshMount corev1.VolumeMount) corev1.Container {
+	_, statusMount, doneEnv := tunnelStatusVolume()
+	return corev1.Container{
+		Name:            tunnelSidecarName,
+		SecurityContext: securityContext,
+		Image:           r.tunnelImage,
+		ImagePullPolicy: r.tunnelImagePullPolicy,
+		Command:         []string{"/bin/sh", "-c", tunnelSidecarScript},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "HOME",
+				Value: tunnelSidecarHome,
+			},
+			doneEnv,
+		},
+		VolumeMounts: []corev1.VolumeMount{sshMount, statusMount},
+		Lifecycle: &corev1.Lifecycle{
+			PostStart: &corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: []string{"/bin/sh", "-c", "HOME=" + tunnelSidecarHome + "\n" + tunnelSidecarReadyScript},
+				},
+			},
+		},
+	}
+}
+
+// tunnelHostAliases resolves the endpoints' hosts to the loopback addresses the tunnel listens on
+func tunnelHostAliases(network *tfv1alpha2.Network) []corev1.HostAlias {
+	aliases := []corev1.HostAlias{}
+	for i, endpoint := range network.Tunnel.Endpoints {
+		aliases = append(aliases, corev1.HostAlias{IP: endpointAddress(i), Hostnames: []string{endpoint.Host}})
+	}
+	return aliases
+}
+
+// tunnelSysctls lets the tunnel, which does not run as root, listen on the privileged ports of endpoints
+func tunnelSysctls(network *tfv1alpha2.Network) []corev1.Sysctl {
+	for _, endpoint := range network.Tunnel.Endpoints {
+		if endpoint.Port < 1024 {
+			return []corev1.Sysctl{{Name: "net.ipv4.ip_unprivileged_port_start", Value: "0"}}
+		}
+	}
+	return nil
+}
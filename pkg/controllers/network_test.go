@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestNetworkTerraform() *tfv1alpha2.Terraform {
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		Network: &tfv1alpha2.Network{
+			HTTPSProxy: "http://proxy.corp.example.com:3128",
+			NoProxy:    []string{".corp.example.com"},
+			Tunnel: &tfv1alpha2.NetworkTunnel{
+				Proxy: true,
+				Endpoints: []tfv1alpha2.TunnelEndpoint{
+					{Name: "vault", Host: "vault.internal.example.com", Port: 8200},
+					{Name: "k8s-api", Host: "k8s.internal.example.com", Port: 443},
+				},
+			},
+		},
+		SSHTunnel: &tfv1alpha2.ProxyOpts{Host: "bastion.example.com", User: "tunnel", SSHKeySecretRef: tfv1alpha2.SSHKeySecretRef{Name: "bastion"}},
+	})
+}
+
+func TestValidateNetwork(t *testing.T) {
+	tf := newTestNetworkTerraform()
+	if err := validateNetwork(tf); err != nil {
+		t.Fatal(err)
+	}
+	tf.Spec.SSHTunnel = nil
+	if err := validateNetwork(tf); err == nil {
+		t.Error("expected the tunnel to require spec.sshTunnel")
+	}
+}
+
+func TestNetworkSSHConfig(t *testing.T) {
+	k8sClient := newTestReconciler(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bastion"},
+		Data:       map[string][]byte{"id_rsa": []byte("proxy key")},
+	}).Client
+	data, err := formatJobSSHConfig(context.TODO(), logr.Discard(), newTestNetworkTerraform(), k8sClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\tLocalForward 127.0.1.1:8200 vault.internal.example.com:8200\n",
+		"\tLocalForward 127.0.1.2:443 k8s.internal.example.com:443\n",
+	} {
+		if !strings.Contains(string(data["config"]), want) {
+			t.Errorf("expected the ssh config to contain %q, got:\n%s", want, data["config"])
+		}
+	}
+}
+
+func TestNetworkTunnelPod(t *testing.T) {
+	pod := newTaskOptions(newTestNetworkTerraform(), tfv1alpha2.RunPlan, 1, nil).generatePod()
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[0].Name != "tunnel" {
+		t.Fatalf("expected the tunnel sidecar before the task, got %d containers", len(pod.Spec.Containers))
+	}
+	// The task signals the end of the task to the sidecar in a shared emptyDir
+	sidecar, task := pod.Spec.Containers[0], pod.Spec.Containers[1]
+	for _, container := range []corev1.Container{sidecar, task} {
+		if podEnvs(container)["TFO_TASK_DONE_FILE"] != "/tmp/tunnel-status/done" || !hasVolumeMount(container, "tunnel-status") {
+			t.Errorf("expected the %s container to share the done file", container.Name)
+		}
+	}
+	if script := sidecar.Command[2]; !strings.Contains(script, `until [ -f "$TFO_TASK_DONE_FILE" ]`) || strings.Contains(script, "-ge 60") {
+		t.Errorf("expected the sidecar to wait for the done file without a timeout, got:\n%s", script)
+	}
+	if pod.Spec.ShareProcessNamespace == nil || !*pod.Spec.ShareProcessNamespace {
+		t.Error("expected the process namespace to be shared")
+	}
+	if len(pod.Spec.HostAliases) != 2 || pod.Spec.HostAliases[1].IP != "127.0.1.2" || pod.Spec.HostAliases[1].Hostnames[0] != "k8s.internal.example.com" {
+		t.Errorf("expected the endpoints to resolve to loopback addresses, got %v", pod.Spec.HostAliases)
+	}
+	if sysctls := pod.Spec.SecurityContext.Sysctls; len(sysctls) != 1 || sysctls[0].Name != "net.ipv4.ip_unprivileged_port_start" {
+		t.Errorf("expected the privileged port sysctl, got %v", sysctls)
+	}
+}
+
+func TestNetworkTaskEnvs(t *testing.T) {
+	tests := []struct {
+		task      tfv1alpha2.TaskName
+		httpProxy string
+		noProxy   func(string) bool
+		endpoint  string
+	}{
+		{
+			// Tasks that run the tunnel send their traffic through it
+			task:      tfv1alpha2.RunPlan,
+			httpProxy: "socks5h://127.0.0.1:1080",
+			noProxy: func(noProxy string) bool {
+				return strings.HasPrefix(noProxy, "vault.internal.example.com,k8s.internal.example.com,localhost") && strings.HasSuffix(noProxy, ".corp.example.com")
+			},
+			endpoint: "k8s.internal.example.com:443",
+		},
+		{
+			// The setup task does not run the sidecar but uses the corporate proxy
+			task:      tfv1alpha2.RunSetup,
+			httpProxy: "http://proxy.corp.example.com:3128",
+			noProxy:   func(noProxy string) bool { return strings.HasSuffix(noProxy, ".corp.example.com") },
+			endpoint:  "",
+		},
+	}
+	tf := newTestNetworkTerraform()
+	for _, tt := range tests {
+		envs := taskEnvs(tf, tt.task)
+		if envs["HTTPS_PROXY"] != tt.httpProxy || envs["https_proxy"] != envs["HTTPS_PROXY"] {
+			t.Errorf("%s: expected the proxy %s, got %s", tt.task, tt.httpProxy, envs["HTTPS_PROXY"])
+		}
+		if !tt.noProxy(envs["NO_PROXY"]) {
+			t.Errorf("%s: unexpected NO_PROXY %s", tt.task, envs["NO_PROXY"])
+		}
+		if envs["TFO_ENDPOINT_K8S_API"] != tt.endpoint {
+			t.Errorf("%s: expected the endpoint env %q, got %q", tt.task, tt.endpoint, envs["TFO_ENDPOINT_K8S_API"])
+		}
+	}
+	if pod := newTaskOptions(tf, tfv1alpha2.RunSetup, 1, nil).generatePod(); len(pod.Spec.Containers) != 1 || pod.Spec.ShareProcessNamespace != nil {
+		t.Errorf("expected no tunnel sidecar in the setup task")
+	}
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// pluginCacheClaimName is the claim of the namespace plugin cache. It is shared by the resources of the
+	// namespace so it is not owned by any of them.
+	pluginCacheClaimName = "tfo-plugin-cache"
+
+	// pluginCachePath is where the plugin cache is mounted in the terraform tasks
+	pluginCachePath = "/tmp/tfo-plugin-cache"
+
+	// pluginCacheLockFile is held by the task while it writes to the cache
+	pluginCacheLockFile = pluginCachePath + "/.tfo-lock"
+
+	defaultPluginCacheRetention = 720 * time.Hour
+)
+
+var (
+	pluginCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfo_plugin_cache_hits_total",
+		Help: "Number of providers terraform init used from the plugin cache",
+	}, []string{"namespace", "scope"})
+
+	pluginCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfo_plugin_cache_misses_total",
+		Help: "Number of providers terraform init downloaded into the plugin cache",
+	}, []string{"namespace", "scope"})
+
+	// ansiEscapePattern finds the color codes of terraform's output
+	ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	pluginCacheHitPattern  = regexp.MustCompile(`(?m)^- Using \S+ v\S+ from the shared cache directory`)
+	pluginCacheMissPattern = regexp.MustCompile(`(?m)^- Installing \S+ v\S+\.\.\.`)
+)
+
+func init() {
+	metrics.Registry.MustRegister(pluginCacheHits, pluginCacheMisses)
+}
+
+// pluginCacheScope returns the scope of the cache, which defaults to the namespace
+func pluginCacheScope(pluginCache *tfv1alpha2.PluginCache) tfv1alpha2.PluginCacheScope {
+	if pluginCache.Scope == "" {
+		return tfv1alpha2.PluginCacheScopeNamespace
+	}
+	return pluginCache.Scope
+}
+
+// validatePluginCache checks the plugin cache can be mounted
+func (r ReconcileTerraform) validatePluginCache(tf *tfv1alpha2.Terraform) error {
+	pluginCache := tf.Spec.PluginCache
+	if pluginCache == nil {
+		return nil
+	}
+	switch pluginCacheScope(pluginCache) {
+	case tfv1alpha2.PluginCacheScopeNamespace:
+	case tfv1alpha2.PluginCacheScopeCluster:
+		if r.PluginCacheVolume == nil {
+			return fmt.Errorf("the cluster plugin cache is not configured in the controller")
+		}
+	default:
+		return fmt.Errorf("unknown plugin cache scope '%s'", pluginCache.Scope)
+	}
+	return nil
+}
+
+// createPluginCachePVC creates the claim of the namespace plugin cache when it does not exist yet
+func (r ReconcileTerraform) createPluginCachePVC(ctx context.Context, tf *tfv1alpha2.Terraform) error {
+	pluginCache := tf.Spec.PluginCache
+	if pluginCache == nil || pluginCacheScope(pluginCache) != tfv1alpha2.PluginCacheScopeNamespace {
+		return nil
+	}
+	kind := "PersistentVolumeClaim"
+	_, found, err := r.checkPersistentVolumeClaimExists(ctx, types.NamespacedName{
+		Name:      pluginCacheClaimName,
+		Namespace: tf.Namespace,
+	})
+	if err != nil || found {
+		return err
+	}
+	size := resource.MustParse("10Gi")
+	if pluginCache.Size != nil {
+		size = *pluginCache.Size
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pluginCacheClaimName,
+			Namespace: tf.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "terraform-operator",
+				"app.kubernetes.io/created-by": "controller",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			StorageClassName: pluginCache.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+	err = r.Client.Create(ctx, pvc)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		r.Recorder.Event(tf, "Warning", fmt.Sprintf("%sCreateError", kind), fmt.Sprintf("Could not create %s %v", kind, err))
+		return err
+	}
+	if err == nil {
+		r.Recorder.Event(tf, "Normal", "SuccessfulCreate", fmt.Sprintf("Created %s: '%s'", kind, pvc.Name))
+	}
+	return nil
+}
+
+// pluginCacheVolume mounts the plugin cache and tells the task how to use it. Only the init tasks collect
+// the unused provider versions.
+func (r TaskOptions) pluginCacheVolume() (*corev1.Volume, corev1.VolumeMount, []corev1.EnvVar) {
+	volume := &corev1.Volume{Name: "plugin-cache"}
+	if pluginCacheScope(r.pluginCache) == tfv1alpha2.PluginCacheScopeCluster {
+		if r.clusterPluginCache == nil {
+			return nil, corev1.VolumeMount{}, nil
+		}
+		volume.VolumeSource = *r.clusterPluginCache
+	} else {
+		volume.VolumeSource.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: pluginCacheClaimName,
+		}
+	}
+	mount := corev1.VolumeMount{
+		Name:      "plugin-cache",
+		MountPath: pluginCachePath,
+	}
+	envs := []corev1.EnvVar{
+		{
+			Name:  "TF_PLUGIN_CACHE_DIR",
+			Value: pluginCachePath,
+		},
+		{
+			Name:  "TFO_PLUGIN_CACHE_LOCK_FILE",
+			Value: pluginCacheLockFile,
+		},
+	}
+	if r.task == tfv1alpha2.RunInit || r.task == tfv1alpha2.RunInitDelete {
+		retention := defaultPluginCacheRetention
+		if r.pluginCacheRetention > 0 {
+			retention = r.pluginCacheRetention
+		}
+		envs = append(envs, corev1.EnvVar{
+			Name:  "TFO_PLUGIN_CACHE_RETENTION",
+			Value: retention.String(),
+		})
+		if !r.pluginCacheKeepSince.IsZero() {
+			envs = append(envs, corev1.EnvVar{
+				Name:  "TFO_PLUGIN_CACHE_KEEP_SINCE",
+				Value: fmt.Sprintf("%d", r.pluginCacheKeepSince.Unix()),
+			})
+		}
+	}
+	return volume, mount, envs
+}
+
+// sharesPluginCache returns true when both resources mount the same plugin cache
+func sharesPluginCache(tf, other *tfv1alpha2.Terraform) bool {
+	if tf.Spec.PluginCache == nil || other.Spec.PluginCache == nil {
+		return false
+	}
+	scope := pluginCacheScope(tf.Spec.PluginCache)
+	if scope != pluginCacheScope(other.Spec.PluginCache) {
+		return false
+	}
+	return scope == tfv1alpha2.PluginCacheScopeCluster || tf.Namespace == other.Namespace
+}
+
+// getPluginCacheKeepSince returns when the oldest running workflow that shares the plugin cache of the
+// resource started
+func (r ReconcileTerraform) getPluginCacheKeepSince(ctx context.Context, tf *tfv1alpha2.Terraform) (time.Time, error) {
+	listOptions := []client.ListOption{}
+	if pluginCacheScope(tf.Spec.PluginCache) == tfv1alpha2.PluginCacheScopeNamespace {
+		listOptions = append(listOptions, client.InNamespace(tf.Namespace))
+	}
+	tfList := &tfv1alpha2.TerraformList{}
+	if err := r.Client.List(ctx, tfList, listOptions...); err != nil {
+		return time.Time{}, err
+	}
+	podList := &corev1.PodList{}
+	listOptions = append(listOptions, client.HasLabels{"terraforms.tf.isaaguilar.com/podPrefix"})
+	if err := r.Client.List(ctx, podList, listOptions...); err != nil {
+		return time.Time{}, err
+	}
+	return pluginCacheKeepSince(tf, tfList.Items, podList.Items), nil
+}
+
+// pluginCacheKeepSince returns when the oldest running workflow that shares the plugin cache of the
+// resource started. A workflow started with the first task pod of its generation, or with its current
+// stage when the pods are gone. The time is zero when no workflow is running.
+func pluginCacheKeepSince(tf *tfv1alpha2.Terraform, tfs []tfv1alpha2.Terraform, pods []corev1.Pod) time.Time {
+	starts := map[string]time.Time{}
+	for i := range tfs {
+		other := &tfs[i]
+		if !sharesPluginCache(tf, other) || !isWorkflowActive(other) || other.Status.Stage.StartTime.IsZero() {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%d", other.Namespace, other.Status.PodNamePrefix, other.Status.Stage.Generation)
+		starts[key] = other.Status.Stage.StartTime.Time
+	}
+	for _, pod := range pods {
+		key := fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Labels["terraforms.tf.isaaguilar.com/podPrefix"], pod.Labels["terraforms.tf.isaaguilar.com/generation"])
+		if start, ok := starts[key]; ok && pod.CreationTimestamp.Time.Before(start) {
+			starts[key] = pod.CreationTimestamp.Time
+		}
+	}
+	keepSince := time.Time{}
+	for _, start := range starts {
+		if keepSince.IsZero() || start.Before(keepSince) {
+			keepSince = start
+		}
+	}
+	return keepSince
+}
+
+// countPluginCacheUsage counts the providers terraform init used from the cache and the ones it downloaded
+func countPluginCacheUsage(log string) (int, int) {
+	log = ansiEscapePattern.ReplaceAllString(log, "")
+	return len(pluginCacheHitPattern.FindAllString(log, -1)), len(pluginCacheMissPattern.FindAllString(log, -1))
+}
+
+// recordPluginCacheUsage adds the cache hits and misses of the init task to the metrics. Logs are skipped
+// when the controller is not configured with a clientset.
+func (r ReconcileTerraform) recordPluginCacheUsage(ctx context.Context, tf *tfv1alpha2.Terraform, pod *corev1.Pod) {
+	if r.Clientset == nil || tf.Spec.PluginCache == nil {
+		return
+	}
+	log, err := r.scanTaskLog(ctx, pod, pluginCacheHitPattern, pluginCacheMissPattern)
+	if err != nil {
+		r.Log.V(1).Info(fmt.Sprintf("Could not get logs of pod '%s': %s", pod.Name, err))
+		return
+	}
+	hits, misses := countPluginCacheUsage(log)
+	scope := strings.ToLower(string(pluginCacheScope(tf.Spec.PluginCache)))
+	pluginCacheHits.WithLabelValues(tf.Namespace, scope).Add(float64(hits))
+	pluginCacheMisses.WithLabelValues(tf.Namespace, scope).Add(float64(misses))
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCountPluginCacheUsage(t *testing.T) {
+	log := `Initializing provider plugins...
+- Finding hashicorp/aws versions matching "~> 5.0"...
+- Finding latest version of hashicorp/random...
+- Using hashicorp/aws v5.31.0 from the shared cache directory
+- Installing hashicorp/random v3.6.0...
+- Installed hashicorp/random v3.6.0 (signed by HashiCorp)
+` + "\x1b[0m- Using hashicorp/null v3.2.2 from the shared cache directory\x1b[0m\n"
+	hits, misses := countPluginCacheUsage(log)
+	if hits != 2 || misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d and %d", hits, misses)
+	}
+}
+
+func newTestPluginCacheTerraform() *tfv1alpha2.Terraform {
+	storageClassName := "efs"
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		PluginCache: &tfv1alpha2.PluginCache{
+			StorageClassName: &storageClassName,
+		},
+	})
+}
+
+func TestPluginCacheClaim(t *testing.T) {
+	tf := newTestPluginCacheTerraform()
+	r := newTestReconciler()
+	if err := r.validatePluginCache(tf); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.createPluginCachePVC(context.TODO(), tf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: pluginCacheClaimName}, pvc); err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.AccessModes[0] != corev1.ReadWriteMany || *pvc.Spec.StorageClassName != "efs" || len(pvc.OwnerReferences) != 0 {
+		t.Errorf("unexpected plugin cache claim %v", pvc.Spec)
+	}
+
+	claimed := false
+	for _, volume := range newTaskOptions(tf, tfv1alpha2.RunInit, 1, nil).generatePod().Spec.Volumes {
+		claimed = claimed || (volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pluginCacheClaimName)
+	}
+	if !claimed {
+		t.Error("expected the plugin cache claim to be mounted")
+	}
+}
+
+func TestPluginCacheTasks(t *testing.T) {
+	tf := newTestPluginCacheTerraform()
+	// Every terraform task links the providers from the cache but only the init tasks collect unused
+	// versions, while holding the lock
+	if got := tasksWithEnv(tf, "TF_PLUGIN_CACHE_DIR"); !reflect.DeepEqual(got, terraformTaskNames) {
+		t.Errorf("expected only the terraform tasks to use the cache, got %v", got)
+	}
+	if got := tasksWithEnv(tf, "TFO_PLUGIN_CACHE_LOCK_FILE"); !reflect.DeepEqual(got, terraformTaskNames) {
+		t.Errorf("expected the tasks using the cache to share the lock, got %v", got)
+	}
+	want := []tfv1alpha2.TaskName{tfv1alpha2.RunInit, tfv1alpha2.RunInitDelete}
+	if got := tasksWithEnv(tf, "TFO_PLUGIN_CACHE_RETENTION"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected only the init tasks to collect unused versions, got %v", got)
+	}
+
+	// The retention is the controller's since the resources share the cache
+	runOpts := newTaskOptions(tf, tfv1alpha2.RunInit, 1, nil)
+	runOpts.pluginCacheRetention = 48 * time.Hour
+	runOpts.pluginCacheKeepSince = time.Unix(1700000000, 0)
+	containers := runOpts.generatePod().Spec.Containers
+	envs := podEnvs(containers[len(containers)-1])
+	if envs["TFO_PLUGIN_CACHE_RETENTION"] != "48h0m0s" || envs["TFO_PLUGIN_CACHE_KEEP_SINCE"] != "1700000000" {
+		t.Errorf("unexpected plugin cache envs %v", envs)
+	}
+}
+
+func TestPluginCacheKeepSince(t *testing.T) {
+	now := time.Now()
+	newWorkflow := func(namespace, name string, scope tfv1alpha2.PluginCacheScope, phase tfv1alpha2.StatusPhase, started time.Time) tfv1alpha2.Terraform {
+		tf := newTestTerraform(tfv1alpha2.TerraformSpec{PluginCache: &tfv1alpha2.PluginCache{Scope: scope}})
+		tf.Namespace, tf.Name = namespace, name
+		tf.Status.PodNamePrefix = name + "-abc"
+		tf.Status.Phase = phase
+		tf.Status.Stage = tfv1alpha2.Stage{Generation: 1, TaskType: tfv1alpha2.RunApply, StartTime: metav1.NewTime(started)}
+		return *tf
+	}
+	taskPod := func(namespace, prefix string, created time.Time) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				"terraforms.tf.isaaguilar.com/podPrefix":  prefix,
+				"terraforms.tf.isaaguilar.com/generation": "1",
+			},
+		}}
+	}
+	tf := newWorkflow("default", "app", "", tfv1alpha2.PhaseRunning, now)
+	tfs := []tfv1alpha2.Terraform{
+		tf,
+		// The apply of this workflow started recently but its init ran hours ago
+		newWorkflow("default", "db", "", tfv1alpha2.PhaseRunning, now.Add(-time.Hour)),
+		// These do not hold back the namespace cache
+		newWorkflow("default", "done", "", tfv1alpha2.PhaseCompleted, now.Add(-48*time.Hour)),
+		newWorkflow("other", "web", "", tfv1alpha2.PhaseRunning, now.Add(-24*time.Hour)),
+		newWorkflow("other", "cluster", tfv1alpha2.PluginCacheScopeCluster, tfv1alpha2.PhaseRunning, now.Add(-12*time.Hour)),
+	}
+	pods := []corev1.Pod{
+		taskPod("default", "db-abc", now.Add(-3*time.Hour)),
+		taskPod("default", "done-abc", now.Add(-50*time.Hour)),
+		taskPod("other", "web-abc", now.Add(-25*time.Hour)),
+	}
+	if got := pluginCacheKeepSince(&tf, tfs, pods); !got.Equal(now.Add(-3 * time.Hour)) {
+		t.Errorf("expected the namespace cache to keep the versions since the oldest workflow of the namespace started, got %v", got)
+	}
+
+	tf = newWorkflow("default", "app", tfv1alpha2.PluginCacheScopeCluster, tfv1alpha2.PhaseRunning, now)
+	if got := pluginCacheKeepSince(&tf, tfs, pods); !got.Equal(now.Add(-12 * time.Hour)) {
+		t.Errorf("expected the cluster cache to keep the versions since the oldest workflow using the cluster cache started, got %v", got)
+	}
+
+	r := newTestReconciler(&tfs[1], &pods[0])
+	tf = newWorkflow("default", "app", "", tfv1alpha2.PhaseRunning, now)
+	got, err := r.getPluginCacheKeepSince(context.TODO(), &tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Unix() != now.Add(-3*time.Hour).Unix() {
+		t.Errorf("expected the versions since the db workflow started to be kept, got %v", got)
+	}
+}
+
+func TestClusterPluginCache(t *testing.T) {
+	tf := newTestPluginCacheTerraform()
+	tf.Spec.PluginCache.Scope = tfv1alpha2.PluginCacheScopeCluster
+	r := newTestReconciler()
+	if err := r.validatePluginCache(tf); err == nil {
+		t.Error("expected the cluster cache to require the controller's volume")
+	}
+	r.PluginCacheVolume = &corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{Server: "nfs.example.com", Path: "/providers"}}
+	if err := r.validatePluginCache(tf); err != nil {
+		t.Fatal(err)
+	}
+	runOpts := newTaskOptions(tf, tfv1alpha2.RunApply, 1, nil)
+	runOpts.clusterPluginCache = r.PluginCacheVolume
+	mounted := false
+	for _, volume := range runOpts.generatePod().Spec.Volumes {
+		mounted = mounted || (volume.NFS != nil && volume.NFS.Server == "nfs.example.com")
+	}
+	if !mounted {
+		t.Error("expected the cluster plugin cache to be mounted")
+	}
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	getter "github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+const defaultRegistryHost = "registry.terraform.io"
+
+var (
+	registryNamePattern     = regexp.MustCompile(`^[0-9A-Za-z](?:[0-9A-Za-z-_]{0,62}[0-9A-Za-z])?$`)
+	registryProviderPattern = regexp.MustCompile(`^[0-9a-z]{1,64}$`)
+
+	// registryHTTPClient is the client used to talk to module registries
+	registryHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// registryModule is the address of a module in a terraform registry
+type registryModule struct {
+	Host      string
+	Namespace string
+	Name      string
+	Provider  string
+	Subdir    string
+}
+
+func (m registryModule) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", m.Host, m.Namespace, m.Name, m.Provider)
+}
+
+// parseRegistryAddress parses `[<host>/]<namespace>/<name>/<provider>[//<subdir>]`. Returns false when the
+// address is not a registry address, eg a git or http url.
+func parseRegistryAddress(address string) (registryModule, bool) {
+	if strings.Contains(address, "::") || strings.Contains(address, "://") || strings.Contains(address, "?") {
+		return registryModule{}, false
+	}
+	if strings.HasPrefix(address, ".") || strings.HasPrefix(address, "/") {
+		return registryModule{}, false
+	}
+	subdir := ""
+	if i := strings.Index(address, "//"); i != -1 {
+		address, subdir = address[:i], address[i+2:]
+	}
+	parts := strings.Split(address, "/")
+	host := defaultRegistryHost
+	if len(parts) == 4 {
+		host = parts[0]
+		// Like terraform, the hosts of the go-getter shorthands are never registries
+		if !strings.ContainsAny(host, ".:") || host == "github.com" || host == "bitbucket.org" {
+			return registryModule{}, false
+		}
+		parts = parts[1:]
+	}
+	if len(parts) != 3 {
+		return registryModule{}, false
+	}
+	if !registryNamePattern.MatchString(parts[0]) || !registryNamePattern.MatchString(parts[1]) || !registryProviderPattern.MatchString(parts[2]) {
+		return registryModule{}, false
+	}
+	return registryModule{
+		Host:      strings.ToLower(host),
+		Namespace: parts[0],
+		Name:      parts[1],
+		Provider:  parts[2],
+		Subdir:    subdir,
+	}, true
+}
+
+// selectVersion returns the newest version that matches the constraint. Pre-releases are only
+// selected when the constraint names one.
+func selectVersion(available []string, constraint string) (string, error) {
+	var constraints version.Constraints
+	if constraint != "" {
+		var err error
+		constraints, err = version.NewConstraint(constraint)
+		if err != nil {
+			return "", fmt.Errorf("invalid version constraint '%s': %v", constraint, err)
+		}
+	}
+	var selected *version.Version
+	for _, v := range available {
+		candidate, err := version.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if constraints == nil && candidate.Prerelease() != "" {
+			continue
+		}
+		if constraints != nil && !constraints.Check(candidate) {
+			continue
+		}
+		if selected == nil || candidate.GreaterThan(selected) {
+			selected = candidate
+		}
+	}
+	if selected == nil {
+		return "", fmt.Errorf("no version matches '%s'", constraint)
+	}
+	return selected.Original(), nil
+}
+
+// addSubdir adds the registry address' subdir to the subdir of the download address
+func addSubdir(address, subdir string) string {
+	if subdir == "" {
+		return address
+	}
+	source, existing := getter.SourceDirSubdir(address)
+	query := ""
+	if i := strings.Index(source, "?"); i != -1 {
+		source, query = source[:i], source[i:]
+	}
+	return source + "//" + path.Join(existing, subdir) + query
+}
+
+// getRegistryToken returns the token of the registry host from the scm auth methods
+func (r ReconcileTerraform) getRegistryToken(ctx context.Context, tf *tfv1alpha2.Terraform, host string) (string, error) {
+	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Registry == nil || m.Registry.TokenSecretRef == nil || strings.ToLower(m.Host) != host {
+			continue
+		}
+		ref := m.Registry.TokenSecretRef
+		key := ref.Key
+		if key == "" {
+			key = "token"
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = tf.Namespace
+		}
+		return loadPassword(ctx, r.Client, key, ref.Name, namespace)
+	}
+	return "", nil
+}
+
+// getRegistryTokenEnvs returns the TF_TOKEN_<host> variables terraform uses to authenticate to the
+// registries of the scm auth methods.
+func (r ReconcileTerraform) getRegistryTokenEnvs(ctx context.Context, tf *tfv1alpha2.Terraform) (map[string][]byte, error) {
+	envs := make(map[string][]byte)
+	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Registry == nil || m.Registry.TokenSecretRef == nil || strings.Contains(m.Host, ":") {
+			continue
+		}
+		host := strings.ToLower(m.Host)
+		token, err := r.getRegistryToken(ctx, tf, host)
+		if err != nil {
+			return envs, err
+		}
+		envs[tokenEnvName(host)] = []byte(token)
+	}
+	return envs, nil
+}
+
+// tokenEnvName formats the TF_TOKEN_<host> variable of the host
+func tokenEnvName(host string) string {
+	return "TF_TOKEN_" + strings.ReplaceAll(strings.ReplaceAll(host, "-", "__"), ".", "_")
+}
+
+func registryGet(ctx context.Context, httpClient *http.Client, u, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s returned %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// getRegistryModulesURL discovers the base url of the modules api of the registry host
+func getRegistryModulesURL(ctx context.Context, httpClient *http.Client, host, token string) (*url.URL, error) {
+	discoveryURL := &url.URL{Scheme: "https", Host: host, Path: "/.well-known/terraform.json"}
+	resp, err := registryGet(ctx, httpClient, discoveryURL.String(), token)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	services := make(map[string]interface{})
+	err = json.NewDecoder(resp.Body).Decode(&services)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the services of '%s': %v", host, err)
+	}
+	modules, ok := services["modules.v1"].(string)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a module registry", host)
+	}
+	modulesURL, err := url.Parse(modules)
+	if err != nil {
+		return nil, err
+	}
+	modulesURL = discoveryURL.ResolveReference(modulesURL)
+	if !strings.HasSuffix(modulesURL.Path, "/") {
+		modulesURL.Path += "/"
+	}
+	return modulesURL, nil
+}
+
+// resolveRegistryModule selects the module's version, when it has not been selected for the generation,
+// and returns the address the registry says to download the version from.
+func (r ReconcileTerraform) resolveRegistryModule(ctx context.Context, tf *tfv1alpha2.Terraform, module registryModule) (string, error) {
+	token, err := r.getRegistryToken(ctx, tf, module.Host)
+	if err != nil {
+		return "", err
+	}
+	httpClient, err := r.getHTTPClient(ctx, tf)
+	if err != nil {
+		return "", err
+	}
+	modulesURL, err := getRegistryModulesURL(ctx, httpClient, module.Host, token)
+	if err != nil {
+		return "", err
+	}
+	moduleURL := modulesURL.ResolveReference(&url.URL{Path: path.Join(module.Namespace, module.Name, module.Provider) + "/"})
+
+	if tf.Status.ResolvedModuleVersion == "" {
+		resp, err := registryGet(ctx, httpClient, moduleURL.ResolveReference(&url.URL{Path: "versions"}).String(), token)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		versions := struct {
+			Modules []struct {
+				Versions []struct {
+					Version string `json:"version"`
+				} `json:"versions"`
+			} `json:"modules"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&versions)
+		if err != nil {
+			return "", fmt.Errorf("could not read the versions of '%s': %v", module, err)
+		}
+		available := []string{}
+		for _, m := range versions.Modules {
+			for _, v := range m.Versions {
+				available = append(available, v.Version)
+			}
+		}
+		selected, err := selectVersion(available, tf.Spec.TerraformModule.Version)
+		if err != nil {
+			return "", fmt.Errorf("could not select a version of '%s': %v", module, err)
+		}
+		r.Recorder.Event(tf, "Normal", "ModuleVersionResolved", fmt.Sprintf("Using version %s of '%s'", selected, module))
+		tf.Status.ResolvedModuleVersion = selected
+	}
+
+	downloadURL := moduleURL.ResolveReference(&url.URL{Path: path.Join(tf.Status.ResolvedModuleVersion, "download")})
+	resp, err := registryGet(ctx, httpClient, downloadURL.String(), token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	location := resp.Header.Get("X-Terraform-Get")
+	if location == "" {
+		body := struct {
+			Location string `json:"location"`
+		}{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		location = body.Location
+	}
+	if location == "" {
+		return "", fmt.Errorf("'%s' did not return a download location for version %s", module, tf.Status.ResolvedModuleVersion)
+	}
+	if strings.HasPrefix(location, "/") || strings.HasPrefix(location, "./") || strings.HasPrefix(location, "../") {
+		relative, err := url.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		location = downloadURL.ResolveReference(relative).String()
+	}
+	return addSubdir(location, module.Subdir), nil
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseRegistryAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    registryModule
+		ok      bool
+	}{
+		{address: "hashicorp/consul/aws", want: registryModule{Host: "registry.terraform.io", Namespace: "hashicorp", Name: "consul", Provider: "aws"}, ok: true},
+		{address: "app.terraform.io/example-corp/k8s-cluster/azurerm//modules/node", want: registryModule{Host: "app.terraform.io", Namespace: "example-corp", Name: "k8s-cluster", Provider: "azurerm", Subdir: "modules/node"}, ok: true},
+		{address: "localhost:8443/org/vpc/aws", want: registryModule{Host: "localhost:8443", Namespace: "org", Name: "vpc", Provider: "aws"}, ok: true},
+		{address: "github.com/org/repo"},
+		{address: "github.com/org/repo/subdir"},
+		{address: "git@github.com:org/repo.git"},
+		{address: "https://github.com/org/repo.git?ref=main"},
+		{address: "git::ssh://git@example.com/org/repo.git"},
+		{address: "./modules/vpc"},
+	}
+	for _, tt := range tests {
+		got, ok := parseRegistryAddress(tt.address)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.address, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestSelectModuleVersion(t *testing.T) {
+	available := []string{"1.0.0", "1.2.0", "1.10.1", "2.0.0-beta1", "2.0.0", "not-a-version"}
+	tests := []struct {
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "", want: "2.0.0"},
+		{constraint: "~> 1.2", want: "1.10.1"},
+		{constraint: ">= 1.0.0, < 1.10.0", want: "1.2.0"},
+		{constraint: "2.0.0-beta1", want: "2.0.0-beta1"},
+		{constraint: "> 3.0", wantErr: true},
+		{constraint: "~>", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := selectVersion(available, tt.constraint)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.constraint, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.constraint, tt.want, got)
+		}
+	}
+}
+
+func TestAddSubdir(t *testing.T) {
+	tests := []struct {
+		address, subdir, want string
+	}{
+		{"git::https://github.com/org/repo?ref=v1.0.0", "", "git::https://github.com/org/repo?ref=v1.0.0"},
+		{"git::https://github.com/org/repo?ref=v1.0.0", "modules/node", "git::https://github.com/org/repo//modules/node?ref=v1.0.0"},
+		{"git::https://github.com/org/repo//modules?ref=v1.0.0", "node", "git::https://github.com/org/repo//modules/node?ref=v1.0.0"},
+	}
+	for _, tt := range tests {
+		if got := addSubdir(tt.address, tt.subdir); got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestResolveRegistryModule(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/.well-known/terraform.json" && req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/.well-known/terraform.json":
+			fmt.Fprint(w, `{"modules.v1": "/api/registry/v1/modules/"}`)
+		case "/api/registry/v1/modules/org/vpc/aws/versions":
+			fmt.Fprint(w, `{"modules": [{"versions": [{"version": "3.1.0"}, {"version": "3.14.2"}, {"version": "4.0.0"}]}]}`)
+		case "/api/registry/v1/modules/org/vpc/aws/3.14.2/download":
+			w.Header().Set("X-Terraform-Get", "git::https://github.com/org/terraform-aws-vpc?ref=v3.14.2")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defaultClient := registryHTTPClient
+	registryHTTPClient = server.Client()
+	defer func() { registryHTTPClient = defaultClient }()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+	host := server.Listener.Addr().String()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "registry"},
+		Data:       map[string][]byte{"token": []byte("t0ken")},
+	}
+	tf := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			TerraformModule: tfv1alpha2.Module{Source: host + "/org/vpc/aws//modules/nat", Version: "~> 3.1"},
+			SCMAuthMethods: []tfv1alpha2.SCMAuthMethod{
+				{Host: host, Registry: &tfv1alpha2.RegistrySCM{TokenSecretRef: &tfv1alpha2.TokenSecretRef{Name: "registry"}}},
+			},
+		},
+	}
+	r := ReconcileTerraform{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	module, ok := parseRegistryAddress(tf.Spec.TerraformModule.Source)
+	if !ok {
+		t.Fatalf("expected %s to be a registry address", tf.Spec.TerraformModule.Source)
+	}
+	source, err := r.resolveRegistryModule(context.TODO(), tf, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "git::https://github.com/org/terraform-aws-vpc//modules/nat?ref=v3.14.2"; source != want {
+		t.Errorf("expected %s, got %s", want, source)
+	}
+	if tf.Status.ResolvedModuleVersion != "3.14.2" {
+		t.Errorf("expected version 3.14.2 to be resolved, got %s", tf.Status.ResolvedModuleVersion)
+	}
+
+	// Hosts with a port can not be used as TF_TOKEN_ variables
+	envs, err := r.getRegistryTokenEnvs(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 0 {
+		t.Errorf("expected no token envs, got %v", envs)
+	}
+	tf.Spec.SCMAuthMethods[0].Host = "tf-registry.example.com"
+	envs, err = r.getRegistryTokenEnvs(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(envs["TF_TOKEN_tf__registry_example_com"]); got != "t0ken" {
+		t.Errorf("expected the token env, got %v", envs)
+	}
+}
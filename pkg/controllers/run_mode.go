@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// runMode returns the mode of the resource, which defaults to apply
+func runMode(tf *tfv1alpha2.Terraform) tfv1alpha2.RunMode {
+	if tf.Spec.Mode == "" {
+		return tfv1alpha2.RunModeApply
+	}
+	return tf.Spec.Mode
+}
+
+// skippedTasks are the tasks the mode does not run. The plan-only mode does not apply anything and only
+// the apply mode applies the destroy plan, unless the resource has been applied by the apply mode before.
+func skippedTasks(mode tfv1alpha2.RunMode, applied bool) []tfv1alpha2.TaskName {
+	skipped := []tfv1alpha2.TaskName{}
+	if mode == tfv1alpha2.RunModePlanOnly {
+		skipped = append(skipped, tfv1alpha2.RunPreApply, tfv1alpha2.RunApply, tfv1alpha2.RunPostApply)
+	}
+	if mode != tfv1alpha2.RunModeApply && !applied {
+		skipped = append(skipped, tfv1alpha2.RunPreApplyDelete, tfv1alpha2.RunApplyDelete, tfv1alpha2.RunPostApplyDelete)
+	}
+	return skipped
+}
+
+// runResult is the result of a workflow of the mode that has completed
+func runResult(mode tfv1alpha2.RunMode) tfv1alpha2.RunResult {
+	switch mode {
+	case tfv1alpha2.RunModePlanOnly:
+		return tfv1alpha2.RunResultPlanned
+	case tfv1alpha2.RunModeRefreshOnly:
+		return tfv1alpha2.RunResultRefreshed
+	}
+	return tfv1alpha2.RunResultApplied
+}
+
+// modeEnvs tells the tasks the mode of the workflow. The plan task of the refresh-only mode plans a refresh.
+func (r TaskOptions) modeEnvs() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  "TFO_MODE",
+			Value: string(r.mode),
+		},
+	}
+}
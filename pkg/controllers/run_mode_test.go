@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+func newTestRunModeTerraform(mode tfv1alpha2.RunMode, applied bool) *tfv1alpha2.Terraform {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformVersion: "1.5.7",
+		Mode:             mode,
+		TaskOptions: []tfv1alpha2.TaskOption{
+			{
+				Affects: []tfv1alpha2.TaskName{tfv1alpha2.RunPostPlan, tfv1alpha2.RunPostApply, tfv1alpha2.RunPostApplyDelete},
+				Script:  tfv1alpha2.StageScript{Inline: "echo done"},
+			},
+		},
+	})
+	tf.Status.Applied = applied
+	return tf
+}
+
+func TestRunMode(t *testing.T) {
+	// workflow walks the tasks from the first task of a workflow
+	workflow := func(tf *tfv1alpha2.Terraform, first tfv1alpha2.TaskName) string {
+		configuredTasks := getConfiguredTasks(tf)
+		tasks := []string{}
+		for task := first; task != tfv1alpha2.RunNil; task = nextTask(task, configuredTasks) {
+			tasks = append(tasks, task.String())
+		}
+		return strings.Join(tasks, ",")
+	}
+
+	tests := []struct {
+		mode    tfv1alpha2.RunMode
+		applied bool
+		create  string
+		delete  string
+		result  tfv1alpha2.RunResult
+	}{
+		{
+			mode:   "",
+			create: "setup,init,plan,postplan,apply,postapply",
+			delete: "setup-delete,init-delete,plan-delete,apply-delete,postapply-delete",
+			result: tfv1alpha2.RunResultApplied,
+		},
+		{
+			mode:   tfv1alpha2.RunModeApply,
+			create: "setup,init,plan,postplan,apply,postapply",
+			delete: "setup-delete,init-delete,plan-delete,apply-delete,postapply-delete",
+			result: tfv1alpha2.RunResultApplied,
+		},
+		{
+			mode:   tfv1alpha2.RunModePlanOnly,
+			create: "setup,init,plan,postplan",
+			delete: "setup-delete,init-delete,plan-delete",
+			result: tfv1alpha2.RunResultPlanned,
+		},
+		{
+			mode:   tfv1alpha2.RunModeRefreshOnly,
+			create: "setup,init,plan,postplan,apply,postapply",
+			delete: "setup-delete,init-delete,plan-delete",
+			result: tfv1alpha2.RunResultRefreshed,
+		},
+		{
+			// The resources applied before the mode changed are destroyed with the resource
+			mode:    tfv1alpha2.RunModePlanOnly,
+			applied: true,
+			create:  "setup,init,plan,postplan",
+			delete:  "setup-delete,init-delete,plan-delete,apply-delete,postapply-delete",
+			result:  tfv1alpha2.RunResultPlanned,
+		},
+		{
+			mode:    tfv1alpha2.RunModeRefreshOnly,
+			applied: true,
+			create:  "setup,init,plan,postplan,apply,postapply",
+			delete:  "setup-delete,init-delete,plan-delete,apply-delete,postapply-delete",
+			result:  tfv1alpha2.RunResultRefreshed,
+		},
+	}
+	for _, tt := range tests {
+		tf := newTestRunModeTerraform(tt.mode, tt.applied)
+		if got := workflow(tf, tfv1alpha2.RunSetup); got != tt.create {
+			t.Errorf("%q applied=%t: expected the tasks %s, got %s", tt.mode, tt.applied, tt.create, got)
+		}
+		if got := workflow(tf, tfv1alpha2.RunSetupDelete); got != tt.delete {
+			t.Errorf("%q applied=%t: expected the delete tasks %s, got %s", tt.mode, tt.applied, tt.delete, got)
+		}
+		if got := runResult(runMode(tf)); got != tt.result {
+			t.Errorf("%q: expected the result %s, got %s", tt.mode, tt.result, got)
+		}
+	}
+}
+
+func TestRunModeTasks(t *testing.T) {
+	// Every task is told the mode so the scripts can skip what the mode does not run
+	for _, mode := range []tfv1alpha2.RunMode{tfv1alpha2.RunModeApply, tfv1alpha2.RunModePlanOnly, tfv1alpha2.RunModeRefreshOnly} {
+		tf := newTestRunModeTerraform(mode, false)
+		if got := tasksWithEnv(tf, "TFO_MODE"); len(got) != len(terraformTaskNames)+len(otherTaskNames) {
+			t.Errorf("%q: expected every task to get the mode, got %v", mode, got)
+		}
+		if got := taskEnvs(tf, tfv1alpha2.RunPlan)["TFO_MODE"]; got != string(mode) {
+			t.Errorf("%q: expected TFO_MODE %q, got %q", mode, mode, got)
+		}
+	}
+}
+
+func TestNewStageResults(t *testing.T) {
+	tests := []struct {
+		reason string
+		task   tfv1alpha2.TaskName
+		mode   tfv1alpha2.RunMode
+		clear  bool
+		apply  bool
+	}{
+		{reason: "GENERATION_CHANGE", task: tfv1alpha2.RunSetup, clear: true},
+		{reason: "INPUTS_CHANGED", task: tfv1alpha2.RunSetup, clear: true},
+		{reason: "SOURCE_CHANGED", task: tfv1alpha2.RunSetup, clear: true},
+		{reason: "GIT_PUSH", task: tfv1alpha2.RunSetup, clear: true},
+		{reason: "COMPLETED_PLAN", task: tfv1alpha2.RunApply, apply: true},
+		{reason: "COMPLETED_PLAN", task: tfv1alpha2.RunApply, mode: tfv1alpha2.RunModeRefreshOnly},
+	}
+	for _, tt := range tests {
+		tf := &tfv1alpha2.Terraform{Spec: tfv1alpha2.TerraformSpec{Mode: tt.mode}}
+		tf.Status.Result = tfv1alpha2.RunResultPlanned
+		tf.Status.PlanResults = []tfv1alpha2.PlanResult{{Module: "vpc", Add: 1}}
+		newStage(tf, tt.task, tt.reason, tfv1alpha2.CanBeInterrupt, tfv1alpha2.StateInitializing)
+		if cleared := tf.Status.Result == "" && tf.Status.PlanResults == nil; cleared != tt.clear {
+			t.Errorf("%s: expected the results to be cleared %t, got %s %v", tt.reason, tt.clear, tf.Status.Result, tf.Status.PlanResults)
+		}
+		if tf.Status.Applied != tt.apply {
+			t.Errorf("%s %s %q: expected applied %t", tt.reason, tt.task, tt.mode, tt.apply)
+		}
+	}
+}
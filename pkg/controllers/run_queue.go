@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// runQueueLockKey is the cache key held while a workflow is admitted so two reconcilers do not take
+	// the same free slot.
+	runQueueLockKey = "run-queue-lock"
+)
+
+// isRunQueueEnabled returns true when the controller is configured to limit the number of workflows that
+// can run at the same time.
+func (r ReconcileTerraform) isRunQueueEnabled() bool {
+	return r.MaxConcurrentWorkflows > 0 || r.MaxConcurrentWorkflowsPerNamespace > 0
+}
+
+// isWaitingToRun returns true when the workflow has not created its first task pod yet. These are the
+// only workflows held by the run queue; a workflow that has started is never paused.
+func isWaitingToRun(tf *tfv1alpha2.Terraform) bool {
+	if tf.Status.Stage.State == tfv1alpha2.StateFailed {
+		return false
+	}
+	switch tf.Status.Phase {
+	case tfv1alpha2.PhaseInitializing, tfv1alpha2.PhaseInitDelete, tfv1alpha2.PhaseQueued:
+		return true
+	}
+	return false
+}
+
+// setQueued marks the workflow as waiting to run. Delete workflows keep their phase since the delete phases
+// are what tells the controller the resource is being deleted.
+func setQueued(tf *tfv1alpha2.Terraform) {
+	tf.Status.Queued = true
+	if tf.Status.Phase != tfv1alpha2.PhaseInitDelete {
+		tf.Status.Phase = tfv1alpha2.PhaseQueued
+	}
+}
+
+// isWorkflowActive returns true when the workflow has task pods running and is taking up a slot.
+func isWorkflowActive(tf *tfv1alpha2.Terraform) bool {
+	if tf.Status.Stage.State == tfv1alpha2.StateFailed || tf.Status.Stage.TaskType == tfv1alpha2.RunNil {
+		return false
+	}
+	return tf.Status.Phase == tfv1alpha2.PhaseRunning || tf.Status.Phase == tfv1alpha2.PhaseDeleting
+}
+
+// getRunQueuePosition returns the position of the workflow in the run queue. A position of 0 means the
+// workflow can start now.
+func (r ReconcileTerraform) getRunQueuePosition(ctx context.Context, tf *tfv1alpha2.Terraform) (int, error) {
+	tfList := &tfv1alpha2.TerraformList{}
+	err := r.Client.List(ctx, tfList)
+	if err != nil {
+		return 0, err
+	}
+	return runQueuePosition(tf, tfList.Items, r.MaxConcurrentWorkflows, r.MaxConcurrentWorkflowsPerNamespace), nil
+}
+
+// runQueuePosition orders the workflows that are waiting to run by priority, then by the time the
+// workflow's stage started, and hands out the free slots in that order. Workflows that are ahead in the
+// queue but blocked by their namespace's limit do not hold back workflows in other namespaces.
+func runQueuePosition(tf *tfv1alpha2.Terraform, items []tfv1alpha2.Terraform, maxConcurrent, maxConcurrentPerNamespace int) int {
+	key := types.NamespacedName{Namespace: tf.Namespace, Name: tf.Name}
+	active := 0
+	activeInNamespace := map[string]int{}
+	waiting := []tfv1alpha2.Terraform{*tf}
+	for _, item := range items {
+		if item.Namespace == key.Namespace && item.Name == key.Name {
+			continue
+		}
+		if isWorkflowActive(&item) {
+			active++
+			activeInNamespace[item.Namespace]++
+		} else if isWaitingToRun(&item) && !isBlockedByConcurrencyGroup(&item) && len(item.Status.WaitingOn) == 0 {
+			waiting = append(waiting, item)
+		}
+	}
+
+	sort.SliceStable(waiting, func(i, j int) bool {
+		if waiting[i].Spec.Priority != waiting[j].Spec.Priority {
+			return waiting[i].Spec.Priority > waiting[j].Spec.Priority
+		}
+		iStartTime := waiting[i].Status.Stage.StartTime
+		jStartTime := waiting[j].Status.Stage.StartTime
+		if !iStartTime.Equal(&jStartTime) {
+			return iStartTime.Before(&jStartTime)
+		}
+		if waiting[i].Namespace != waiting[j].Namespace {
+			return waiting[i].Namespace < waiting[j].Namespace
+		}
+		return waiting[i].Name < waiting[j].Name
+	})
+
+	position := 0
+	for _, item := range waiting {
+		hasSlot := maxConcurrent <= 0 || active < maxConcurrent
+		hasNamespaceSlot := maxConcurrentPerNamespace <= 0 || activeInNamespace[item.Namespace] < maxConcurrentPerNamespace
+		isSelf := item.Namespace == key.Namespace && item.Name == key.Name
+		if hasSlot && hasNamespaceSlot {
+			if isSelf {
+				return 0
+			}
+			// The slot is held for the workflow ahead in the queue until it gets reconciled
+			active++
+			activeInNamespace[item.Namespace]++
+			continue
+		}
+		position++
+		if isSelf {
+			return position
+		}
+	}
+	return position
+}
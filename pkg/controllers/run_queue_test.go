@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newQueueTestTerraform(namespace, name string, phase tfv1alpha2.StatusPhase, priority int32, startTime time.Time) tfv1alpha2.Terraform {
+	return tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       tfv1alpha2.TerraformSpec{Priority: priority},
+		Status: tfv1alpha2.TerraformStatus{
+			Phase: phase,
+			Stage: tfv1alpha2.Stage{
+				TaskType:  tfv1alpha2.RunSetup,
+				State:     tfv1alpha2.StateInitializing,
+				StartTime: metav1.NewTime(startTime),
+			},
+		},
+	}
+}
+
+func TestRunQueuePosition(t *testing.T) {
+	now := time.Now()
+	running := newQueueTestTerraform("a", "running", tfv1alpha2.PhaseRunning, 0, now.Add(-time.Hour))
+	running.Status.Stage.State = tfv1alpha2.StateInProgress
+	failed := newQueueTestTerraform("a", "failed", tfv1alpha2.PhaseRunning, 0, now.Add(-time.Hour))
+	failed.Status.Stage.State = tfv1alpha2.StateFailed
+	oldest := newQueueTestTerraform("a", "oldest", tfv1alpha2.PhaseQueued, 0, now.Add(-time.Minute))
+	important := newQueueTestTerraform("b", "important", tfv1alpha2.PhaseQueued, 10, now)
+	newest := newQueueTestTerraform("b", "newest", tfv1alpha2.PhaseInitializing, 0, now.Add(time.Minute))
+	items := []tfv1alpha2.Terraform{running, failed, oldest, important, newest}
+
+	tests := []struct {
+		name                      string
+		tf                        tfv1alpha2.Terraform
+		maxConcurrent             int
+		maxConcurrentPerNamespace int
+		want                      int
+	}{
+		{name: "no limits", tf: newest, want: 0},
+		{name: "higher priority gets the free slot", tf: important, maxConcurrent: 2, want: 0},
+		{name: "equal priority waits behind older", tf: newest, maxConcurrent: 2, want: 2},
+		{name: "older gets queued behind higher priority", tf: oldest, maxConcurrent: 2, want: 1},
+		{name: "namespace limit does not block other namespaces", tf: important, maxConcurrentPerNamespace: 1, want: 0},
+		{name: "namespace limit", tf: oldest, maxConcurrentPerNamespace: 1, want: 1},
+		{name: "namespace limit after slot is taken", tf: newest, maxConcurrentPerNamespace: 1, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runQueuePosition(&tt.tf, items, tt.maxConcurrent, tt.maxConcurrentPerNamespace)
+			if got != tt.want {
+				t.Errorf("expected position %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// runnerPath is where the init container puts the runner
+const runnerPath = "/tmp/tfo-runner"
+
+// runnerInitContainer copies the runner from the runner image into a volume shared with the task
+// container. The task container runs the runner instead of the entrypoint of its image.
+func (r TaskOptions) runnerInitContainer(securityContext *corev1.SecurityContext) (corev1.Volume, corev1.VolumeMount, corev1.Container) {
+	volume := corev1.Volume{
+		Name: "tfo-runner",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: runnerPath,
+		ReadOnly:  true,
+	}
+	container := corev1.Container{
+		Name:            "runner",
+		SecurityContext: securityContext,
+		Image:           r.runnerImage,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/runner", "install", runnerPath},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: volume.Name, MountPath: runnerPath},
+		},
+	}
+	return volume, mount, container
+}
+
+// runnerCommand is the command of the task container that runs the runner
+func runnerCommand() []string {
+	return []string{filepath.Join(runnerPath, "runner")}
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+func TestRunnerImage(t *testing.T) {
+	runOpts := newTaskOptions(newTestTerraform(tfv1alpha2.TerraformSpec{}), tfv1alpha2.RunPlan, 1, nil)
+	pod := runOpts.generatePod()
+	if len(pod.Spec.InitContainers) != 0 || len(pod.Spec.Containers[0].Command) != 0 {
+		t.Errorf("expected the task image's entrypoint without a runner image")
+	}
+
+	runOpts.runnerImage = "example.com/runner:v1"
+	pod = runOpts.generatePod()
+	if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Image != "example.com/runner:v1" {
+		t.Fatalf("expected the runner init container, got %v", pod.Spec.InitContainers)
+	}
+	task := pod.Spec.Containers[len(pod.Spec.Containers)-1]
+	if len(task.Command) != 1 || task.Command[0] != runnerPath+"/runner" {
+		t.Errorf("expected the task to run the runner, got %v", task.Command)
+	}
+	mounted := false
+	for _, mount := range task.VolumeMounts {
+		mounted = mounted || (mount.MountPath == runnerPath && mount.ReadOnly)
+	}
+	if !mounted {
+		t.Errorf("expected the runner to be mounted in the task container")
+	}
+}
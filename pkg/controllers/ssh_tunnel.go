@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// sshTunnelHost is the host in the generated ssh config that serves the SOCKS5 proxy
+	sshTunnelHost = "proxy-tunnel"
+
+	// sshTunnelSocksAddress is where the SOCKS5 proxy of the ssh tunnel listens in the task pod
+	sshTunnelSocksAddress = "127.0.0.1:1080"
+)
+
+// getTunnelHosts returns the hosts of the https scm auth methods that require the ssh tunnel
+func getTunnelHosts(tf *tfv1alpha2.Terraform) []string {
+	if tf.Spec.SSHTunnel == nil {
+		return nil
+	}
+	hosts := []string{}
+	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Git == nil || m.Git.HTTPS == nil || !m.Git.HTTPS.RequireProxy || m.Host == "" {
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(m.Host))
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// tunnelEnvs tells the runner to start the ssh tunnel and configures git to use the tunnel's SOCKS5 proxy
+// for the hosts. The git config is set with GIT_CONFIG_COUNT so it does not need to be written to a file.
+// The runner does not start the tunnel when the tunnel sidecar already runs it.
+func tunnelEnvs(hosts []string, startTunnel bool) []corev1.EnvVar {
+	if len(hosts) == 0 {
+		return nil
+	}
+	envs := []corev1.EnvVar{}
+	if startTunnel {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "TFO_SSH_TUNNEL_HOST",
+			Value: sshTunnelHost,
+		})
+	}
+	envs = append(envs, []corev1.EnvVar{
+		{
+			Name:  "TFO_SSH_TUNNEL_SOCKS_ADDRESS",
+			Value: sshTunnelSocksAddress,
+		},
+		{
+			Name:  "TFO_SSH_TUNNEL_HOSTS",
+			Value: strings.Join(hosts, ","),
+		},
+		{
+			Name:  "GIT_CONFIG_COUNT",
+			Value: fmt.Sprint(len(hosts)),
+		},
+	}...)
+	for i, host := range hosts {
+		envs = append(envs, []corev1.EnvVar{
+			{
+				Name:  fmt.Sprintf("GIT_CONFIG_KEY_%d", i),
+				Value: fmt.Sprintf("http.https://%s/.proxy", host),
+			},
+			{
+				Name:  fmt.Sprintf("GIT_CONFIG_VALUE_%d", i),
+				Value: "socks5h://" + sshTunnelSocksAddress,
+			},
+		}...)
+	}
+	return envs
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestSSHTunnelTerraform() *tfv1alpha2.Terraform {
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformModule: tfv1alpha2.Module{Source: "https://GHE.internal.example.com/org/vpc.git"},
+		SCMAuthMethods: []tfv1alpha2.SCMAuthMethod{
+			{
+				Host: "GHE.internal.example.com",
+				Git: &tfv1alpha2.GitSCM{
+					HTTPS: &tfv1alpha2.GitHTTPS{RequireProxy: true, TokenSecretRef: &tfv1alpha2.TokenSecretRef{Name: "ghe-token"}},
+				},
+			},
+			{
+				Host: "github.com",
+				Git: &tfv1alpha2.GitSCM{
+					HTTPS: &tfv1alpha2.GitHTTPS{TokenSecretRef: &tfv1alpha2.TokenSecretRef{Name: "ghe-token"}},
+				},
+			},
+		},
+		SSHTunnel: &tfv1alpha2.ProxyOpts{Host: "bastion.example.com", User: "tunnel", SSHKeySecretRef: tfv1alpha2.SSHKeySecretRef{Name: "bastion"}},
+	})
+}
+
+func newTestSSHTunnelClient() client.Client {
+	return newTestReconciler(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bastion"},
+			Data:       map[string][]byte{"id_rsa": []byte("proxy key")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ghe-token"},
+			Data:       map[string][]byte{"token": []byte("t0ken")},
+		},
+	).Client
+}
+
+func TestSSHTunnelConfig(t *testing.T) {
+	k8sClient := newTestSSHTunnelClient()
+	tf := newTestSSHTunnelTerraform()
+	tf.Spec.SSHTunnel = nil
+	if _, err := formatJobSSHConfig(context.TODO(), logr.Discard(), tf, k8sClient); err == nil {
+		t.Error("expected an error when the ssh tunnel is not defined")
+	}
+
+	data, err := formatJobSSHConfig(context.TODO(), logr.Discard(), newTestSSHTunnelTerraform(), k8sClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := string(data["config"])
+	for _, want := range []string{"Host proxy-tunnel\n", "\tHostname bastion.example.com\n", "\tDynamicForward 127.0.0.1:1080\n"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected the ssh config to contain %q, got:\n%s", want, config)
+		}
+	}
+	if string(data["proxy_key"]) != "proxy key" {
+		t.Errorf("expected the proxy key in the secret, got %v", data)
+	}
+}
+
+func TestSSHTunnelForHTTPS(t *testing.T) {
+	tf := newTestSSHTunnelTerraform()
+	data, err := formatJobSSHConfig(context.TODO(), logr.Discard(), tf, newTestSSHTunnelClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOpts := newTaskOptions(tf, tfv1alpha2.RunSetup, 1, nil)
+	runOpts.secretData = data
+	pod := runOpts.generatePod()
+	envs := podEnvs(pod.Spec.Containers[0])
+	want := map[string]string{
+		"TFO_SSH_TUNNEL_HOST":          "proxy-tunnel",
+		"TFO_SSH_TUNNEL_SOCKS_ADDRESS": "127.0.0.1:1080",
+		"TFO_SSH_TUNNEL_HOSTS":         "ghe.internal.example.com",
+		"GIT_CONFIG_COUNT":             "1",
+		"GIT_CONFIG_KEY_0":             "http.https://ghe.internal.example.com/.proxy",
+		"GIT_CONFIG_VALUE_0":           "socks5h://127.0.0.1:1080",
+	}
+	for name, value := range want {
+		if envs[name] != value {
+			t.Errorf("expected %s=%s, got %s", name, value, envs[name])
+		}
+	}
+	items := map[string]bool{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == "ssh" {
+			for _, item := range volume.Secret.Items {
+				items[item.Key] = true
+			}
+		}
+	}
+	if !items["config"] || !items["proxy_key"] {
+		t.Errorf("expected the ssh config and key to be mounted, got %v", items)
+	}
+}
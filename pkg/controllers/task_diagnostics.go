@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// errorMarkerFile is where the task is expected to write a short description of why it failed. The
+	// kubelet copies the content of this file into the container's termination message.
+	errorMarkerFile = "/dev/termination-log"
+
+	// maxLogTailBytes caps the amount of log data copied into the resource status
+	maxLogTailBytes = int64(4096)
+
+	// maxEventMessageLength is just under the length the apiserver allows for event messages
+	maxEventMessageLength = 1000
+
+	maskedValue = "***"
+)
+
+// sensitiveKeyValuePattern finds things like `password=foo` or `"token": "foo"` in log output so the value
+// can be masked even when the value is not known to the controller.
+var sensitiveKeyValuePattern = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key)["']?\s*[:=]\s*["']?)([^\s"',]+)`)
+
+// getTaskTermination returns the exit code and the termination reason and message of the task container.
+// A pod level reason, such as when the pod is evicted or the deadline is exceeded, takes precedence over
+// the container's reason since the container was killed because of it.
+func getTaskTermination(pod *corev1.Pod) (*int32, string, string) {
+	var exitCode *int32
+	reason := ""
+	message := ""
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name != "task" {
+			continue
+		}
+		if terminated := containerStatus.State.Terminated; terminated != nil {
+			code := terminated.ExitCode
+			exitCode = &code
+			reason = terminated.Reason
+			message = strings.TrimSpace(terminated.Message)
+		}
+	}
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+		if message == "" {
+			message = strings.TrimSpace(pod.Status.Message)
+		}
+	}
+	return exitCode, reason, message
+}
+
+// describeTaskFailure puts together a one line summary of why the task failed.
+func describeTaskFailure(exitCode *int32, reason, errorMarker string) string {
+	details := []string{}
+	if reason != "" {
+		details = append(details, reason)
+	}
+	if exitCode != nil {
+		details = append(details, fmt.Sprintf("exit code %d", *exitCode))
+	}
+	summary := strings.Join(details, ", ")
+	if errorMarker != "" {
+		firstLine := strings.SplitN(errorMarker, "\n", 2)[0]
+		if summary == "" {
+			return firstLine
+		}
+		return fmt.Sprintf("%s: %s", summary, firstLine)
+	}
+	return summary
+}
+
+// setTaskFailureDiagnostics fills in the stage with the reason the task failed and a tail of the task's
+// log. The termination message and the log are masked since they end up in the status and events.
+func (r ReconcileTerraform) setTaskFailureDiagnostics(ctx context.Context, tf *tfv1alpha2.Terraform, pod *corev1.Pod, runOpts TaskOptions) {
+	sensitiveValues := r.getSensitiveValues(ctx, tf, runOpts)
+
+	exitCode, reason, errorMarker := getTaskTermination(pod)
+	tf.Status.Stage.ExitCode = exitCode
+	tf.Status.Stage.TerminationReason = reason
+	tf.Status.Stage.ErrorMarker = maskSensitiveValues(errorMarker, sensitiveValues)
+
+	logTail, err := r.getTaskLogTail(ctx, pod)
+	if err != nil {
+		r.Log.V(1).Info(fmt.Sprintf("Could not get logs of pod '%s': %s", pod.Name, err))
+	}
+	tf.Status.Stage.LogTail = maskSensitiveValues(logTail, sensitiveValues)
+}
+
+// recordTaskFailure records a Warning event with the stage's failure summary and log tail so the failure
+// can be triaged without reading the pod logs.
+func (r ReconcileTerraform) recordTaskFailure(tf *tfv1alpha2.Terraform) {
+	message := tf.Status.Stage.Message
+	if tf.Status.Stage.LogTail != "" {
+		message = fmt.Sprintf("%s\n%s", message, tf.Status.Stage.LogTail)
+	}
+	r.Recorder.Event(tf, "Warning", "TaskFailed", truncateMessage(message, maxEventMessageLength))
+}
+
+// truncateMessage cuts the message to at most max bytes without splitting a multi-byte character
+func truncateMessage(message string, max int) string {
+	if len(message) <= max {
+		return message
+	}
+	for max > 0 && !utf8.RuneStart(message[max]) {
+		max--
+	}
+	return message[:max]
+}
+
+// getTaskLogTail fetches the last lines of the task container's log. Logs are skipped when the controller
+// is not configured with a clientset.
+func (r ReconcileTerraform) getTaskLogTail(ctx context.Context, pod *corev1.Pod) (string, error) {
+	if r.Clientset == nil || r.TaskFailureLogLines <= 0 {
+		return "", nil
+	}
+	tailLines := r.TaskFailureLogLines
+	limitBytes := maxLogTailBytes
+	b, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  "task",
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n"), nil
+}
+
+// scanTaskLog reads the whole log of the task container and returns the lines the patterns match. The
+// summaries terraform prints are spread over the log and at its end so the log can not be truncated, but
+// only the matching lines are kept. Logs are skipped when the controller is not configured with a
+// clientset.
+func (r ReconcileTerraform) scanTaskLog(ctx context.Context, pod *corev1.Pod, patterns ...*regexp.Regexp) (string, error) {
+	if r.Clientset == nil {
+		return "", nil
+	}
+	stream, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: "task",
+	}).Stream(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	return filterLogLines(stream, patterns...)
+}
+
+// filterLogLines returns the lines of the log the patterns match without terraform's color codes
+func filterLogLines(log io.Reader, patterns ...*regexp.Regexp) (string, error) {
+	lines := []string{}
+	reader := bufio.NewReader(log)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = ansiEscapePattern.ReplaceAllString(strings.TrimRight(line, "\r\n"), "")
+			for _, pattern := range patterns {
+				if pattern.MatchString(line) {
+					lines = append(lines, line)
+					break
+				}
+			}
+		}
+		if err == io.EOF {
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// getSensitiveValues collects the values of the secrets made available to the task so they can be masked
+// out of anything copied from the task into the resource.
+func (r ReconcileTerraform) getSensitiveValues(ctx context.Context, tf *tfv1alpha2.Terraform, runOpts TaskOptions) []string {
+	values := []string{}
+	for _, value := range r.GlobalEnvFromSecretData {
+		values = append(values, string(value))
+	}
+	for _, value := range runOpts.secretData {
+		values = append(values, string(value))
+	}
+
+	// The versioned secret holds the git tokens and ssh keys of the generation
+	secretNames := []string{runOpts.versionedName}
+	for _, envFrom := range runOpts.envFrom {
+		if envFrom.SecretRef != nil {
+			secretNames = append(secretNames, envFrom.SecretRef.Name)
+		}
+	}
+	for _, env := range runOpts.env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			secretNames = append(secretNames, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+	for _, c := range runOpts.credentials {
+		if c.SecretNameRef.Name != "" {
+			secretNames = append(secretNames, c.SecretNameRef.Name)
+		}
+	}
+	for _, name := range secretNames {
+		secret, err := r.loadSecret(ctx, name, tf.Namespace)
+		if err != nil {
+			continue
+		}
+		for _, value := range secret.Data {
+			values = append(values, string(value))
+		}
+	}
+	return values
+}
+
+// maskSensitiveValues replaces known secret values, and values that look like credentials, with asterisks.
+// Very short values are ignored since masking them would make the output unreadable.
+func maskSensitiveValues(s string, sensitiveValues []string) string {
+	if s == "" {
+		return s
+	}
+	for _, value := range sensitiveValues {
+		value = strings.TrimSpace(value)
+		if len(value) < 4 {
+			continue
+		}
+		s = strings.ReplaceAll(s, value, maskedValue)
+	}
+	return sensitiveKeyValuePattern.ReplaceAllString(s, "${1}"+maskedValue)
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestMaskSensitiveValues(t *testing.T) {
+	log := "Initializing provider\nexport AWS_SECRET_ACCESS_KEY=abcd1234efgh\npassword: hunter22\nusing token s3cr3tvalue"
+	got := maskSensitiveValues(log, []string{"s3cr3tvalue", "1"})
+	want := "Initializing provider\nexport AWS_SECRET_ACCESS_KEY=***\npassword: ***\nusing token ***"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDescribeTaskFailure(t *testing.T) {
+	exitCode := int32(137)
+	if got, want := describeTaskFailure(&exitCode, "OOMKilled", ""), "OOMKilled, exit code 137"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	exitCode = int32(1)
+	if got, want := describeTaskFailure(&exitCode, "Error", "terraform plan failed\nsee logs"), "Error, exit code 1: terraform plan failed"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got, want := describeTaskFailure(nil, "DeadlineExceeded", ""), "DeadlineExceeded"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFilterLogLines(t *testing.T) {
+	// The summaries of a run-all plan are at the end of a log larger than any cap on the log
+	log := "- Using hashicorp/aws v5.0.0 from the shared cache directory\n" +
+		strings.Repeat("module.vpc.aws_subnet.private[0]: Refreshing state...\n", 100000) +
+		"[/tmp/main/live/vpc] \x1b[1mPlan:\x1b[0m 2 to add, 0 to change, 0 to destroy.\n" +
+		"- Installing hashicorp/random v3.5.1...\n" +
+		"[/tmp/main/live/eks] No changes."
+	got, err := filterLogLines(strings.NewReader(log), planSummaryPattern, pluginCacheHitPattern, pluginCacheMissPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- Using hashicorp/aws v5.0.0 from the shared cache directory\n" +
+		"[/tmp/main/live/vpc] Plan: 2 to add, 0 to change, 0 to destroy.\n" +
+		"- Installing hashicorp/random v3.5.1...\n" +
+		"[/tmp/main/live/eks] No changes."
+	if got != want {
+		t.Errorf("expected the matching lines:\n%s\ngot:\n%s", want, got)
+	}
+	if results := parsePlanResults(got, "/tmp/main/live"); len(results) != 2 || results[0].Module != "vpc" || results[0].Add != 2 || results[1].Module != "eks" {
+		t.Errorf("expected the plan results of both modules, got %v", results)
+	}
+	if hits, misses := countPluginCacheUsage(got); hits != 1 || misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+}
+
+func TestTaskFailureIsMasked(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{})
+	tf.Status.Stage.Message = "Pod 'app-abc-v1-plan' Failed"
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-abc-v1-plan"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "task",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error", Message: "could not log in with s3cr3tvalue"},
+					},
+				},
+			},
+		},
+	}
+	r := newTestReconciler()
+	r.GlobalEnvFromSecretData = map[string][]byte{"TOKEN": []byte("s3cr3tvalue")}
+	recorder := record.NewFakeRecorder(1)
+	r.Recorder = recorder
+
+	r.setTaskFailureDiagnostics(context.TODO(), tf, pod, newTaskOptions(tf, tfv1alpha2.RunPlan, 1, nil))
+	summary := describeTaskFailure(tf.Status.Stage.ExitCode, tf.Status.Stage.TerminationReason, tf.Status.Stage.ErrorMarker)
+	if want := "Error, exit code 1: could not log in with ***"; summary != want {
+		t.Errorf("expected the masked summary %q, got %q", want, summary)
+	}
+	tf.Status.Stage.Message += ": " + summary
+	r.recordTaskFailure(tf)
+	if event := <-recorder.Events; strings.Contains(event, "s3cr3tvalue") {
+		t.Errorf("expected the event to be masked, got %q", event)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		max     int
+		want    string
+	}{
+		{message: "short", max: 10, want: "short"},
+		{message: "abcdef", max: 3, want: "abc"},
+		// "é" is two bytes and is dropped rather than split
+		{message: "abé", max: 3, want: "ab"},
+		{message: "ab→c", max: 4, want: "ab"},
+		{message: "ab→c", max: 5, want: "ab→"},
+	}
+	for _, tt := range tests {
+		got := truncateMessage(tt.message, tt.max)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("%q %d: expected %q, got %q", tt.message, tt.max, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/taskscripts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxScriptBytes caps the size of a pinned task script
+const maxScriptBytes = 1 << 20
+
+// taskScriptsConfigMapName is the ConfigMap with the default task scripts. The name changes with the
+// scripts so the tasks of generations that started before an upgrade of the controller keep working.
+var taskScriptsConfigMapName = taskscripts.ConfigMapPrefix + taskscripts.Version()
+
+// createTaskScriptsConfigMap creates the ConfigMap with the default task scripts in the namespace of the
+// resource. It is shared by the resources of the namespace so it is not owned by any of them.
+func (r ReconcileTerraform) createTaskScriptsConfigMap(ctx context.Context, tf *tfv1alpha2.Terraform) error {
+	kind := "ConfigMap"
+	_, found, err := r.checkConfigMapExists(ctx, types.NamespacedName{
+		Name:      taskScriptsConfigMapName,
+		Namespace: tf.Namespace,
+	})
+	if err != nil || found {
+		return err
+	}
+	immutable := true
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      taskScriptsConfigMapName,
+			Namespace: tf.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "terraform-operator",
+				"app.kubernetes.io/created-by": "controller",
+			},
+		},
+		Immutable: &immutable,
+		Data:      taskscripts.Scripts(),
+	}
+	err = r.Client.Create(ctx, configMap)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		r.Recorder.Event(tf, "Warning", fmt.Sprintf("%sCreateError", kind), fmt.Sprintf("Could not create %s %v", kind, err))
+		return err
+	}
+	return nil
+}
+
+// getPinnedScripts downloads the task scripts pinned with a checksum and verifies them. The verified
+// scripts are given to the tasks as inline scripts so the tasks never fetch the source.
+func (r ReconcileTerraform) getPinnedScripts(ctx context.Context, tf *tfv1alpha2.Terraform) (map[string]string, error) {
+	scripts := make(map[string]string)
+	var httpClient *http.Client
+	for _, taskOption := range tf.Spec.TaskOptions {
+		script := taskOption.Script
+		if script.Checksum == "" || script.Inline != "" {
+			continue
+		}
+		if script.Source == "" {
+			return nil, fmt.Errorf("the script checksum '%s' requires a source", script.Checksum)
+		}
+		if !strings.HasPrefix(script.Checksum, "sha256:") {
+			return nil, fmt.Errorf("the checksum of '%s' must be a sha256 checksum", script.Source)
+		}
+		if httpClient == nil {
+			var err error
+			httpClient, err = r.getHTTPClient(ctx, tf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		content, err := fetchScript(ctx, httpClient, script.Source)
+		if err != nil {
+			return nil, err
+		}
+		got := fmt.Sprintf("%x", sha256.Sum256(content))
+		if want := strings.ToLower(strings.TrimPrefix(script.Checksum, "sha256:")); got != want {
+			return nil, fmt.Errorf("checksum of '%s' did not match: expected %s, got %s", script.Source, want, got)
+		}
+		for _, affected := range taskOption.Affects {
+			if affected.String() == "*" {
+				continue
+			}
+			scripts[fmt.Sprintf("inline-%s.sh", affected)] = string(content)
+		}
+	}
+	return scripts, nil
+}
+
+func fetchScript(ctx context.Context, httpClient *http.Client, source string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch '%s': %s", source, resp.Status)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScriptBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch '%s': %v", source, err)
+	}
+	if len(content) > maxScriptBytes {
+		return nil, fmt.Errorf("'%s' is larger than %d bytes", source, maxScriptBytes)
+	}
+	return content, nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/taskscripts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestTaskScriptsTerraform(url, pinned string) *tfv1alpha2.Terraform {
+	return newTestTerraform(tfv1alpha2.TerraformSpec{
+		TaskOptions: []tfv1alpha2.TaskOption{
+			{
+				Affects: []tfv1alpha2.TaskName{tfv1alpha2.RunPostApply},
+				Script:  tfv1alpha2.StageScript{Source: url + "/post-apply.sh"},
+			},
+			{
+				Affects: []tfv1alpha2.TaskName{tfv1alpha2.RunPrePlan, "*"},
+				Script: tfv1alpha2.StageScript{
+					Source:   url + "/pre-plan.sh",
+					Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(pinned))),
+				},
+			},
+		},
+	})
+}
+
+func TestTaskScriptSources(t *testing.T) {
+	url := "https://scripts.example.com"
+	tf := newTestTaskScriptsTerraform(url, "#!/bin/sh\necho pinned\n")
+	tests := []struct {
+		task          tfv1alpha2.TaskName
+		urlSource     string
+		configMapKey  string
+		configMapName string
+	}{
+		{task: tfv1alpha2.RunSetup, configMapName: taskScriptsConfigMapName, configMapKey: taskscripts.Setup},
+		{task: tfv1alpha2.RunPlan, configMapName: taskScriptsConfigMapName, configMapKey: taskscripts.Terraform},
+		{task: tfv1alpha2.RunPreInit, configMapName: taskScriptsConfigMapName, configMapKey: taskscripts.Noop},
+		{task: tfv1alpha2.RunPostApply, urlSource: url + "/post-apply.sh"},
+	}
+	for _, tt := range tests {
+		runOpts := newTaskOptions(tf, tt.task, 1, nil)
+		if runOpts.urlSource != tt.urlSource || runOpts.configMapSourceName != tt.configMapName || runOpts.configMapSourceKey != tt.configMapKey {
+			t.Errorf("%s: expected %q %s/%s, got %q %s/%s", tt.task, tt.urlSource, tt.configMapName, tt.configMapKey, runOpts.urlSource, runOpts.configMapSourceName, runOpts.configMapSourceKey)
+		}
+	}
+	// Pinned sources are fetched and verified by the controller
+	if runOpts := newTaskOptions(tf, tfv1alpha2.RunPrePlan, 1, nil); runOpts.urlSource != "" {
+		t.Errorf("expected the pinned source to not be fetched by the task, got %s", runOpts.urlSource)
+	}
+}
+
+func TestPinnedScripts(t *testing.T) {
+	pinned := "#!/bin/sh\necho pinned\n"
+	script := pinned
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, script)
+	}))
+	defer server.Close()
+
+	tf := newTestTaskScriptsTerraform(server.URL, pinned)
+	r := newTestReconciler()
+	scripts, err := r.getPinnedScripts(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 1 || scripts["inline-preplan.sh"] != pinned {
+		t.Errorf("expected the verified pre-plan script, got %v", scripts)
+	}
+	script = "#!/bin/sh\necho changed\n"
+	if _, err := r.getPinnedScripts(context.TODO(), tf); err == nil || !strings.Contains(err.Error(), "did not match") {
+		t.Errorf("expected the changed script to fail the checksum, got %v", err)
+	}
+}
+
+func TestTaskScriptsConfigMap(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{})
+	r := newTestReconciler()
+	for i := 0; i < 2; i++ {
+		if err := r.createTaskScriptsConfigMap(context.TODO(), tf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: taskScriptsConfigMapName}, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Immutable == nil || !*configMap.Immutable || configMap.Data[taskscripts.Terraform] == "" || configMap.Data["common.sh"] == "" {
+		t.Errorf("unexpected task scripts ConfigMap %v", configMap.Data)
+	}
+}
@@ -16,10 +16,13 @@ import (
 	"github.com/go-logr/logr"
 	getter "github.com/hashicorp/go-getter"
 	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/download"
+	"github.com/isaaguilar/terraform-operator/pkg/taskscripts"
 	"github.com/isaaguilar/terraform-operator/pkg/utils"
 	localcache "github.com/patrickmn/go-cache"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -28,13 +31,17 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	runtimecontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -53,6 +60,66 @@ type ReconcileTerraform struct {
 	GlobalEnvFromConfigmapData map[string]string
 	GlobalEnvFromSecretData    map[string][]byte
 	GlobalEnvSuffix            string
+
+	// TaskPendingTimeout is the default amount of time a task pod can be pending before the stage is
+	// failed. A value of 0 disables the check. Can be overridden per task by taskOptions.pendingTimeout.
+	TaskPendingTimeout time.Duration
+
+	// Clientset is used for the things the controller-runtime client cannot do, like reading pod logs.
+	Clientset kubernetes.Interface
+
+	// TaskFailureLogLines is the number of lines of a failed task's log to copy into the stage status.
+	TaskFailureLogLines int64
+
+	// MaxConcurrentWorkflows is the number of workflows allowed to run at the same time across the cluster.
+	// Workflows over the limit are queued. A value of 0 means no limit.
+	MaxConcurrentWorkflows int
+
+	// MaxConcurrentWorkflowsPerNamespace is the number of workflows allowed to run at the same time in a
+	// single namespace. A value of 0 means no limit.
+	MaxConcurrentWorkflowsPerNamespace int
+
+	// ConcurrencyGroupNamespace is the namespace of the Leases used as the mutex of concurrency groups.
+	ConcurrencyGroupNamespace string
+
+	// TrustedCA is the PEM encoded CA bundle trusted by every resource's tasks and by the controller's git
+	// and registry clients. Resources add their own CAs with spec.trustedCA.
+	TrustedCA []byte
+
+	// PluginCacheVolume is the volume of the cluster plugin cache, eg a nfs share. Resources that use the
+	// cluster scope fail validation when it is not configured.
+	PluginCacheVolume *corev1.VolumeSource
+
+	// PluginCacheRetention is how long a provider version that is not installed by any init is kept in the
+	// plugin caches. Versions used by a running workflow are kept longer. Defaults to 720h.
+	PluginCacheRetention time.Duration
+
+	// CLIConfig is the default .terraformrc config of every resource. Resources merge their own with
+	// spec.cliConfig.
+	CLIConfig *tfv1alpha2.CLIConfig
+
+	// RunnerImage is the image of the task runner. When set, the setup, terraform and script tasks run the
+	// runner instead of the entrypoint of their image.
+	RunnerImage string
+
+	// EngineVersionsConfigMap is the ConfigMap that lists the available versions of each engine, keyed by
+	// the engine's name. The version constraints of the resources are resolved against the list.
+	EngineVersionsConfigMap *types.NamespacedName
+
+	// EngineVersionsFromRegistry resolves the version constraints against the tags of the terraform tasks'
+	// image when EngineVersionsConfigMap is not set.
+	EngineVersionsFromRegistry bool
+
+	// StrictEngineVersions rejects resources that run the `latest` version of their engine, which changes
+	// without a change to the resource.
+	StrictEngineVersions bool
+
+	// AllowCrossNamespaceDependencies lets resources depend on, and read the outputs of, resources in other
+	// namespaces. Dependencies are limited to the namespace of the resource by default.
+	AllowCrossNamespaceDependencies bool
+
+	// gitPollEvents queues resources that poll their git source
+	gitPollEvents chan event.GenericEvent
 }
 
 // createEnvFromSources adds any of the global environment vars defined at the controller scope
@@ -160,13 +227,28 @@ func (r *ReconcileTerraform) SetupWithManager(mgr ctrl.Manager) error {
 		// This is useful for testing webhooks
 		return nil
 	}
+	r.gitPollEvents = make(chan event.GenericEvent)
+	err := mgr.Add(manager.RunnableFunc(r.pollGitSources))
+	if err != nil {
+		return err
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &tfv1alpha2.Terraform{}, inputReferencesIndex, indexInputReferences)
+	if err != nil {
+		return err
+	}
+
 	// only listen to v1alpha2
-	err := ctrl.NewControllerManagedBy(mgr).
+	err = ctrl.NewControllerManagedBy(mgr).
 		For(&tfv1alpha2.Terraform{}).
 		Watches(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
 			IsController: true,
 			OwnerType:    &tfv1alpha2.Terraform{},
 		}).
+		Watches(&source.Kind{Type: &tfv1alpha2.Terraform{}}, handler.EnqueueRequestsFromMapFunc(r.mapDependencyRequests)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.mapInputRequests(inputKindConfigMap))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.mapInputRequests(inputKindSecret))).
+		Watches(&source.Channel{Source: r.gitPollEvents}, &handler.EnqueueRequestForObject{}).
 		WithOptions(controllerOptions).
 		Complete(r)
 	if err != nil {
@@ -196,7 +278,7 @@ type ParsedAddress struct {
 	Files []string `json:"files"`
 
 	// Hash is also known as the `ref` query argument. For git this is the
-	// commit-sha or branch-name to checkout.
+	// commit-sha or branch-name to checkout. It is empty for the default branch.
 	Hash string `json:"hash"`
 
 	// UrlScheme is the protocol of the URL
@@ -217,39 +299,69 @@ type ParsedAddress struct {
 	// Repo when using a SCM is the URL of the repo which is the same as the
 	// URL and omitting the query args.
 	Repo string `json:"repo"`
+
+	// Address is the go-getter address to download, including the forced
+	// getter, eg `git::https://...`.
+	Address string `json:"address"`
+
+	// Checksum is verified before the downloaded files are put into the main
+	// module directory.
+	Checksum string `json:"checksum"`
 }
 
 type TaskOptions struct {
 	annotations                         map[string]string
+	cliConfig                           bool
+	clusterPluginCache                  *corev1.VolumeSource
 	configMapSourceName                 string
 	configMapSourceKey                  string
 	credentials                         []tfv1alpha2.Credentials
+	engine                              tfv1alpha2.EngineName
 	env                                 []corev1.EnvVar
 	envFrom                             []corev1.EnvFromSource
 	generation                          int64
 	image                               string
 	imagePullPolicy                     corev1.PullPolicy
 	labels                              map[string]string
+	mode                                tfv1alpha2.RunMode
 	mainModulePluginData                map[string]string
 	namespace                           string
+	network                             *tfv1alpha2.Network
+	objectSources                       []objectSource
+	tunnelHosts                         []string
+	tunnelImage                         string
+	tunnelImagePullPolicy               corev1.PullPolicy
 	outputsSecretName                   string
 	outputsToInclude                    []string
 	outputsToOmit                       []string
+	pendingTimeout                      *time.Duration
+	pluginCache                         *tfv1alpha2.PluginCache
+	pluginCacheKeepSince                time.Time
+	pluginCacheRetention                time.Duration
 	policyRules                         []rbacv1.PolicyRule
 	prefixedName                        string
 	resourceLabels                      map[string]string
 	resourceName                        string
 	resourceUUID                        string
+	runnerImage                         string
 	task                                tfv1alpha2.TaskName
 	saveOutputs                         bool
 	secretData                          map[string][]byte
+	secretVariables                     bool
 	serviceAccount                      string
+	stateEncryption                     *tfv1alpha2.StateEncryption
+	terragrunt                          *tfv1alpha2.Terragrunt
 	cleanupDisk                         bool
 	stripGenerationLabelOnOutputsSecret bool
 	terraformModuleParsed               ParsedAddress
 	terraformVersion                    string
+	timeout                             *int64
+	trustedCA                           bool
 	urlSource                           string
+	variablesSecretData                 map[string][]byte
+	variablesSecretName                 string
 	versionedName                       string
+	workspace                           string
 }
 
 func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generation int64, globalEnvFrom []corev1.EnvFromSource) TaskOptions {
@@ -259,10 +371,7 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 	resourceUUID := string(tf.UID)
 	prefixedName := tf.Status.PodNamePrefix
 	versionedName := prefixedName + "-v" + fmt.Sprint(tf.Generation)
-	terraformVersion := tf.Spec.TerraformVersion
-	if terraformVersion == "" {
-		terraformVersion = "latest"
-	}
+	engine, terraformVersion := getEngine(tf)
 
 	image := ""
 	imagePullPolicy := corev1.PullAlways
@@ -275,6 +384,8 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 	urlSource := ""
 	configMapSourceName := ""
 	configMapSourceKey := ""
+	var timeout *int64
+	var pendingTimeout *time.Duration
 
 	// TaskOptions have data for all the tasks but since we're only interested
 	// in the ones for this taskType, extract and add them to RunOptions
@@ -290,9 +401,19 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 			}
 			env = append(env, taskOption.Env...)
 			envFrom = append(envFrom, taskOption.EnvFrom...)
+			if taskOption.Timeout != nil {
+				seconds := int64(math.Ceil(taskOption.Timeout.Duration.Seconds()))
+				timeout = &seconds
+			}
+			if taskOption.PendingTimeout != nil {
+				pendingTimeout = &taskOption.PendingTimeout.Duration
+			}
 		}
 		if tfv1alpha2.ListContainsTask(taskOption.Affects, task) {
-			urlSource = taskOption.Script.Source
+			// Pinned sources are verified and given to the task as inline scripts
+			if taskOption.Script.Checksum == "" {
+				urlSource = taskOption.Script.Source
+			}
 			if configMapSelector := taskOption.Script.ConfigMapSelector; configMapSelector != nil {
 				configMapSourceName = configMapSelector.Name
 				configMapSourceKey = configMapSelector.Key
@@ -312,16 +433,7 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 		}
 	}
 
-	if images.Terraform.Image == "" {
-		images.Terraform.Image = fmt.Sprintf("%s:%s", tfv1alpha2.TerraformTaskImageRepoDefault, terraformVersion)
-	} else {
-		terraformImage := images.Terraform.Image
-		splitImage := strings.Split(images.Terraform.Image, ":")
-		if length := len(splitImage); length > 1 {
-			terraformImage = strings.Join(splitImage[:length-1], ":")
-		}
-		images.Terraform.Image = fmt.Sprintf("%s:%s", terraformImage, terraformVersion)
-	}
+	images.Terraform.Image = terraformImage(tf, engine, terraformVersion)
 
 	if images.Setup == nil {
 		images.Setup = &tfv1alpha2.ImageConfig{
@@ -372,24 +484,50 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 		tfv1alpha2.RunSetupDelete,
 	}
 
+	// Only the setup task downloads the ConfigMaps and Secrets used as sources
+	objectSources := []objectSource{}
+
+	// Only the terraform tasks run the tunnel sidecar, use the plugin cache, decrypt the state, run
+	// terragrunt and select the workspace
+	tunnelImage := ""
+	var pluginCache *tfv1alpha2.PluginCache
+	var stateEncryption *tfv1alpha2.StateEncryption
+	var terragrunt *tfv1alpha2.Terragrunt
+	workspace := ""
+	tunnelImagePullPolicy := images.Setup.ImagePullPolicy
+
+	// Tasks without a script run the default scripts bundled with the operator
+	defaultScript := ""
+
 	if tfv1alpha2.ListContainsTask(terraformTasks, task) {
 		image = images.Terraform.Image
 		imagePullPolicy = images.Terraform.ImagePullPolicy
-		if urlSource == "" {
-			urlSource = "https://raw.githubusercontent.com/GalleyBytes/terraform-operator-tasks/master/tf.sh"
+		defaultScript = taskscripts.Terraform
+		if usesTunnelSidecar(tf) {
+			tunnelImage = tf.Spec.Network.Tunnel.Image
+			if tunnelImage == "" {
+				tunnelImage = images.Setup.Image
+			}
 		}
+		pluginCache = tf.Spec.PluginCache
+		if tf.Spec.Engine != nil && engine == tfv1alpha2.EngineOpenTofu {
+			stateEncryption = tf.Spec.Engine.StateEncryption
+		}
+		terragrunt = tf.Spec.Terragrunt
+		workspace = tf.Spec.Workspace
 	} else if tfv1alpha2.ListContainsTask(scriptTasks, task) {
 		image = images.Script.Image
 		imagePullPolicy = images.Script.ImagePullPolicy
-		if urlSource == "" {
-			urlSource = "https://raw.githubusercontent.com/GalleyBytes/terraform-operator-tasks/master/noop.sh"
-		}
+		defaultScript = taskscripts.Noop
 	} else if tfv1alpha2.ListContainsTask(setupTasks, task) {
 		image = images.Setup.Image
 		imagePullPolicy = images.Setup.ImagePullPolicy
-		if urlSource == "" {
-			urlSource = "https://raw.githubusercontent.com/GalleyBytes/terraform-operator-tasks/master/setup.sh"
-		}
+		defaultScript = taskscripts.Setup
+		objectSources = getObjectSources(tf)
+	}
+	if urlSource == "" && configMapSourceName == "" && defaultScript != "" {
+		configMapSourceName = taskScriptsConfigMapName
+		configMapSourceKey = defaultScript
 	}
 
 	// sshConfig := utils.TruncateResourceName(tf.Name, 242) + "-ssh-config"
@@ -402,6 +540,20 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 
 	credentials := tf.Spec.Credentials
 
+	// The tokens of registries and of the cli config credentials are passed to every task as TF_TOKEN_*
+	// environment variables. The cli config credentials can be controller-wide, so the secret is optional
+	// and is always referenced.
+	variablesSecretName := versionedName + "-variables"
+	optionalVariablesSecret := true
+	envFrom = append(envFrom, corev1.EnvFromSource{
+		SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: variablesSecretName,
+			},
+			Optional: &optionalVariablesSecret,
+		},
+	})
+
 	// Outputs will be saved as a secret that will have the same lifecycle
 	// as the Terraform CustomResource by adding the ownership metadata
 	outputsSecretName := prefixedName + "-outputs"
@@ -422,13 +574,18 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 	}
 
 	resourceLabels := map[string]string{
-		"terraforms.tf.isaaguilar.com/generation":       fmt.Sprintf("%d", generation),
-		"terraforms.tf.isaaguilar.com/resourceName":     resourceName,
-		"terraforms.tf.isaaguilar.com/podPrefix":        prefixedName,
-		"terraforms.tf.isaaguilar.com/terraformVersion": terraformVersion,
-		"app.kubernetes.io/name":                        "terraform-operator",
-		"app.kubernetes.io/component":                   "terraform-operator-runner",
-		"app.kubernetes.io/created-by":                  "controller",
+		"terraforms.tf.isaaguilar.com/generation":    fmt.Sprintf("%d", generation),
+		"terraforms.tf.isaaguilar.com/resourceName":  resourceName,
+		"terraforms.tf.isaaguilar.com/podPrefix":     prefixedName,
+		"terraforms.tf.isaaguilar.com/engine":        string(engine),
+		"terraforms.tf.isaaguilar.com/engineVersion": versionLabelValue(terraformVersion),
+		"app.kubernetes.io/name":                     "terraform-operator",
+		"app.kubernetes.io/component":                "terraform-operator-runner",
+		"app.kubernetes.io/created-by":               "controller",
+	}
+
+	if engine == tfv1alpha2.EngineTerraform {
+		resourceLabels["terraforms.tf.isaaguilar.com/terraformVersion"] = versionLabelValue(terraformVersion)
 	}
 
 	if task.ID() == -2 {
@@ -447,10 +604,21 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 		labels:                              labels,
 		imagePullPolicy:                     imagePullPolicy,
 		namespace:                           tf.Namespace,
+		objectSources:                       objectSources,
+		tunnelHosts:                         getTunnelHosts(tf),
+		tunnelImage:                         tunnelImage,
+		tunnelImagePullPolicy:               tunnelImagePullPolicy,
+		network:                             tf.Spec.Network,
+		pluginCache:                         pluginCache,
 		resourceName:                        resourceName,
 		prefixedName:                        prefixedName,
 		versionedName:                       versionedName,
 		credentials:                         credentials,
+		engine:                              engine,
+		stateEncryption:                     stateEncryption,
+		terragrunt:                          terragrunt,
+		mode:                                runMode(tf),
+		workspace:                           workspace,
 		terraformVersion:                    terraformVersion,
 		image:                               image,
 		task:                                task,
@@ -459,6 +627,7 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 		serviceAccount:                      serviceAccount,
 		mainModulePluginData:                make(map[string]string),
 		secretData:                          make(map[string][]byte),
+		secretVariables:                     usesSecretVariables(tf),
 		cleanupDisk:                         cleanupDisk,
 		outputsSecretName:                   outputsSecretName,
 		saveOutputs:                         saveOutputs,
@@ -466,6 +635,10 @@ func newTaskOptions(tf *tfv1alpha2.Terraform, task tfv1alpha2.TaskName, generati
 		outputsToInclude:                    outputsToInclude,
 		outputsToOmit:                       outputsToOmit,
 		urlSource:                           urlSource,
+		variablesSecretData:                 make(map[string][]byte),
+		variablesSecretName:                 variablesSecretName,
+		timeout:                             timeout,
+		pendingTimeout:                      pendingTimeout,
 	}
 }
 
@@ -508,9 +681,14 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 
 	// Final delete by removing finalizers
 	if tf.Status.Phase == tfv1alpha2.PhaseDeleted {
+		err := r.releaseConcurrencyGroup(ctx, tf)
+		if err != nil {
+			reqLogger.V(1).Info(fmt.Sprintf("Failed to release concurrency group: %s", err))
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+		}
 		reqLogger.Info("Remove finalizers")
 		_ = updateFinalizer(tf)
-		err := r.update(ctx, tf)
+		err = r.update(ctx, tf)
 		if err != nil {
 			r.Recorder.Event(tf, "Warning", "ProcessingError", err.Error())
 			return reconcile.Result{}, err
@@ -588,7 +766,7 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 	stage := r.checkSetNewStage(ctx, tf)
 	if stage != nil {
 		tf.Status.Stage = *stage
-		if stage.Reason == "RESTARTED_WORKFLOW" || stage.Reason == "RESTARTED_DELETE_WORKFLOW" {
+		if stage.Reason == "RESTARTED_WORKFLOW" || stage.Reason == "RESTARTED_DELETE_WORKFLOW" || stage.Reason == "RESTARTED_AFTER_EVICTION" || stage.Reason == "INPUTS_CHANGED" || stage.Reason == "SOURCE_CHANGED" || stage.Reason == "GIT_PUSH" {
 			_ = r.removeOldPlan(tf)
 			// TODO what to do if the remove old plan function fails
 		}
@@ -604,6 +782,21 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 		return reconcile.Result{}, nil
 	}
 
+	if tf.Status.ConcurrencyGroupHolder == concurrencyGroupIdentity(tf) && !isWorkflowActive(tf) && !isWaitingToRun(tf) {
+		// The workflow is done or has failed so let the next resource in the group run
+		err := r.releaseConcurrencyGroup(ctx, tf)
+		if err != nil {
+			reqLogger.V(1).Info(fmt.Sprintf("Failed to release concurrency group: %s", err))
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+		tf.Status.ConcurrencyGroupHolder = ""
+		err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
+		if err != nil {
+			reqLogger.V(1).Info(err.Error())
+		}
+		return reconcile.Result{}, nil
+	}
+
 	globalEnvFrom := r.listEnvFromSources(tf)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -611,7 +804,19 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 	currentStage := tf.Status.Stage
 	podType := currentStage.TaskType
 	generation := currentStage.Generation
+	if podType != tfv1alpha2.RunNil {
+		// The version is pinned before it is used as the tag of the tasks' image
+		if err := r.resolveEngineVersion(ctx, tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "EngineVersionError", err.Error())
+			return reconcile.Result{}, err
+		}
+	}
 	runOpts := newTaskOptions(tf, currentStage.TaskType, generation, globalEnvFrom)
+	runOpts.trustedCA = r.usesTrustedCA(tf)
+	runOpts.cliConfig = r.usesCLIConfig(tf)
+	runOpts.clusterPluginCache = r.PluginCacheVolume
+	runOpts.pluginCacheRetention = r.PluginCacheRetention
+	runOpts.runnerImage = r.RunnerImage
 
 	if podType == tfv1alpha2.RunNil {
 		// podType is blank when the terraform workflow has completed for
@@ -620,6 +825,10 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 		if tf.Status.Phase == tfv1alpha2.PhaseRunning {
 			// Updates the status as "completed" on the resource
 			tf.Status.Phase = tfv1alpha2.PhaseCompleted
+			tf.Status.Result = runResult(runMode(tf))
+			if tf.Status.Result != tfv1alpha2.RunResultApplied {
+				r.Recorder.Event(tf, "Normal", "NothingApplied", fmt.Sprintf("The %s workflow completed without changing the infrastructure", runMode(tf)))
+			}
 			if tf.Spec.WriteOutputsToStatus {
 				// runOpts.outputsSecetName
 				secret, err := r.loadSecret(ctx, runOpts.outputsSecretName, runOpts.namespace)
@@ -692,7 +901,81 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 		return reconcile.Result{}, nil
 	}
 
+	if len(pods.Items) == 0 && tf.Status.Stage.State == tfv1alpha2.StateFailed && tf.Status.Stage.Reason == "TASK_PENDING_TIMEOUT" {
+		// The pod was removed by the controller because it never left the pending phase. Do not retry
+		// the task until the resource changes.
+		return reconcile.Result{}, nil
+	}
+
 	if len(pods.Items) == 0 {
+		if isWaitingToRun(tf) {
+			if err := r.validateDependencies(tf); err != nil {
+				r.Recorder.Event(tf, "Warning", "DependencyError", err.Error())
+				return reconcile.Result{}, nil
+			}
+			waitingOn, err := r.getUnmetDependencies(ctx, tf)
+			if err != nil {
+				reqLogger.Error(err, "Failed to check dependencies")
+				return reconcile.Result{}, err
+			}
+			if len(waitingOn) > 0 {
+				if strings.Join(tf.Status.WaitingOn, ",") != strings.Join(waitingOn, ",") || !tf.Status.Queued {
+					r.Recorder.Event(tf, "Normal", "WaitingOnDependencies", fmt.Sprintf("Waiting on %s", strings.Join(waitingOn, ", ")))
+					setQueued(tf)
+					tf.Status.WaitingOn = waitingOn
+					err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
+					if err != nil {
+						reqLogger.V(1).Info(err.Error())
+					}
+				}
+				return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+			}
+			tf.Status.WaitingOn = nil
+		}
+
+		if tf.Spec.ConcurrencyGroup != "" {
+			blocked, err := r.waitForConcurrencyGroup(ctx, tf, reqLogger)
+			if err != nil {
+				reqLogger.V(1).Info(fmt.Sprintf("Failed to acquire concurrency group '%s': %s", tf.Spec.ConcurrencyGroup, err))
+				return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			if blocked {
+				return reconcile.Result{RequeueAfter: 15 * time.Second}, nil
+			}
+		} else {
+			tf.Status.ConcurrencyGroupHolder = ""
+		}
+
+		if r.isRunQueueEnabled() && isWaitingToRun(tf) {
+			// Hold the queue lock until the status is updated so the slot is counted by the next reconciler
+			err := r.Cache.Add(runQueueLockKey, reconcilerID, time.Minute)
+			if err != nil {
+				reqLogger.V(6).Info("Run queue is locked")
+				return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			defer r.Cache.Delete(runQueueLockKey)
+
+			position, err := r.getRunQueuePosition(ctx, tf)
+			if err != nil {
+				reqLogger.Error(err, "Failed to get the run queue position")
+				return reconcile.Result{}, err
+			}
+			if position > 0 {
+				if !tf.Status.Queued {
+					r.Recorder.Event(tf, "Normal", "Queued", fmt.Sprintf("Concurrent workflow limit reached, queued at position %d", position))
+				}
+				if !tf.Status.Queued || tf.Status.QueuePosition != int32(position) {
+					setQueued(tf)
+					tf.Status.QueuePosition = int32(position)
+					err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
+					if err != nil {
+						reqLogger.V(1).Info(err.Error())
+					}
+				}
+				return reconcile.Result{RequeueAfter: 15 * time.Second}, nil
+			}
+		}
+
 		// Trigger a new pod when no pods are found for current stage
 		reqLogger.V(1).Info(fmt.Sprintf("Setting up the '%s' pod", podType))
 		err := r.setupAndRun(ctx, tf, runOpts)
@@ -700,11 +983,13 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 			reqLogger.Error(err, "")
 			return reconcile.Result{}, err
 		}
-		if tf.Status.Phase == tfv1alpha2.PhaseInitializing {
+		if tf.Status.Phase == tfv1alpha2.PhaseInitializing || tf.Status.Phase == tfv1alpha2.PhaseQueued {
 			tf.Status.Phase = tfv1alpha2.PhaseRunning
 		} else if tf.Status.Phase == tfv1alpha2.PhaseInitDelete {
 			tf.Status.Phase = tfv1alpha2.PhaseDeleting
 		}
+		tf.Status.Queued = false
+		tf.Status.QueuePosition = 0
 		tf.Status.Stage.State = tfv1alpha2.StateInProgress
 
 		// TODO because the pod is already running, is it critical that the
@@ -745,6 +1030,16 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 	podName := pods.Items[0].ObjectMeta.Name
 	podPhase := pods.Items[0].Status.Phase
 	msg := fmt.Sprintf("Pod '%s' %s", podName, podPhase)
+	if podPhase == corev1.PodFailed {
+		if tf.Status.Stage.State != tfv1alpha2.StateFailed {
+			// Only gather diagnostics the first time the failure is seen
+			r.setTaskFailureDiagnostics(ctx, tf, &pods.Items[0], runOpts)
+		}
+		// The summary is built from the masked diagnostics since it ends up in the status and events
+		if details := describeTaskFailure(tf.Status.Stage.ExitCode, tf.Status.Stage.TerminationReason, tf.Status.Stage.ErrorMarker); details != "" {
+			msg = fmt.Sprintf("%s: %s", msg, details)
+		}
+	}
 
 	// if tf.Status.Stage.PodName != podName {
 	// 	if tf.Status.Stage.PodName == "" {
@@ -755,22 +1050,15 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 	// 	}
 	// }
 	tf.Status.Stage.PodName = podName
-	if tf.Status.Stage.Message != msg {
+	if tf.Status.Stage.Message != msg && tf.Status.Stage.Reason != "TASK_PENDING_TIMEOUT" {
 		tf.Status.Stage.Message = msg
 		reqLogger.Info(msg)
 	}
 
-	// TODO Does the user need reason and message?
-	// reason := pods.Items[0].Status.Reason
-	// message := pods.Items[0].Status.Message
-	// if reason != "" {
-	// 	msg = fmt.Sprintf("%s %s", msg, reason)
-	// }
-	// if message != "" {
-	// 	msg = fmt.Sprintf("%s %s", msg, message)
-	// }
-
 	if pods.Items[0].Status.Phase == corev1.PodFailed {
+		if tf.Status.Stage.State != tfv1alpha2.StateFailed {
+			r.recordTaskFailure(tf)
+		}
 		tf.Status.Stage.State = tfv1alpha2.StateFailed
 		tf.Status.Stage.StopTime = metav1.NewTime(time.Now())
 		err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
@@ -782,6 +1070,25 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 	}
 
 	if pods.Items[0].Status.Phase == corev1.PodSucceeded {
+		if (podType == tfv1alpha2.RunInit || podType == tfv1alpha2.RunInitDelete) && tf.Status.Stage.State != tfv1alpha2.StateComplete {
+			r.recordPluginCacheUsage(ctx, tf, &pods.Items[0])
+		}
+		if (podType == tfv1alpha2.RunPlan || podType == tfv1alpha2.RunPlanDelete) && tf.Status.Stage.State != tfv1alpha2.StateComplete {
+			r.setPlanResults(ctx, tf, &pods.Items[0], runOpts)
+		}
+		if tf.Spec.ExportRepo != nil && podType == tfv1alpha2.RunPlan && tf.Status.Stage.State != tfv1alpha2.StateComplete {
+			commit, err := r.exportRepo(ctx, tf, &pods.Items[0], runOpts)
+			if err != nil {
+				r.Recorder.Event(tf, "Warning", "ExportRepoError", fmt.Sprintf("Could not export to '%s': %s", tf.Spec.ExportRepo.Address, err))
+				if tf.Spec.ExportRepo.RetryOnFailure {
+					return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
+				}
+			} else {
+				r.Recorder.Event(tf, "Normal", "Exported", fmt.Sprintf("Exported generation %d to '%s' at %s", tf.Generation, tf.Spec.ExportRepo.Address, commit))
+				tf.Status.ExportedGeneration = tf.Generation
+				tf.Status.ExportedCommit = commit
+			}
+		}
 		tf.Status.Stage.State = tfv1alpha2.StateComplete
 		tf.Status.Stage.StopTime = metav1.NewTime(time.Now())
 		err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
@@ -798,6 +1105,43 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 		return reconcile.Result{}, nil
 	}
 
+	requeueAfter := time.Duration(0)
+	if pods.Items[0].Status.Phase == corev1.PodPending && tf.Status.Stage.State != tfv1alpha2.StateFailed {
+		pendingTimeout := r.TaskPendingTimeout
+		if runOpts.pendingTimeout != nil {
+			pendingTimeout = *runOpts.pendingTimeout
+		}
+		if pendingTimeout > 0 {
+			pendingFor := time.Since(pods.Items[0].CreationTimestamp.Time)
+			if pendingFor < pendingTimeout {
+				// Pod status changes do not occur when a pod is stuck, check back when the timeout is up
+				requeueAfter = pendingTimeout - pendingFor
+			} else {
+				msg := fmt.Sprintf("Pod '%s' has been pending for more than %s", podName, pendingTimeout)
+				if cause := getPodPendingCause(&pods.Items[0]); cause != "" {
+					msg = fmt.Sprintf("%s: %s", msg, cause)
+				}
+				reqLogger.Info(msg)
+				r.Recorder.Event(tf, "Warning", "TaskPendingTimeout", msg)
+				tf.Status.Stage.State = tfv1alpha2.StateFailed
+				tf.Status.Stage.Reason = "TASK_PENDING_TIMEOUT"
+				tf.Status.Stage.Message = msg
+				tf.Status.Stage.StopTime = metav1.NewTime(time.Now())
+				err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
+				if err != nil {
+					reqLogger.V(1).Info(err.Error())
+					return reconcile.Result{}, err
+				}
+				// The pod must not be allowed to start once the stage has been failed.
+				err := r.Client.Delete(ctx, &pods.Items[0])
+				if err != nil && !errors.IsNotFound(err) {
+					reqLogger.V(1).Info(err.Error())
+				}
+				return reconcile.Result{}, nil
+			}
+		}
+	}
+
 	// Finally, update any statuses that have been changed if not already saved. This is probablye
 	// for pending condition that does not require anything to be done.
 	err = r.updateStatusWithRetry(ctx, tf, &tf.Status, reqLogger)
@@ -805,6 +1149,9 @@ func (r *ReconcileTerraform) Reconcile(ctx context.Context, request reconcile.Re
 		reqLogger.V(1).Info(err.Error())
 		return reconcile.Result{}, err
 	}
+	if requeueAfter > 0 {
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
 
 	// TODO should tf operator "auto" reconciliate (eg plan+apply)?
 	// TODO how should we handle manually triggering apply
@@ -831,10 +1178,51 @@ func (r ReconcileTerraform) getTerraformResource(ctx context.Context, namespaced
 	return tf, nil
 }
 
+// getPodPendingCause looks through the pod's status to find out why the pod has not left the pending
+// phase. The waiting reason of the task container (eg ImagePullBackOff or CreateContainerConfigError when
+// a secret is missing) is preferred over the scheduler's message.
+func getPodPendingCause(pod *corev1.Pod) string {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if waiting := containerStatus.State.Waiting; waiting != nil && waiting.Reason != "" {
+			if waiting.Message != "" {
+				return fmt.Sprintf("%s: %s", waiting.Reason, waiting.Message)
+			}
+			return waiting.Reason
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			if condition.Message != "" {
+				return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+			}
+			return condition.Reason
+		}
+	}
+	if pod.Status.Reason != "" {
+		return fmt.Sprintf("%s: %s", pod.Status.Reason, pod.Status.Message)
+	}
+	return ""
+}
+
 func newStage(tf *tfv1alpha2.Terraform, taskType tfv1alpha2.TaskName, reason string, interruptible tfv1alpha2.Interruptible, stageState tfv1alpha2.StageState) *tfv1alpha2.Stage {
-	if reason == "GENERATION_CHANGE" {
+	if reason == "GENERATION_CHANGE" || reason == "INPUTS_CHANGED" || reason == "SOURCE_CHANGED" || reason == "GIT_PUSH" {
 		tf.Status.Plugins = []tfv1alpha2.TaskName{}
 		tf.Status.Phase = tfv1alpha2.PhaseInitializing
+		// The results are of the last completed workflow, which the new workflow replaces
+		tf.Status.Result = ""
+		tf.Status.PlanResults = nil
+	}
+	if taskType == tfv1alpha2.RunApply && runMode(tf) == tfv1alpha2.RunModeApply {
+		// Even a failed apply may have created resources the delete workflow has to destroy
+		tf.Status.Applied = true
+	}
+	if reason == "GENERATION_CHANGE" || reason == "GIT_PUSH" {
+		// The git source is resolved again for the new generation or push
+		tf.Status.ResolvedCommit = ""
+	}
+	if reason == "GENERATION_CHANGE" {
+		tf.Status.ResolvedModuleVersion = ""
+		tf.Status.ResolvedEngineVersion = ""
 	}
 	startTime := metav1.NewTime(time.Now())
 	stopTime := metav1.NewTime(time.Unix(0, 0))
@@ -852,7 +1240,9 @@ func newStage(tf *tfv1alpha2.Terraform, taskType tfv1alpha2.TaskName, reason str
 	}
 }
 
-func getConfiguredTasks(taskOptions *[]tfv1alpha2.TaskOption) []tfv1alpha2.TaskName {
+// getConfiguredTasks returns the tasks of the workflows. The tasks with task options run in addition to the
+// terraform tasks and the mode removes the tasks that apply plans it does not apply.
+func getConfiguredTasks(tf *tfv1alpha2.Terraform) []tfv1alpha2.TaskName {
 	tasks := []tfv1alpha2.TaskName{
 		tfv1alpha2.RunSetup,
 		tfv1alpha2.RunInit,
@@ -863,10 +1253,7 @@ func getConfiguredTasks(taskOptions *[]tfv1alpha2.TaskOption) []tfv1alpha2.TaskN
 		tfv1alpha2.RunPlanDelete,
 		tfv1alpha2.RunApplyDelete,
 	}
-	if taskOptions == nil {
-		return tasks
-	}
-	for _, taskOption := range *taskOptions {
+	for _, taskOption := range tf.Spec.TaskOptions {
 		for _, affected := range taskOption.Affects {
 			if affected == "*" {
 				continue
@@ -876,7 +1263,14 @@ func getConfiguredTasks(taskOptions *[]tfv1alpha2.TaskOption) []tfv1alpha2.TaskN
 			}
 		}
 	}
-	return tasks
+	skipped := skippedTasks(runMode(tf), tf.Status.Applied)
+	configured := []tfv1alpha2.TaskName{}
+	for _, task := range tasks {
+		if !tfv1alpha2.ListContainsTask(skipped, task) {
+			configured = append(configured, task)
+		}
+	}
+	return configured
 }
 
 // checkSetNewStage uses the tf resource's `.status.stage` state to find the next stage of the terraform run.
@@ -897,10 +1291,10 @@ func (r ReconcileTerraform) checkSetNewStage(ctx context.Context, tf *tfv1alpha2
 	var isNewStage bool
 	var podType tfv1alpha2.TaskName
 	var reason string
-	configuredTasks := getConfiguredTasks(&tf.Spec.TaskOptions)
+	configuredTasks := getConfiguredTasks(tf)
 
 	deletePhases := []string{
-		string(tfv1alpha2.PhaseDeleted),
+		string(tfv1alpha2.PhaseDeleting),
 		string(tfv1alpha2.PhaseInitDelete),
 		string(tfv1alpha2.PhaseDeleted),
 	}
@@ -936,6 +1330,9 @@ func (r ReconcileTerraform) checkSetNewStage(ctx context.Context, tf *tfv1alpha2
 		reason = "TF_RESOURCE_DELETED"
 		podType = tfv1alpha2.RunSetupDelete
 		interruptible = tfv1alpha2.CanNotBeInterrupt
+		if runMode(tf) != tfv1alpha2.RunModeApply && tf.Status.Applied {
+			r.Recorder.Event(tf, "Normal", "DestroyingAppliedResources", fmt.Sprintf("The resource was applied before it was changed to the %s mode so the delete workflow applies the destroy plan", runMode(tf)))
+		}
 
 	} else if currentStage.State == tfv1alpha2.StateComplete {
 		isNewStage = true
@@ -954,7 +1351,22 @@ func (r ReconcileTerraform) checkSetNewStage(ctx context.Context, tf *tfv1alpha2
 			}
 		}
 	} else if currentStage.State == tfv1alpha2.StateFailed {
-		if currentStage.TaskType == tfv1alpha2.RunApply {
+		if currentStage.Reason == "TASK_PENDING_TIMEOUT" {
+			// The task never started so there is nothing to restart. A new generation is required to
+			// try again.
+			isNewStage = false
+		} else if currentStage.TerminationReason == "Evicted" && currentStage.Interruptible == tfv1alpha2.CanNotBeInterrupt {
+			// An uninterruptible task got evicted (eg node pressure) and may have left the state partially
+			// written. Recover by going back to init which will reload the state from the backend and
+			// then re-plan, which refreshes the state, before anything gets applied again.
+			isNewStage = true
+			reason = "RESTARTED_AFTER_EVICTION"
+			podType = tfv1alpha2.RunInit
+			if tfIsFinalizing {
+				podType = tfv1alpha2.RunInitDelete
+			}
+			interruptible = isTaskInterruptable(podType)
+		} else if currentStage.TaskType == tfv1alpha2.RunApply {
 
 			err := r.Client.Get(ctx, types.NamespacedName{Namespace: tf.Namespace, Name: tf.Status.Stage.PodName}, &corev1.Pod{})
 			if err != nil && errors.IsNotFound(err) {
@@ -977,6 +1389,46 @@ func (r ReconcileTerraform) checkSetNewStage(ctx context.Context, tf *tfv1alpha2
 		}
 
 	}
+
+	workflowIsIdle := (currentStage.State == tfv1alpha2.StateComplete && currentStagePodType == tfv1alpha2.RunNil) || currentStage.State == tfv1alpha2.StateFailed
+	if !isNewStage && tf.Spec.WatchInputs && tfIsNotFinalizing && !isNewGeneration && workflowIsIdle && tf.Status.InputHashes != nil {
+		inputHashes, err := r.getInputHashes(ctx, tf)
+		if err != nil {
+			r.Log.V(1).Info(fmt.Sprintf("Failed to get the hashes of inputs: %s", err))
+		} else if changedInputs := getChangedInputs(tf.Status.InputHashes, inputHashes); len(changedInputs) > 0 {
+			r.Recorder.Event(tf, "Normal", "InputsChanged", fmt.Sprintf("Restarting workflow because inputs changed: %s", strings.Join(changedInputs, ", ")))
+			isNewStage = true
+			reason = "INPUTS_CHANGED"
+			podType = tfv1alpha2.RunSetup
+			interruptible = tfv1alpha2.CanBeInterrupt
+			stageState = tfv1alpha2.StateInitializing
+		}
+	}
+
+	if !isNewStage && tfIsNotFinalizing && !isNewGeneration && workflowIsIdle && tf.Status.ResolvedCommit != "" && r.isGitPollDue(tf) {
+		commit, err := r.resolveModuleCommit(ctx, tf)
+		if err != nil {
+			r.Log.V(1).Info(fmt.Sprintf("Failed to poll the git source: %s", err))
+		} else if commit != "" && commit != tf.Status.ResolvedCommit {
+			r.Recorder.Event(tf, "Normal", "SourceChanged", fmt.Sprintf("Restarting workflow because the git source moved from %s to %s", tf.Status.ResolvedCommit, commit))
+			tf.Status.ResolvedCommit = commit
+			isNewStage = true
+			reason = "SOURCE_CHANGED"
+			podType = tfv1alpha2.RunSetup
+			interruptible = tfv1alpha2.CanBeInterrupt
+			stageState = tfv1alpha2.StateInitializing
+		}
+	}
+
+	if !isNewStage && tfIsNotFinalizing && !isNewGeneration && workflowIsIdle && hasPendingGitPush(tf) {
+		r.Recorder.Event(tf, "Normal", "GitPush", fmt.Sprintf("Restarting workflow because of the push '%s'", tf.Annotations[gitPushAnnotation]))
+		isNewStage = true
+		reason = "GIT_PUSH"
+		podType = tfv1alpha2.RunSetup
+		interruptible = tfv1alpha2.CanBeInterrupt
+		stageState = tfv1alpha2.StateInitializing
+	}
+
 	if !isNewStage {
 		return nil
 	}
@@ -1004,6 +1456,13 @@ func (r ReconcileTerraform) removeOldPlan(tf *tfv1alpha2.Terraform) error {
 			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunInitDelete),
 			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunPostInitDelete),
 		}...)
+	} else if tf.Status.Stage.Reason == "RESTARTED_AFTER_EVICTION" {
+		labelSelectors = append(labelSelectors, []string{
+			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunSetup),
+			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunPreInit),
+			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunSetupDelete),
+			fmt.Sprintf("app.kubernetes.io/instance!=%s", tfv1alpha2.RunPreInitDelete),
+		}...)
 	}
 	labelSelector, err := labels.Parse(strings.Join(labelSelectors, ","))
 	if err != nil {
@@ -1278,6 +1737,9 @@ func (r ReconcileTerraform) reapPlugins(tf *tfv1alpha2.Terraform, attempt int) {
 // is recorded in the controller.
 func (r ReconcileTerraform) createPluginPod(ctx context.Context, logger logr.Logger, tf *tfv1alpha2.Terraform, pluginTaskName tfv1alpha2.TaskName, pluginConfig tfv1alpha2.Plugin, globalEnvFrom []corev1.EnvFromSource) (reconcile.Result, error) {
 	pluginRunOpts := newTaskOptions(tf, pluginTaskName, tf.Generation, globalEnvFrom)
+	pluginRunOpts.trustedCA = r.usesTrustedCA(tf)
+	pluginRunOpts.cliConfig = r.usesCLIConfig(tf)
+	pluginRunOpts.clusterPluginCache = r.PluginCacheVolume
 	pluginRunOpts.image = pluginConfig.Image
 	pluginRunOpts.imagePullPolicy = pluginConfig.ImagePullPolicy
 
@@ -1445,9 +1907,33 @@ func formatJobSSHConfig(ctx context.Context, reqLogger logr.Logger, tf *tfv1alph
 		}
 		data["proxy_key"] = key
 
+		if len(getTunnelHosts(tf)) > 0 || usesTunnelSidecar(tf) {
+			// The runner, or the tunnel sidecar, starts this host to serve a SOCKS5 proxy for the https hosts
+			// that require the proxy and to forward the network endpoints
+			data["config"] += fmt.Sprintf("\nHost %s\n"+
+				"\tStrictHostKeyChecking no\n"+
+				"\tUserKnownHostsFile=/dev/null\n"+
+				"\tUser %s\n"+
+				"\tHostname %s\n"+
+				"\tIdentityFile ~/.ssh/proxy_key\n"+
+				"\tDynamicForward %s\n"+
+				"\tExitOnForwardFailure yes\n%s",
+				sshTunnelHost,
+				tf.Spec.SSHTunnel.User,
+				tf.Spec.SSHTunnel.Host,
+				sshTunnelSocksAddress,
+				getTunnelForwards(tf))
+		}
 	}
 
 	for _, m := range tf.Spec.SCMAuthMethods {
+		if m.Git == nil {
+			continue
+		}
+
+		if m.Git.HTTPS != nil && m.Git.HTTPS.RequireProxy && tf.Spec.SSHTunnel == nil {
+			return dataAsByte, fmt.Errorf("%s requires a proxy but spec.sshTunnel is not defined", m.Host)
+		}
 
 		// TODO validate SSH in resource manifest
 		if m.Git.SSH != nil {
@@ -1499,7 +1985,7 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 	var err error
 
 	reason := tf.Status.Stage.Reason
-	isNewGeneration := reason == "GENERATION_CHANGE" || reason == "TF_RESOURCE_DELETED"
+	isNewGeneration := reason == "GENERATION_CHANGE" || reason == "TF_RESOURCE_DELETED" || reason == "INPUTS_CHANGED" || reason == "SOURCE_CHANGED" || reason == "GIT_PUSH"
 	isFirstInstall := reason == "TF_RESOURCE_CREATED"
 	isChanged := isNewGeneration || isFirstInstall
 	// r.Recorder.Event(tf, "Normal", "InitializeJobCreate", fmt.Sprintf("Setting up a Job"))
@@ -1530,10 +2016,51 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 	}
 
 	if tf.Spec.TerraformModule.Source != "" {
-		runOpts.terraformModuleParsed, err = getParsedAddress(tf.Spec.TerraformModule.Source, "", false, scmMap)
+		source := tf.Spec.TerraformModule.Source
+		if module, ok := parseRegistryAddress(source); ok {
+			source, err = r.resolveRegistryModule(ctx, tf, module)
+			if err != nil {
+				r.Recorder.Event(tf, "Warning", "RegistryResolveError", err.Error())
+				return err
+			}
+		}
+		runOpts.terraformModuleParsed, err = getParsedAddress(source, "", false, scmMap)
 		if err != nil {
 			return err
 		}
+		if err := download.ValidateChecksum(tf.Spec.TerraformModule.Checksum); err != nil {
+			r.Recorder.Event(tf, "Warning", "ChecksumError", err.Error())
+			return err
+		}
+		runOpts.terraformModuleParsed.Checksum = tf.Spec.TerraformModule.Checksum
+		if err := validateChecksumSource(runOpts.terraformModuleParsed); err != nil {
+			r.Recorder.Event(tf, "Warning", "ChecksumError", err.Error())
+			return err
+		}
+		if usesDefaultSetupScript(tf, r.RunnerImage) {
+			if err := validateSetupScriptAddress(runOpts.terraformModuleParsed); err != nil {
+				r.Recorder.Event(tf, "Warning", "UnsupportedSource", err.Error())
+				return err
+			}
+		}
+
+		// Pin the ref to a commit so the whole generation uses the same commit
+		if runOpts.terraformModuleParsed.DetectedScheme == "git" {
+			if tf.Status.ResolvedCommit == "" {
+				commit, err := r.resolveGitCommit(ctx, tf, runOpts.terraformModuleParsed)
+				if err != nil {
+					r.Recorder.Event(tf, "Warning", "GitResolveError", fmt.Sprintf("Could not resolve '%s', the setup task will fetch the ref instead: %s", runOpts.terraformModuleParsed.Hash, err))
+				}
+				tf.Status.ResolvedCommit = commit
+			}
+			if tf.Status.ResolvedCommit != "" {
+				runOpts.terraformModuleParsed.Hash = tf.Status.ResolvedCommit
+				runOpts.terraformModuleParsed.Address, err = setAddressRef(runOpts.terraformModuleParsed.Address, tf.Status.ResolvedCommit)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	} else {
 
 		// Make this a legit error, must have a module to run else this is not a tf workflow
@@ -1556,6 +2083,17 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 			}
 		}
 
+		pinnedScripts, err := r.getPinnedScripts(ctx, tf)
+		if err != nil {
+			r.Recorder.Event(tf, "Warning", "ScriptChecksumError", err.Error())
+			return err
+		}
+		for k, v := range pinnedScripts {
+			if _, found := runOpts.mainModulePluginData[k]; !found {
+				runOpts.mainModulePluginData[k] = v
+			}
+		}
+
 		// Set up the HTTPS token to use if defined
 		for _, m := range tf.Spec.SCMAuthMethods {
 			// This loop is used to find the first HTTPS token-based
@@ -1564,7 +2102,7 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 			// TODO
 			//		Is there a way to allow multiple tokens for HTTPS access
 			//		to git scm?
-			if m.Git.HTTPS != nil {
+			if m.Git != nil && m.Git.HTTPS != nil {
 				if _, found := runOpts.secretData["gitAskpass"]; found {
 					continue
 				}
@@ -1581,6 +2119,31 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 			}
 		}
 
+		if err := validateNetwork(tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "NetworkError", err.Error())
+			return err
+		}
+
+		if err := validateEngine(tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "EngineError", err.Error())
+			return err
+		}
+
+		if err := validateWorkspace(tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "WorkspaceError", err.Error())
+			return err
+		}
+
+		if err := validateTerragrunt(tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "TerragruntError", err.Error())
+			return err
+		}
+
+		if err := r.validatePluginCache(tf); err != nil {
+			r.Recorder.Event(tf, "Warning", "PluginCacheError", err.Error())
+			return err
+		}
+
 		// Set up the SSH keys to use if defined
 		sshConfigData, err := formatJobSSHConfig(ctx, reqLogger, tf, r.Client)
 		if err != nil {
@@ -1591,6 +2154,76 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 			runOpts.secretData[k] = v
 		}
 
+		caBundle, err := r.getCABundle(ctx, tf)
+		if err != nil {
+			r.Recorder.Event(tf, "Warning", "TrustedCAError", err.Error())
+			return err
+		}
+		if len(caBundle) > 0 {
+			runOpts.secretData[caBundleKey] = caBundle
+		}
+
+		cliConfig, cliConfigTokenEnvs, err := r.renderCLIConfig(ctx, tf)
+		if err != nil {
+			r.Recorder.Event(tf, "Warning", "CLIConfigError", err.Error())
+			return err
+		}
+		if cliConfig != nil {
+			runOpts.secretData[cliConfigKey] = cliConfig
+		}
+		for k, v := range cliConfigTokenEnvs {
+			runOpts.variablesSecretData[k] = v
+		}
+
+		// The outputs of dependencies are overridden by the values from Secrets of the same variable
+		secretVariables := make(map[string]string)
+		dependencyOutputs, err := r.getDependencyOutputs(ctx, tf)
+		if err != nil {
+			r.Recorder.Event(tf, "Warning", "DependencyOutputsError", err.Error())
+			return err
+		}
+		for variable, value := range dependencyOutputs {
+			if !variableNamePattern.MatchString(variable) {
+				err := fmt.Errorf("'%s' is not a valid variable name", variable)
+				r.Recorder.Event(tf, "Warning", "DependencyOutputsError", err.Error())
+				return err
+			}
+			secretVariables[variable] = formatOutputVariable(variable, value)
+		}
+
+		// Record the inputs used by this run so changes can be detected
+		inputHashes, err := r.getInputHashes(ctx, tf)
+		if err != nil {
+			return err
+		}
+		tf.Status.InputHashes = inputHashes
+
+		// The run includes any push received before it started
+		tf.Status.LastGitPush = tf.Annotations[gitPushAnnotation]
+
+		variableFiles, variableSecrets, err := r.renderVariables(ctx, tf)
+		if err != nil {
+			r.Recorder.Event(tf, "Warning", "VariablesError", err.Error())
+			return err
+		}
+		for k, v := range variableFiles {
+			runOpts.mainModulePluginData[k] = v
+		}
+		for k, v := range variableSecrets {
+			secretVariables[k] = v
+		}
+		if len(secretVariables) > 0 {
+			runOpts.secretData[secretVariablesKey] = renderSecretVariables(secretVariables)
+		}
+
+		registryTokenEnvs, err := r.getRegistryTokenEnvs(ctx, tf)
+		if err != nil {
+			return err
+		}
+		for k, v := range registryTokenEnvs {
+			runOpts.variablesSecretData[k] = v
+		}
+
 		resourceDownloadItems := []ParsedAddress{}
 		// Configure the resourceDownloads in JSON that the setupRunner will
 		// use to download the resources into the main module directory
@@ -1603,6 +2236,21 @@ func (r *ReconcileTerraform) setupAndRun(ctx context.Context, tf *tfv1alpha2.Ter
 				if err != nil {
 					return err
 				}
+				if err := download.ValidateChecksum(s.Checksum); err != nil {
+					r.Recorder.Event(tf, "Warning", "ChecksumError", fmt.Sprintf("%s: %v", address, err))
+					return err
+				}
+				parsedAddress.Checksum = s.Checksum
+				if err := validateChecksumSource(parsedAddress); err != nil {
+					r.Recorder.Event(tf, "Warning", "ChecksumError", err.Error())
+					return err
+				}
+				if usesDefaultSetupScript(tf, r.RunnerImage) {
+					if err := validateSetupScriptAddress(parsedAddress); err != nil {
+						r.Recorder.Event(tf, "Warning", "UnsupportedSource", err.Error())
+						return err
+					}
+				}
 				// b, err := json.Marshal(parsedAddress)
 				// if err != nil {
 				// 	return err
@@ -1728,6 +2376,43 @@ func (r ReconcileTerraform) createPVC(ctx context.Context, tf *tfv1alpha2.Terraf
 	return nil
 }
 
+func (r ReconcileTerraform) checkPodDisruptionBudgetExists(ctx context.Context, lookupKey types.NamespacedName) (*policyv1beta1.PodDisruptionBudget, bool, error) {
+	resource := &policyv1beta1.PodDisruptionBudget{}
+
+	err := r.Client.Get(ctx, lookupKey, resource)
+	if err != nil && errors.IsNotFound(err) {
+		return resource, false, nil
+	} else if err != nil {
+		return resource, false, err
+	}
+	return resource, true, nil
+}
+
+// createPodDisruptionBudget creates a single budget for the lifetime of the resource that prevents
+// uninterruptible task pods from being evicted.
+func (r ReconcileTerraform) createPodDisruptionBudget(ctx context.Context, tf *tfv1alpha2.Terraform, runOpts TaskOptions) error {
+	kind := "PodDisruptionBudget"
+	_, found, err := r.checkPodDisruptionBudgetExists(ctx, types.NamespacedName{
+		Name:      runOpts.prefixedName,
+		Namespace: runOpts.namespace,
+	})
+	if err != nil {
+		return err
+	} else if found {
+		return nil
+	}
+	resource := runOpts.generatePodDisruptionBudget()
+	controllerutil.SetControllerReference(tf, resource, r.Scheme)
+
+	err = r.Client.Create(ctx, resource)
+	if err != nil {
+		r.Recorder.Event(tf, "Warning", fmt.Sprintf("%sCreateError", kind), fmt.Sprintf("Could not create %s %v", kind, err))
+		return err
+	}
+	r.Recorder.Event(tf, "Normal", "SuccessfulCreate", fmt.Sprintf("Created %s: '%s'", kind, resource.Name))
+	return nil
+}
+
 func (r ReconcileTerraform) checkConfigMapExists(ctx context.Context, lookupKey types.NamespacedName) (*corev1.ConfigMap, bool, error) {
 	resource := &corev1.ConfigMap{}
 
@@ -2085,6 +2770,16 @@ func (r TaskOptions) generateRole() *rbacv1.Role {
 		}
 	}
 
+	if r.saveOutputs {
+		// The default terraform script writes the outputs to the outputs secret
+		rules = append(rules, rbacv1.PolicyRule{
+			Verbs:         []string{"get", "patch"},
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{r.outputsSecretName},
+		})
+	}
+
 	rules = append(rules, r.policyRules...)
 
 	role := &rbacv1.Role{
@@ -2141,6 +2836,36 @@ func (r TaskOptions) generatePVC(size resource.Quantity) *corev1.PersistentVolum
 	}
 }
 
+func (r TaskOptions) generatePodDisruptionBudget() *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(0)
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.prefixedName,
+			Namespace: r.namespace,
+			Labels: map[string]string{
+				"terraforms.tf.isaaguilar.com/resourceName": r.resourceName,
+				"terraforms.tf.isaaguilar.com/podPrefix":    r.prefixedName,
+				"app.kubernetes.io/name":                    "terraform-operator",
+				"app.kubernetes.io/created-by":              "controller",
+			},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"terraforms.tf.isaaguilar.com/podPrefix":       r.prefixedName,
+					"terraforms.tf.isaaguilar.com/uninterruptible": "true",
+				},
+			},
+		},
+	}
+}
+
+// mainModulePath is where the setup task puts the main module of the generation
+func (r TaskOptions) mainModulePath() string {
+	return fmt.Sprintf("/home/tfo-runner/generations/%d/main", r.generation)
+}
+
 // generatePod puts together all the contents required to execute the taskType.
 // Although most of the tasks use similar.... (TODO EDIT ME)
 func (r TaskOptions) generatePod() *corev1.Pod {
@@ -2200,7 +2925,7 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 		},
 		{
 			Name:  "TFO_MAIN_MODULE",
-			Value: generationPath + "/main",
+			Value: r.mainModulePath(),
 		},
 		{
 			Name:  "TFO_TERRAFORM_VERSION",
@@ -2224,6 +2949,16 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 		},
 	}...)
 
+	envs = append(envs, r.engineEnvs()...)
+	envs = append(envs, r.terragruntEnvs()...)
+	envs = append(envs, r.workspaceEnvs()...)
+	envs = append(envs, r.modeEnvs()...)
+
+	envs = append(envs, corev1.EnvVar{
+		Name:  "TFO_ERROR_MARKER_FILE",
+		Value: errorMarkerFile,
+	})
+
 	if r.cleanupDisk {
 		envs = append(envs, corev1.EnvVar{
 			Name:  "TFO_CLEANUP_DISK",
@@ -2305,6 +3040,70 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 		}
 	}
 
+	if r.terraformModuleParsed.Address != "" {
+		envs = append(envs, []corev1.EnvVar{
+			{
+				Name:  "TFO_MAIN_MODULE_ADDRESS",
+				Value: r.terraformModuleParsed.Address,
+			},
+			{
+				Name:  "TFO_MAIN_MODULE_CHECKSUM",
+				Value: r.terraformModuleParsed.Checksum,
+			},
+		}...)
+	}
+
+	if r.trustedCA {
+		volume, mount, trustedCAEnvs := r.trustedCAVolume()
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+		envs = append(envs, trustedCAEnvs...)
+	}
+
+	if r.cliConfig {
+		volume, mount, cliConfigEnv := r.cliConfigVolume()
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+		envs = append(envs, cliConfigEnv)
+	}
+
+	if r.secretVariables {
+		volume, mount, secretVariablesEnv := r.secretVariablesVolume()
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+		envs = append(envs, secretVariablesEnv)
+	}
+
+	if r.pluginCache != nil {
+		volume, mount, pluginCacheEnvs := r.pluginCacheVolume()
+		if volume != nil {
+			volumes = append(volumes, *volume)
+			volumeMounts = append(volumeMounts, mount)
+			envs = append(envs, pluginCacheEnvs...)
+		}
+	}
+
+	envs = append(envs, tunnelEnvs(r.tunnelHosts, r.tunnelImage == "")...)
+	envs = append(envs, getNetworkEnvs(r.network, r.tunnelImage != "")...)
+
+	for i, source := range r.objectSources {
+		volumeName := fmt.Sprintf("tfo-resource-%d", i)
+		volume := corev1.Volume{Name: volumeName}
+		if source.Kind == inputKindSecret {
+			volume.VolumeSource.Secret = &corev1.SecretVolumeSource{SecretName: source.Name}
+		} else {
+			volume.VolumeSource.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: source.Name},
+			}
+		}
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: source.mountPath(),
+			ReadOnly:  true,
+		})
+	}
+
 	configMapSourceVolumeName := "config-map-source"
 	configMapSourcePath := "/tmp/config-map-source"
 	if r.configMapSourceName != "" && r.configMapSourceKey != "" {
@@ -2392,7 +3191,7 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 	sshMountPath := "/tmp/ssh"
 	mode := int32(0775)
 	sshConfigItems := []corev1.KeyToPath{}
-	keysToIgnore := []string{"gitAskpass"}
+	keysToIgnore := []string{"gitAskpass", caBundleKey, cliConfigKey, secretVariablesKey}
 	for key := range r.secretData {
 		if utils.ListContainsStr(keysToIgnore, key) {
 			continue
@@ -2455,6 +3254,13 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 	}
 	runnerLabels["app.kubernetes.io/instance"] = r.task.String()
 
+	if isTaskInterruptable(r.task) == tfv1alpha2.CanNotBeInterrupt {
+		// Keep node drains and the cluster-autoscaler from killing a task that can corrupt the state.
+		// The label is selected by the resource's PodDisruptionBudget.
+		runnerLabels["terraforms.tf.isaaguilar.com/uninterruptible"] = "true"
+		annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"] = "false"
+	}
+
 	// Make sure to use the same uid for containers so the dir in the
 	// PersistentVolume have the correct permissions for the user
 	user := int64(2000)
@@ -2467,15 +3273,40 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 	}
 	restartPolicy := corev1.RestartPolicyNever
 
+	var initContainers []corev1.Container
+	var command []string
+	if r.runnerImage != "" {
+		volume, mount, initContainer := r.runnerInitContainer(securityContext)
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+		initContainers = append(initContainers, initContainer)
+		command = runnerCommand()
+	}
+
+	if r.tunnelImage != "" {
+		volume, mount, env := tunnelStatusVolume()
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, mount)
+		envs = append(envs, env)
+	}
+
 	containers := []corev1.Container{}
+	if r.tunnelImage != "" {
+		// The sidecar goes first so its postStart hook holds the task until the tunnel listens
+		sshMount := corev1.VolumeMount{Name: sshMountName, MountPath: sshMountPath}
+		containers = append(containers, r.tunnelSidecar(securityContext, sshMount))
+	}
 	containers = append(containers, corev1.Container{
-		Name:            "task",
-		SecurityContext: securityContext,
-		Image:           r.image,
-		ImagePullPolicy: r.imagePullPolicy,
-		EnvFrom:         envFrom,
-		Env:             envs,
-		VolumeMounts:    volumeMounts,
+		Name:                     "task",
+		SecurityContext:          securityContext,
+		Image:                    r.image,
+		ImagePullPolicy:          r.imagePullPolicy,
+		Command:                  command,
+		EnvFrom:                  envFrom,
+		Env:                      envs,
+		VolumeMounts:             volumeMounts,
+		TerminationMessagePath:   errorMarkerFile,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
 	})
 
 	podSecurityContext := corev1.PodSecurityContext{
@@ -2490,14 +3321,23 @@ func (r TaskOptions) generatePod() *corev1.Pod {
 			Annotations:  annotations,
 		},
 		Spec: corev1.PodSpec{
-			SecurityContext:    &podSecurityContext,
-			ServiceAccountName: r.serviceAccount,
-			RestartPolicy:      restartPolicy,
-			Containers:         containers,
-			Volumes:            volumes,
+			SecurityContext:       &podSecurityContext,
+			ServiceAccountName:    r.serviceAccount,
+			RestartPolicy:         restartPolicy,
+			ActiveDeadlineSeconds: r.timeout,
+			InitContainers:        initContainers,
+			Containers:            containers,
+			Volumes:               volumes,
 		},
 	}
 
+	if r.tunnelImage != "" {
+		shareProcessNamespace := true
+		pod.Spec.ShareProcessNamespace = &shareProcessNamespace
+		pod.Spec.HostAliases = tunnelHostAliases(r.network)
+		podSecurityContext.Sysctls = tunnelSysctls(r.network)
+	}
+
 	return pod
 }
 
@@ -2512,10 +3352,24 @@ func (r ReconcileTerraform) run(ctx context.Context, reqLogger logr.Logger, tf *
 			return err
 		}
 
+		if err := r.createPluginCachePVC(ctx, tf); err != nil {
+			return err
+		}
+
+		if err := r.createPodDisruptionBudget(ctx, tf, runOpts); err != nil {
+			return err
+		}
+
 		if err := r.createSecret(ctx, tf, runOpts.versionedName, runOpts.namespace, runOpts.secretData, true, []string{}, runOpts); err != nil {
 			return err
 		}
 
+		if len(runOpts.variablesSecretData) > 0 {
+			if err := r.createSecret(ctx, tf, runOpts.variablesSecretName, runOpts.namespace, runOpts.variablesSecretData, true, []string{}, runOpts); err != nil {
+				return err
+			}
+		}
+
 		if err := r.createConfigMap(ctx, tf, runOpts); err != nil {
 			return err
 		}
@@ -2597,6 +3451,18 @@ func (r ReconcileTerraform) run(ctx context.Context, reqLogger logr.Logger, tf *
 
 	}
 
+	if err := r.createTaskScriptsConfigMap(ctx, tf); err != nil {
+		return err
+	}
+
+	if runOpts.pluginCache != nil && (runOpts.task == tfv1alpha2.RunInit || runOpts.task == tfv1alpha2.RunInitDelete) {
+		keepSince, err := r.getPluginCacheKeepSince(ctx, tf)
+		if err != nil {
+			return err
+		}
+		runOpts.pluginCacheKeepSince = keepSince
+	}
+
 	if err := r.createPod(ctx, tf, runOpts); err != nil {
 		return err
 	}
@@ -2727,6 +3593,11 @@ type scmType string
 var gitScmType scmType = "git"
 
 func getParsedAddress(address, path string, useAsVar bool, scmMap map[string]scmType) (ParsedAddress, error) {
+	if source, ok := parseObjectSource(address); ok {
+		return source.parsedAddress(path, useAsVar), nil
+	}
+	address = download.NormalizeAddress(address)
+
 	detectors := []getter.Detector{
 		new(sshDetector),
 	}
@@ -2776,10 +3647,8 @@ func getParsedAddress(address, path string, useAsVar bool, scmMap map[string]scm
 	if err != nil {
 		return ParsedAddress{}, err
 	}
+	// An empty ref is the default branch of the repo
 	hash := y.Get("ref")
-	if hash == "" {
-		hash = "master"
-	}
 
 	// subdir can contain a list seperated by double slashes
 	files := strings.Split(filesSource, "//")
@@ -2810,6 +3679,10 @@ func getParsedAddress(address, path string, useAsVar bool, scmMap map[string]scm
 		Port:           port,
 		User:           parsedURL.User.Username(),
 		Repo:           strings.Split(parsedURL.String(), "?")[0],
+		Address:        output,
+	}
+	if scheme == "git" && forcedDetect == "" {
+		p.Address = "git::" + output
 	}
 	return p, nil
 }
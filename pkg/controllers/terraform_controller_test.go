@@ -24,11 +24,14 @@ import (
 	"time"
 
 	tfv1alpha1 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha1"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Terraform controller", func() {
@@ -110,6 +113,85 @@ var _ = Describe("Terraform controller", func() {
 
 		})
 	})
+
+	Context("When Creating Terraform with an ssh tunnel", func() {
+		It("Should configure the tasks to reach the hosts that require the tunnel", func() {
+			ctx := context.Background()
+			const name = "test-tfo-tunnel"
+
+			By("By creating the secrets of the tunnel and the scm auth")
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bastion", Namespace: TerraformNamespace},
+				Data:       map[string][]byte{"id_rsa": []byte("proxy key")},
+			})).Should(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ghe-token", Namespace: TerraformNamespace},
+				Data:       map[string][]byte{"token": []byte("t0ken")},
+			})).Should(Succeed())
+
+			By("By creating a new Terraform whose module is only reachable through the tunnel")
+			terraform := tfv1alpha2.Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: TerraformNamespace,
+				},
+				Spec: tfv1alpha2.TerraformSpec{
+					TerraformModule: tfv1alpha2.Module{Source: "https://ghe.internal.example.com/org/vpc.git?ref=main"},
+					SCMAuthMethods: []tfv1alpha2.SCMAuthMethod{
+						{
+							Host: "ghe.internal.example.com",
+							Git: &tfv1alpha2.GitSCM{
+								HTTPS: &tfv1alpha2.GitHTTPS{RequireProxy: true, TokenSecretRef: &tfv1alpha2.TokenSecretRef{Name: "ghe-token"}},
+							},
+						},
+					},
+					SSHTunnel: &tfv1alpha2.ProxyOpts{Host: "bastion.example.com", User: "tunnel", SSHKeySecretRef: tfv1alpha2.SSHKeySecretRef{Name: "bastion"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &terraform)).Should(Succeed())
+
+			terraformLookupKey := types.NamespacedName{Name: name, Namespace: TerraformNamespace}
+			createdTerraform := &tfv1alpha2.Terraform{}
+			Eventually(func() (string, error) {
+				err := k8sClient.Get(ctx, terraformLookupKey, createdTerraform)
+				return createdTerraform.Status.PodNamePrefix, err
+			}, timeout, interval).ShouldNot(BeEmpty())
+
+			By("By checking the ssh config of the tunnel is in the versioned secret")
+			versionedName := fmt.Sprintf("%s-v%d", createdTerraform.Status.PodNamePrefix, createdTerraform.Generation)
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: versionedName, Namespace: TerraformNamespace}, secret)
+			}, timeout, interval).Should(Succeed())
+			Expect(string(secret.Data["config"])).Should(ContainSubstring("Host proxy-tunnel\n"))
+			Expect(string(secret.Data["config"])).Should(ContainSubstring("\tHostname bastion.example.com\n"))
+			Expect(string(secret.Data["config"])).Should(ContainSubstring("\tDynamicForward 127.0.0.1:1080\n"))
+			Expect(string(secret.Data["proxy_key"])).Should(Equal("proxy key"))
+
+			By("By checking the setup task gets the tunnel envs")
+			envs := map[string]string{}
+			Eventually(func() bool {
+				pods := &corev1.PodList{}
+				err := k8sClient.List(ctx, pods, client.InNamespace(TerraformNamespace), client.MatchingLabels{
+					"terraforms.tf.isaaguilar.com/resourceName": name,
+					"app.kubernetes.io/instance":                tfv1alpha2.RunSetup.String(),
+				})
+				if err != nil || len(pods.Items) == 0 {
+					return false
+				}
+				for _, container := range pods.Items[0].Spec.Containers {
+					if container.Name == "task" {
+						envs = podEnvs(container)
+					}
+				}
+				return true
+			}, timeout, interval).Should(BeTrue())
+			Expect(envs).Should(HaveKeyWithValue("TFO_SSH_TUNNEL_SOCKS_ADDRESS", "127.0.0.1:1080"))
+			Expect(envs).Should(HaveKeyWithValue("TFO_SSH_TUNNEL_HOSTS", "ghe.internal.example.com"))
+			Expect(envs).Should(HaveKeyWithValue("GIT_CONFIG_KEY_0", "http.https://ghe.internal.example.com/.proxy"))
+			Expect(envs).Should(HaveKeyWithValue("GIT_CONFIG_VALUE_0", "socks5h://127.0.0.1:1080"))
+		})
+	})
 })
 
 func TestGetParsedAddress(t *testing.T) {
@@ -268,3 +350,37 @@ func TestGetParsedAddress(t *testing.T) {
 
 	// fmt.Printf("%+v", parsed)
 }
+
+func TestGetPodPendingCause(t *testing.T) {
+	pod := corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{
+				{
+					Type:    corev1.PodScheduled,
+					Status:  corev1.ConditionFalse,
+					Reason:  "Unschedulable",
+					Message: "0/3 nodes are available: 3 Insufficient cpu.",
+				},
+			},
+		},
+	}
+	if got, want := getPodPendingCause(&pod), "Unschedulable: 0/3 nodes are available: 3 Insufficient cpu."; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: "task",
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: `Back-off pulling image "ghcr.io/galleybytes/terraform-operator-tftaskv1:0.0.0"`,
+				},
+			},
+		},
+	}
+	if got, want := getPodPendingCause(&pod), `ImagePullBackOff: Back-off pulling image "ghcr.io/galleybytes/terraform-operator-tftaskv1:0.0.0"`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
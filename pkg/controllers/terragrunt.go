@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// planSummaryPattern finds the summary terraform prints at the end of a plan
+	planSummaryPattern = regexp.MustCompile(`Plan: (?:\d+ to import, )?(\d+) to add, (\d+) to change, (\d+) to destroy\.|No changes\.`)
+
+	// modulePrefixPattern finds the `[<module>]` terragrunt prefixes the output of the modules with
+	modulePrefixPattern = regexp.MustCompile(`\[([^\[\]\s]+)\]`)
+)
+
+// validateTerragrunt checks the terragrunt options that can not be checked by the CRD schema
+func validateTerragrunt(tf *tfv1alpha2.Terraform) error {
+	terragrunt := tf.Spec.Terragrunt
+	if terragrunt == nil {
+		return nil
+	}
+	for name, p := range map[string]string{"dir": terragrunt.Dir, "configPath": terragrunt.ConfigPath} {
+		if p == "" {
+			continue
+		}
+		if clean := path.Clean(p); path.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("terragrunt %s '%s' must be a relative path in the main module", name, p)
+		}
+	}
+	if terragrunt.RunAll && terragrunt.ConfigPath != "" {
+		return fmt.Errorf("terragrunt configPath can not be used with runAll")
+	}
+	return nil
+}
+
+// terragruntDir is the directory terragrunt runs in relative to the main module
+func terragruntDir(terragrunt *tfv1alpha2.Terragrunt) string {
+	if terragrunt.Dir == "" {
+		return "."
+	}
+	return path.Clean(terragrunt.Dir)
+}
+
+// terragruntEnvs tells the terraform tasks to run terragrunt
+func (r TaskOptions) terragruntEnvs() []corev1.EnvVar {
+	if r.terragrunt == nil {
+		return nil
+	}
+	envs := []corev1.EnvVar{
+		{
+			Name:  "TFO_TERRAGRUNT",
+			Value: "true",
+		},
+		{
+			Name:  "TFO_TERRAGRUNT_DIR",
+			Value: terragruntDir(r.terragrunt),
+		},
+		{
+			Name:  "TFO_TERRAGRUNT_RUN_ALL",
+			Value: strconv.FormatBool(r.terragrunt.RunAll),
+		},
+	}
+	if r.terragrunt.Version != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "TFO_TERRAGRUNT_VERSION",
+			Value: r.terragrunt.Version,
+		})
+	}
+	if r.terragrunt.ConfigPath != "" {
+		envs = append(envs, corev1.EnvVar{
+			Name:  "TFO_TERRAGRUNT_CONFIG",
+			Value: r.terragrunt.ConfigPath,
+		})
+	}
+	return envs
+}
+
+// parsePlanResults finds the plan summary of each module in the log of a plan. The output of `run-all` is
+// prefixed with the path of the module, which is made relative to root, the directory terragrunt ran in.
+// Summaries without a prefix belong to the directory itself.
+func parsePlanResults(log, root string) []tfv1alpha2.PlanResult {
+	log = ansiEscapePattern.ReplaceAllString(log, "")
+	results := []tfv1alpha2.PlanResult{}
+	index := map[string]int{}
+	for _, line := range strings.Split(log, "\n") {
+		match := planSummaryPattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		module := "."
+		if prefixes := modulePrefixPattern.FindAllStringSubmatch(line[:match[0]], -1); len(prefixes) > 0 {
+			module = planResultModule(prefixes[len(prefixes)-1][1], root)
+		}
+		result := tfv1alpha2.PlanResult{Module: module}
+		if match[2] >= 0 {
+			result.Add, _ = strconv.Atoi(line[match[2]:match[3]])
+			result.Change, _ = strconv.Atoi(line[match[4]:match[5]])
+			result.Destroy, _ = strconv.Atoi(line[match[6]:match[7]])
+		}
+		if i, ok := index[module]; ok {
+			results[i] = result
+			continue
+		}
+		index[module] = len(results)
+		results = append(results, result)
+	}
+	return results
+}
+
+// planResultModule cleans the prefix of a module's output, which is the path of the module or of its copy
+// in the terragrunt cache
+func planResultModule(module, root string) string {
+	if i := strings.Index(module, "/.terragrunt-cache/"); i >= 0 {
+		module = module[:i]
+	}
+	module = path.Clean(module)
+	if module == root {
+		return "."
+	}
+	return strings.TrimPrefix(module, root+"/")
+}
+
+// setPlanResults copies the plan results of the plan task into the status of terragrunt resources and of
+// resources that do not apply their plans. Logs are skipped when the controller is not configured with a
+// clientset.
+func (r ReconcileTerraform) setPlanResults(ctx context.Context, tf *tfv1alpha2.Terraform, pod *corev1.Pod, runOpts TaskOptions) {
+	if r.Clientset == nil || (tf.Spec.Terragrunt == nil && runMode(tf) == tfv1alpha2.RunModeApply) {
+		return
+	}
+	log, err := r.scanTaskLog(ctx, pod, planSummaryPattern)
+	if err != nil {
+		r.Log.V(1).Info(fmt.Sprintf("Could not get logs of pod '%s': %s", pod.Name, err))
+		return
+	}
+	root := runOpts.mainModulePath()
+	if tf.Spec.Terragrunt != nil {
+		root = path.Join(root, terragruntDir(tf.Spec.Terragrunt))
+	}
+	tf.Status.PlanResults = parsePlanResults(log, root)
+}
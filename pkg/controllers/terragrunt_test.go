@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+func TestValidateTerragrunt(t *testing.T) {
+	tests := []struct {
+		terragrunt tfv1alpha2.Terragrunt
+		wantErr    bool
+	}{
+		{terragrunt: tfv1alpha2.Terragrunt{Version: "v0.55.1", Dir: "live/prod/", RunAll: true}},
+		{terragrunt: tfv1alpha2.Terragrunt{Dir: "/live"}, wantErr: true},
+		{terragrunt: tfv1alpha2.Terragrunt{Dir: "live/../.."}, wantErr: true},
+		{terragrunt: tfv1alpha2.Terragrunt{ConfigPath: "../terragrunt.hcl"}, wantErr: true},
+		{terragrunt: tfv1alpha2.Terragrunt{RunAll: true, ConfigPath: "prod.hcl"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		terragrunt := tt.terragrunt
+		tf := newTestTerraform(tfv1alpha2.TerraformSpec{Terragrunt: &terragrunt})
+		if err := validateTerragrunt(tf); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: expected error %v, got %v", tt.terragrunt, tt.wantErr, err)
+		}
+	}
+}
+
+func TestTerragruntTasks(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.5.7"})
+	if got := tasksWithEnv(tf, "TFO_TERRAGRUNT"); len(got) != 0 {
+		t.Errorf("expected resources without terragrunt to run the engine, got terragrunt in %v", got)
+	}
+
+	tf.Spec.Terragrunt = &tfv1alpha2.Terragrunt{Version: "v0.55.1", Dir: "live/prod/", RunAll: true}
+	if got := tasksWithEnv(tf, "TFO_TERRAGRUNT"); !reflect.DeepEqual(got, terraformTaskNames) {
+		t.Errorf("expected only the terraform tasks to run terragrunt, got %v", got)
+	}
+	// The directory is cleaned so the plan summaries can be attributed to the modules under it
+	if dir := taskEnvs(tf, tfv1alpha2.RunPlan)["TFO_TERRAGRUNT_DIR"]; dir != "live/prod" {
+		t.Errorf("expected the cleaned directory, got %s", dir)
+	}
+}
+
+func TestParseTerragruntPlanResults(t *testing.T) {
+	root := "/home/tfo-runner/generations/1/main/live/prod"
+	tests := []struct {
+		name string
+		log  string
+		want []tfv1alpha2.PlanResult
+	}{
+		{
+			name: "run-all",
+			log: "\x1b[1mInitializing\x1b[0m\n" +
+				"[vpc] \x1b[1mPlan:\x1b[0m 2 to add, 0 to change, 0 to destroy.\n" +
+				"12:00:00.000 STDOUT [" + root + "/app/.terragrunt-cache/abc/def] terraform: Plan: 1 to import, 0 to add, 3 to change, 1 to destroy.\n" +
+				"[" + root + "/dns] No changes. Your infrastructure matches the configuration.\n" +
+				"[vpc] Plan: 1 to add, 0 to change, 0 to destroy.\n",
+			want: []tfv1alpha2.PlanResult{
+				{Module: "vpc", Add: 1},
+				{Module: "app", Change: 3, Destroy: 1},
+				{Module: "dns"},
+			},
+		},
+		{
+			name: "directory",
+			log:  "Plan: 0 to add, 1 to change, 0 to destroy.\n",
+			want: []tfv1alpha2.PlanResult{{Module: ".", Change: 1}},
+		},
+	}
+	for _, tt := range tests {
+		if got := parsePlanResults(tt.log, root); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected the plan results %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
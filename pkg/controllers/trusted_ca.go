@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// caBundleKey is the key of the CA bundle in the versioned secret
+	caBundleKey = "ca-bundle.crt"
+
+	// caBundlePath is where the CA bundle is mounted in the task pods
+	caBundlePath = "/tmp/tfo-ca"
+)
+
+// systemCAFiles are the locations of the system roots of common distros. The controller's system roots
+// are added to the bundle given to the tasks because SSL_CERT_FILE and GIT_SSL_CAINFO replace the roots
+// of the task image.
+var systemCAFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// usesTrustedCA returns true when the tasks get a CA bundle
+func (r ReconcileTerraform) usesTrustedCA(tf *tfv1alpha2.Terraform) bool {
+	return len(r.TrustedCA) > 0 || (tf.Spec.TrustedCA != nil && (tf.Spec.TrustedCA.ConfigMapKeyRef != nil || tf.Spec.TrustedCA.SecretKeyRef != nil))
+}
+
+// getTrustedCA returns the controller-wide CA bundle and the resource's CA certificates
+func (r ReconcileTerraform) getTrustedCA(ctx context.Context, tf *tfv1alpha2.Terraform) ([]byte, error) {
+	bundle := []string{}
+	if len(r.TrustedCA) > 0 {
+		bundle = append(bundle, string(r.TrustedCA))
+	}
+	if trustedCA := tf.Spec.TrustedCA; trustedCA != nil {
+		if trustedCA.ConfigMapKeyRef != nil {
+			value, found, err := r.getConfigMapKeyValue(ctx, tf.Namespace, *trustedCA.ConfigMapKeyRef)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				bundle = append(bundle, value)
+			}
+		}
+		if trustedCA.SecretKeyRef != nil {
+			value, found, err := r.getSecretKeyValue(ctx, tf.Namespace, *trustedCA.SecretKeyRef)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				bundle = append(bundle, value)
+			}
+		}
+	}
+	if len(bundle) == 0 {
+		return nil, nil
+	}
+	pem := []byte(strings.Join(bundle, "\n"))
+	if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM encoded certificates were found in the trusted CA")
+	}
+	return pem, nil
+}
+
+// getCABundle returns the bundle mounted into the tasks, ie the system roots and the trusted CAs
+func (r ReconcileTerraform) getCABundle(ctx context.Context, tf *tfv1alpha2.Terraform) ([]byte, error) {
+	trustedCA, err := r.getTrustedCA(ctx, tf)
+	if err != nil || len(trustedCA) == 0 {
+		return nil, err
+	}
+	for _, filename := range systemCAFiles {
+		systemCA, err := ioutil.ReadFile(filename)
+		if err == nil && len(systemCA) > 0 {
+			return append(append(systemCA, '\n'), trustedCA...), nil
+		}
+	}
+	r.Log.Info("System roots were not found, the tasks will only trust the trusted CA")
+	return trustedCA, nil
+}
+
+// getHTTPClient returns the client used to talk to module registries and to fetch pinned task scripts,
+// which trusts the trusted CAs
+func (r ReconcileTerraform) getHTTPClient(ctx context.Context, tf *tfv1alpha2.Terraform) (*http.Client, error) {
+	trustedCA, err := r.getTrustedCA(ctx, tf)
+	if err != nil || len(trustedCA) == 0 {
+		return registryHTTPClient, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	pool.AppendCertsFromPEM(trustedCA)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Timeout: registryHTTPClient.Timeout, Transport: transport}, nil
+}
+
+// trustedCAVolume mounts the CA bundle of the versioned secret and points the tools to it
+func (r TaskOptions) trustedCAVolume() (corev1.Volume, corev1.VolumeMount, []corev1.EnvVar) {
+	volume := corev1.Volume{
+		Name: "trusted-ca",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: r.versionedName,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  caBundleKey,
+						Path: caBundleKey,
+					},
+				},
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      "trusted-ca",
+		MountPath: caBundlePath,
+		ReadOnly:  true,
+	}
+	bundle := caBundlePath + "/" + caBundleKey
+	envs := []corev1.EnvVar{
+		{
+			Name:  "SSL_CERT_FILE",
+			Value: bundle,
+		},
+		{
+			Name:  "GIT_SSL_CAINFO",
+			Value: bundle,
+		},
+	}
+	return volume, mount, envs
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestTrustedCAReconciler(serverCA string) ReconcileTerraform {
+	return newTestReconciler(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "internal-ca"},
+		Data:       map[string]string{"ca.crt": serverCA},
+	})
+}
+
+func newTestTrustedCA() *tfv1alpha2.TrustedCA {
+	return &tfv1alpha2.TrustedCA{
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "internal-ca"}, Key: "ca.crt"},
+	}
+}
+
+func testCertificate(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestTrustedCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/terraform.json":
+			fmt.Fprint(w, `{"modules.v1": "/v1/modules/"}`)
+		case "/v1/modules/org/vpc/aws/versions":
+			fmt.Fprint(w, `{"modules": [{"versions": [{"version": "1.0.0"}]}]}`)
+		case "/v1/modules/org/vpc/aws/1.0.0/download":
+			w.Header().Set("X-Terraform-Get", "git::https://gitlab.internal.example.com/org/vpc?ref=v1.0.0")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := newTestTrustedCAReconciler(testCertificate(server))
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformModule: tfv1alpha2.Module{Source: server.Listener.Addr().String() + "/org/vpc/aws"},
+	})
+	module, _ := parseRegistryAddress(tf.Spec.TerraformModule.Source)
+
+	if r.usesTrustedCA(tf) {
+		t.Error("expected no trusted CA")
+	}
+	if _, err := r.resolveRegistryModule(context.TODO(), tf, module); err == nil {
+		t.Fatal("expected the registry's certificate to not be trusted")
+	}
+
+	tf.Spec.TrustedCA = newTestTrustedCA()
+	if !r.usesTrustedCA(tf) {
+		t.Error("expected the trusted CA to be used")
+	}
+	source, err := r.resolveRegistryModule(context.TODO(), tf, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(source, "git::https://gitlab.internal.example.com/org/vpc") {
+		t.Errorf("unexpected source %s", source)
+	}
+}
+
+func TestTrustedCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	serverCA := testCertificate(server)
+
+	tests := []struct {
+		name         string
+		controllerCA string
+		trustedCA    *tfv1alpha2.TrustedCA
+		certificates int
+		wantErr      bool
+	}{
+		// The controller-wide CA comes first
+		{name: "controller and resource", controllerCA: serverCA, trustedCA: newTestTrustedCA(), certificates: 2},
+		{name: "resource", trustedCA: newTestTrustedCA(), certificates: 1},
+		{name: "controller", controllerCA: serverCA, certificates: 1},
+		{name: "invalid", controllerCA: "not a certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		r := newTestTrustedCAReconciler(serverCA)
+		r.TrustedCA = []byte(tt.controllerCA)
+		trustedCA, err := r.getTrustedCA(context.TODO(), newTestTerraform(tfv1alpha2.TerraformSpec{TrustedCA: tt.trustedCA}))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %t, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if got := strings.Count(string(trustedCA), "BEGIN CERTIFICATE"); !tt.wantErr && got != tt.certificates {
+			t.Errorf("%s: expected %d certificates in the bundle, got %d", tt.name, tt.certificates, got)
+		}
+	}
+}
+
+func TestTrustedCATaskPod(t *testing.T) {
+	runOpts := newTaskOptions(newTestTerraform(tfv1alpha2.TerraformSpec{}), tfv1alpha2.RunPlan, 1, nil)
+	runOpts.trustedCA = true
+	runOpts.secretData = map[string][]byte{"config": []byte(""), caBundleKey: []byte("ca")}
+	pod := runOpts.generatePod()
+	envs := podEnvs(pod.Spec.Containers[0])
+	if envs["SSL_CERT_FILE"] != "/tmp/tfo-ca/ca-bundle.crt" || envs["GIT_SSL_CAINFO"] != envs["SSL_CERT_FILE"] {
+		t.Errorf("expected the bundle envs, got %s %s", envs["SSL_CERT_FILE"], envs["GIT_SSL_CAINFO"])
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name != "ssh" {
+			continue
+		}
+		for _, item := range volume.Secret.Items {
+			if item.Key == caBundleKey {
+				t.Error("expected the bundle to not be mounted with the ssh config")
+			}
+		}
+	}
+	mounted := false
+	for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+		mounted = mounted || mount.MountPath == "/tmp/tfo-ca"
+	}
+	if !mounted {
+		t.Error("expected the bundle to be mounted")
+	}
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// variablesFileName is loaded automatically by terraform since it ends in `.auto.tfvars`. Files are
+	// loaded in lexical order so the variable files, "tfo-variables-file-NN.auto.tfvars", are loaded first.
+	variablesFileName = "tfo-variables.auto.tfvars"
+
+	// secretVariablesKey is the key of the tfvars file of the secret values in the versioned secret
+	secretVariablesKey = "variables.tfvars"
+
+	// secretVariablesPath is where the tfvars file of the secret values is mounted in the task pods
+	secretVariablesPath = "/tmp/tfo-secret-variables"
+)
+
+var variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// usesSecretVariables returns true when the tasks get the tfvars file of the values that are not written
+// to the addons ConfigMap, ie the outputs of dependencies and the values from Secrets.
+func usesSecretVariables(tf *tfv1alpha2.Terraform) bool {
+	for _, dependency := range tf.Spec.DependsOn {
+		if len(dependency.Outputs) > 0 {
+			return true
+		}
+	}
+	if tf.Spec.Variables != nil {
+		for _, variable := range tf.Spec.Variables.Values {
+			if variable.ValueFrom != nil && variable.ValueFrom.SecretKeyRef != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderVariables reads the values of spec.variables and returns the tfvars files to add to the addons
+// ConfigMap and the tfvars assignments of the values from Secrets, by variable name, which are kept out of
+// the ConfigMap.
+func (r ReconcileTerraform) renderVariables(ctx context.Context, tf *tfv1alpha2.Terraform) (map[string]string, map[string]string, error) {
+	files := make(map[string]string)
+	secretVariables := make(map[string]string)
+	if tf.Spec.Variables == nil {
+		return files, secretVariables, nil
+	}
+
+	for i, selector := range tf.Spec.Variables.Files {
+		value, found, err := r.getConfigMapKeyValue(ctx, tf.Namespace, selector)
+		if err != nil {
+			return files, secretVariables, err
+		}
+		if found {
+			files[fmt.Sprintf("tfo-variables-file-%02d.auto.tfvars", i)] = value
+		}
+	}
+
+	lines := []string{}
+	for _, variable := range tf.Spec.Variables.Values {
+		if !variableNamePattern.MatchString(variable.Name) {
+			return files, secretVariables, fmt.Errorf("'%s' is not a valid variable name", variable.Name)
+		}
+		value := variable.Value
+		if variable.ValueFrom != nil {
+			var found bool
+			var err error
+			if selector := variable.ValueFrom.SecretKeyRef; selector != nil {
+				value, found, err = r.getSecretKeyValue(ctx, tf.Namespace, *selector)
+				if err != nil {
+					return files, secretVariables, err
+				}
+				if found {
+					line, err := formatVariable(variable.Name, value, variable.Type)
+					if err != nil {
+						return files, secretVariables, err
+					}
+					secretVariables[variable.Name] = line
+				}
+				continue
+			} else if selector := variable.ValueFrom.ConfigMapKeyRef; selector != nil {
+				value, found, err = r.getConfigMapKeyValue(ctx, tf.Namespace, *selector)
+				if err != nil {
+					return files, secretVariables, err
+				}
+				if !found {
+					continue
+				}
+			}
+		}
+		line, err := formatVariable(variable.Name, value, variable.Type)
+		if err != nil {
+			return files, secretVariables, err
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		files[variablesFileName] = strings.Join(lines, "\n") + "\n"
+	}
+	return files, secretVariables, nil
+}
+
+// formatVariable writes the variable as a tfvars assignment.
+func formatVariable(name, value string, variableType tfv1alpha2.VariableType) (string, error) {
+	switch variableType {
+	case "", tfv1alpha2.VariableTypeString:
+		return fmt.Sprintf("%s = %s", name, hclString(value)), nil
+	case tfv1alpha2.VariableTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return "", fmt.Errorf("the value of variable '%s' is not valid json", name)
+		}
+	case tfv1alpha2.VariableTypeNumber, tfv1alpha2.VariableTypeBool, tfv1alpha2.VariableTypeHCL:
+	default:
+		return "", fmt.Errorf("unknown type '%s' of variable '%s'", variableType, name)
+	}
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("the value of variable '%s' is empty", name)
+	}
+	return fmt.Sprintf("%s = %s", name, strings.TrimSpace(value)), nil
+}
+
+// formatOutputVariable writes the output of a dependency as a tfvars assignment. Outputs saved as json
+// objects or lists are written as is and any other output is written as a string, which terraform converts
+// to the type of the variable.
+func formatOutputVariable(name, value string) string {
+	trimmed := strings.TrimSpace(value)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return fmt.Sprintf("%s = %s", name, trimmed)
+	}
+	return fmt.Sprintf("%s = %s", name, hclString(value))
+}
+
+// renderSecretVariables writes the tfvars file of the secret values. The file is passed to the tasks as the
+// last -var-file, which takes precedence over the `.auto.tfvars` files and the resource downloads used as
+// variables.
+func renderSecretVariables(secretVariables map[string]string) []byte {
+	names := []string{}
+	for name := range secretVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, secretVariables[name])
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// secretVariablesVolume mounts the tfvars file of the secret values of the versioned secret. The key is
+// optional since optional values may not be found.
+func (r TaskOptions) secretVariablesVolume() (corev1.Volume, corev1.VolumeMount, corev1.EnvVar) {
+	optional := true
+	volume := corev1.Volume{
+		Name: "secret-variables",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: r.versionedName,
+				Optional:   &optional,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  secretVariablesKey,
+						Path: secretVariablesKey,
+					},
+				},
+			},
+		},
+	}
+	mount := corev1.VolumeMount{
+		Name:      "secret-variables",
+		MountPath: secretVariablesPath,
+		ReadOnly:  true,
+	}
+	env := corev1.EnvVar{
+		Name:  "TFO_SECRET_VAR_FILE",
+		Value: secretVariablesPath + "/" + secretVariablesKey,
+	}
+	return volume, mount, env
+}
+
+// hclString quotes the value as an HCL string. Template sequences are escaped so the value is used
+// literally.
+func hclString(value string) string {
+	b, _ := json.Marshal(value)
+	s := string(b)
+	s = strings.ReplaceAll(s, "${", "$${")
+	s = strings.ReplaceAll(s, "%{", "%%{")
+	return s
+}
+
+func (r ReconcileTerraform) getConfigMapKeyValue(ctx context.Context, namespace string, selector corev1.ConfigMapKeySelector) (string, bool, error) {
+	optional := selector.Optional != nil && *selector.Optional
+	configMap := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) && optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("could not get configmap '%s': %s", selector.Name, err)
+	}
+	value, found := configMap.Data[selector.Key]
+	if !found && !optional {
+		return "", false, fmt.Errorf("key '%s' was not found in configmap '%s'", selector.Key, selector.Name)
+	}
+	return value, found, nil
+}
+
+func (r ReconcileTerraform) getSecretKeyValue(ctx context.Context, namespace string, selector corev1.SecretKeySelector) (string, bool, error) {
+	optional := selector.Optional != nil && *selector.Optional
+	secret, err := r.loadSecret(ctx, selector.Name, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) && optional {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("could not get secret '%s': %s", selector.Name, err)
+	}
+	value, found := secret.Data[selector.Key]
+	if !found && !optional {
+		return "", false, fmt.Errorf("key '%s' was not found in secret '%s'", selector.Key, selector.Name)
+	}
+	return string(value), found, nil
+}
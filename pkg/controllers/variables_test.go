@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFormatVariable(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		variableType tfv1alpha2.VariableType
+		want         string
+		wantErr      bool
+	}{
+		{name: "region", value: "us-east-1", want: `region = "us-east-1"`},
+		{name: "template", value: "${var.x} \"quoted\"", variableType: tfv1alpha2.VariableTypeString, want: `template = "$${var.x} \"quoted\""`},
+		{name: "count", value: "3", variableType: tfv1alpha2.VariableTypeNumber, want: `count = 3`},
+		{name: "zones", value: `["a", "b"]`, variableType: tfv1alpha2.VariableTypeHCL, want: `zones = ["a", "b"]`},
+		{name: "tags", value: `{"team": "platform"}`, variableType: tfv1alpha2.VariableTypeJSON, want: `tags = {"team": "platform"}`},
+		{name: "tags", value: `{"team": }`, variableType: tfv1alpha2.VariableTypeJSON, wantErr: true},
+		{name: "enabled", value: "", variableType: tfv1alpha2.VariableTypeBool, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := formatVariable(tt.name, tt.value, tt.variableType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRenderVariables(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = tfv1alpha2.AddToScheme(scheme)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "inputs"},
+		Data: map[string]string{
+			"env":         "prod",
+			"prod.tfvars": "instance_type = \"m5.large\"\n",
+			"replicas":    "3",
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "creds"},
+		Data:       map[string][]byte{"password": []byte("hunter22")},
+	}
+	tf := &tfv1alpha2.Terraform{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: tfv1alpha2.TerraformSpec{
+			Variables: &tfv1alpha2.Variables{
+				Files: []corev1.ConfigMapKeySelector{
+					{LocalObjectReference: corev1.LocalObjectReference{Name: "inputs"}, Key: "prod.tfvars"},
+				},
+				Values: []tfv1alpha2.Variable{
+					{Name: "region", Value: "us-east-1"},
+					{Name: "env", ValueFrom: &tfv1alpha2.VariableSource{
+						ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "inputs"}, Key: "env"},
+					}},
+					{Name: "replicas", Type: tfv1alpha2.VariableTypeNumber, ValueFrom: &tfv1alpha2.VariableSource{
+						ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "inputs"}, Key: "replicas"},
+					}},
+					{Name: "db_password", ValueFrom: &tfv1alpha2.VariableSource{
+						SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "password"},
+					}},
+				},
+			},
+		},
+	}
+	r := ReconcileTerraform{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap, secret).Build(),
+	}
+
+	files, secretVariables, err := r.renderVariables(context.TODO(), tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string]string{
+		"tfo-variables-file-00.auto.tfvars": "instance_type = \"m5.large\"\n",
+		"tfo-variables.auto.tfvars":         "region = \"us-east-1\"\nenv = \"prod\"\nreplicas = 3\n",
+	}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("expected files %v, got %v", wantFiles, files)
+	}
+	wantSecretVariables := map[string]string{"db_password": `db_password = "hunter22"`}
+	if !reflect.DeepEqual(secretVariables, wantSecretVariables) {
+		t.Errorf("expected secret variables %v, got %v", wantSecretVariables, secretVariables)
+	}
+	if !usesSecretVariables(tf) {
+		t.Error("expected the secret values to be passed in the tfvars file instead of the environment")
+	}
+}
+
+func TestSecretVariables(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "vpc_id", value: "vpc-123", want: `vpc_id = "vpc-123"`},
+		{name: "replicas", value: "3", want: `replicas = "3"`},
+		{name: "subnets", value: `["a","b"]`, want: `subnets = ["a","b"]`},
+		{name: "tags", value: `{"team":"platform"}`, want: `tags = {"team":"platform"}`},
+		{name: "template", value: "[${x}", want: `template = "[$${x}"`},
+	}
+	for _, tt := range tests {
+		if got := formatOutputVariable(tt.name, tt.value); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	if got := string(renderSecretVariables(map[string]string{"b": `b = "2"`, "a": `a = "1"`})); got != "a = \"1\"\nb = \"2\"\n" {
+		t.Errorf("expected the assignments in order of name, got %q", got)
+	}
+}
+
+func TestSecretVariablesFile(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{
+		TerraformVersion: "1.5.7",
+		DependsOn:        []tfv1alpha2.Dependency{{Name: "network", Outputs: []tfv1alpha2.DependencyOutput{{Name: "vpc_id"}}}},
+	})
+	task := taskContainer(tf, tfv1alpha2.RunPlan)
+	envs := podEnvs(task)
+	if want := secretVariablesPath + "/" + secretVariablesKey; envs["TFO_SECRET_VAR_FILE"] != want {
+		t.Errorf("expected TFO_SECRET_VAR_FILE %s, got %q", want, envs["TFO_SECRET_VAR_FILE"])
+	}
+	mounted := false
+	for _, mount := range task.VolumeMounts {
+		mounted = mounted || mount.MountPath == secretVariablesPath
+	}
+	if !mounted {
+		t.Errorf("expected the tfvars file of the secret values to be mounted")
+	}
+}
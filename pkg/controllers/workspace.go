@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/go-version"
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+	"github.com/isaaguilar/terraform-operator/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// workspaceBackends are the backends that support multiple workspaces
+	workspaceBackends = []string{"azurerm", "consul", "cos", "gcs", "kubernetes", "local", "oss", "pg", "remote", "s3"}
+
+	workspaceNamePattern = regexp.MustCompile(`^[0-9A-Za-z_.-]{1,90}$`)
+	backendTypePattern   = regexp.MustCompile(`\bbackend\s+"([^"]+)"`)
+
+	// singleWorkspacePattern finds the `workspaces { name = ... }` of the remote backend, which maps the
+	// configuration to a single workspace, unlike `prefix`
+	singleWorkspacePattern = regexp.MustCompile(`\bworkspaces\s*\{[^}]*\bname\s*=`)
+
+	// minWorkspaceVersion is the first terraform version with `workspace select -or-create`
+	minWorkspaceVersion = version.Must(version.NewVersion("1.4.0"))
+)
+
+// validateWorkspace checks that the workspace can be selected in the backend of spec.backend. Modules
+// that configure their own backend are not checked.
+func validateWorkspace(tf *tfv1alpha2.Terraform) error {
+	workspace := tf.Spec.Workspace
+	if workspace == "" {
+		return nil
+	}
+	if !workspaceNamePattern.MatchString(workspace) {
+		return fmt.Errorf("workspace '%s' must be at most 90 letters, digits, '_', '-' or '.'", workspace)
+	}
+	if engine, v := getEngine(tf); engine == tfv1alpha2.EngineTerraform {
+		if parsed, err := version.NewVersion(v); err == nil && parsed.LessThan(minWorkspaceVersion) {
+			return fmt.Errorf("workspaces require terraform %s or later", minWorkspaceVersion)
+		}
+	}
+	match := backendTypePattern.FindStringSubmatch(tf.Spec.Backend)
+	if match == nil {
+		return nil
+	}
+	backend := match[1]
+	if !utils.ListContainsStr(workspaceBackends, backend) {
+		return fmt.Errorf("the %s backend does not support workspaces", backend)
+	}
+	if backend == "remote" && singleWorkspacePattern.MatchString(tf.Spec.Backend) {
+		return fmt.Errorf("the remote backend must use a workspaces prefix instead of a name to support workspaces")
+	}
+	return nil
+}
+
+// workspaceEnvs selects the workspace of the terraform tasks
+func (r TaskOptions) workspaceEnvs() []corev1.EnvVar {
+	if r.workspace == "" {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  "TF_WORKSPACE",
+			Value: r.workspace,
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	tfv1alpha2 "github.com/isaaguilar/terraform-operator/pkg/apis/tf/v1alpha2"
+)
+
+func TestValidateWorkspace(t *testing.T) {
+	tests := []struct {
+		backend   string
+		workspace string
+		version   string
+		wantErr   bool
+	}{
+		{backend: ""},
+		{backend: `terraform { backend "s3" { bucket = "state" } }`},
+		{backend: "terraform {\n  backend \"kubernetes\" {\n    secret_suffix = \"app\"\n  }\n}\n"},
+		{backend: `terraform { backend "remote" { workspaces { prefix = "app-" } } }`},
+		{backend: `terraform { backend "remote" { workspaces { name = "app" } } }`, wantErr: true},
+		{backend: `terraform { backend "http" { address = "https://state.example.com" } }`, wantErr: true},
+		{backend: `terraform { backend "s3" {} }`, workspace: "prod/eu", wantErr: true},
+		{backend: `terraform { backend "s3" {} }`, version: "1.3.9", wantErr: true},
+		{backend: `terraform { backend "s3" {} }`, version: "latest"},
+	}
+	for _, tt := range tests {
+		tf := newTestTerraform(tfv1alpha2.TerraformSpec{Backend: tt.backend, Workspace: "prod", TerraformVersion: "1.5.7"})
+		if tt.workspace != "" {
+			tf.Spec.Workspace = tt.workspace
+		}
+		if tt.version != "" {
+			tf.Spec.TerraformVersion = tt.version
+		}
+		if err := validateWorkspace(tf); (err != nil) != tt.wantErr {
+			t.Errorf("%s %s %s: expected error %v, got %v", tt.backend, tf.Spec.Workspace, tf.Spec.TerraformVersion, tt.wantErr, err)
+		}
+	}
+}
+
+func TestWorkspaceTasks(t *testing.T) {
+	tf := newTestTerraform(tfv1alpha2.TerraformSpec{TerraformVersion: "1.5.7", Workspace: "prod"})
+	if got := tasksWithEnv(tf, "TF_WORKSPACE"); !reflect.DeepEqual(got, terraformTaskNames) {
+		t.Errorf("expected only the terraform tasks to select the workspace, got %v", got)
+	}
+	tf.Spec.Workspace = ""
+	if got := tasksWithEnv(tf, "TF_WORKSPACE"); len(got) != 0 {
+		t.Errorf("expected the default workspace without spec.workspace, got TF_WORKSPACE in %v", got)
+	}
+}
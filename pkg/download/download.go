@@ -0,0 +1,183 @@
+// Package download fetches the terraform module and resource downloads of a workflow. Sources are fetched
+// with go-getter, which covers git, http archives, S3 and GCS, plus an OCI artifact getter.
+package download
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	getter "github.com/hashicorp/go-getter"
+)
+
+var checksumPattern = regexp.MustCompile(`^(md5|sha1|sha256|sha512):[0-9a-fA-F]+$`)
+
+// ValidateChecksum returns an error when the checksum is not formatted as `<type>:<hex>` where type is one
+// of md5, sha1, sha256 or sha512.
+func ValidateChecksum(checksum string) error {
+	if checksum == "" || checksumPattern.MatchString(checksum) {
+		return nil
+	}
+	return fmt.Errorf("invalid checksum '%s', expected <md5|sha1|sha256|sha512>:<hex>", checksum)
+}
+
+// VerifyFile returns an error when the content of the file does not match the checksum. An empty checksum
+// is not verified.
+func VerifyFile(path, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	if err := ValidateChecksum(checksum); err != nil {
+		return err
+	}
+	parts := strings.SplitN(checksum, ":", 2)
+	var h hash.Hash
+	switch parts[0] {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, parts[1]) {
+		return fmt.Errorf("checksums did not match for '%s', expected %s got %s:%s", path, checksum, parts[0], got)
+	}
+	return nil
+}
+
+// NormalizeAddress converts the `s3://<bucket>/<key>` and `gs://<bucket>/<object>` shorthands to the
+// addresses go-getter understands. Other addresses are returned as is.
+func NormalizeAddress(address string) string {
+	switch {
+	case strings.HasPrefix(address, "s3://"):
+		return "s3::https://s3.amazonaws.com/" + strings.TrimPrefix(address, "s3://")
+	case strings.HasPrefix(address, "gs://"):
+		return "gcs::https://www.googleapis.com/storage/v1/" + strings.TrimPrefix(address, "gs://")
+	}
+	return address
+}
+
+// withChecksum adds the checksum to the query of the address where go-getter reads it from
+func withChecksum(address, checksum string) string {
+	if checksum == "" {
+		return address
+	}
+	if strings.Contains(address, "?") {
+		return address + "&checksum=" + checksum
+	}
+	return address + "?checksum=" + checksum
+}
+
+// Fetch downloads the address into the dst directory. The address is downloaded to a temporary directory
+// first and is only copied to dst once the checksum, when given, is verified. Archives are verified before
+// they are extracted. The proxy is optional.
+func Fetch(ctx context.Context, address, dst, checksum string, proxy *Proxy) error {
+	if err := ValidateChecksum(checksum); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir("", "tfo-download-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	getters := make(map[string]getter.Getter)
+	for k, v := range getter.Getters {
+		getters[k] = v
+	}
+	getters["oci"] = &OCIGetter{}
+	proxy.setGetters(getters)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	client := &getter.Client{
+		Ctx:     ctx,
+		Src:     withChecksum(NormalizeAddress(address), checksum),
+		Dst:     filepath.Join(tmp, "download"),
+		Pwd:     pwd,
+		Mode:    getter.ClientModeAny,
+		Getters: getters,
+	}
+	err = client.Get()
+	if err != nil {
+		return fmt.Errorf("could not download '%s': %v", address, err)
+	}
+	return copyDir(filepath.Join(tmp, "download"), dst)
+}
+
+// copyDir copies the content of src into dst. Symlinks, which the file getter creates, are followed.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.Mode()&os.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return err
+			}
+			resolvedInfo, err := os.Stat(resolved)
+			if err != nil {
+				return err
+			}
+			if resolvedInfo.IsDir() {
+				return copyDir(resolved, target)
+			}
+			return copyFile(resolved, target, resolvedInfo.Mode())
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
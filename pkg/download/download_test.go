@@ -0,0 +1,313 @@
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestValidateChecksum(t *testing.T) {
+	for _, checksum := range []string{"", "sha256:abcdef0123", "md5:ABCDEF", "sha512:00"} {
+		if err := ValidateChecksum(checksum); err != nil {
+			t.Errorf("expected '%s' to be valid, got %v", checksum, err)
+		}
+	}
+	for _, checksum := range []string{"abcdef", "sha256:", "crc32:abcd", "sha256:xyz", "file:https://example.com/SHA256SUMS"} {
+		if err := ValidateChecksum(checksum); err == nil {
+			t.Errorf("expected '%s' to be invalid", checksum)
+		}
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prod.tfvars")
+	if err := ioutil.WriteFile(path, []byte("a = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, checksum := range []string{"", "sha256:" + sha256Hex([]byte("a = 1\n")), "sha256:" + strings.ToUpper(sha256Hex([]byte("a = 1\n")))} {
+		if err := VerifyFile(path, checksum); err != nil {
+			t.Errorf("expected '%s' to match, got %v", checksum, err)
+		}
+	}
+	if err := VerifyFile(path, "sha256:"+sha256Hex([]byte("a = 2\n"))); err == nil || !strings.Contains(err.Error(), "checksums did not match") {
+		t.Errorf("expected the checksum to not match, got %v", err)
+	}
+	if err := VerifyFile(path, "sha256"); err == nil {
+		t.Error("expected the invalid checksum to be rejected")
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct{ address, want string }{
+		{"s3://bucket/modules/vpc.zip", "s3::https://s3.amazonaws.com/bucket/modules/vpc.zip"},
+		{"gs://bucket/modules/vpc.zip", "gcs::https://www.googleapis.com/storage/v1/bucket/modules/vpc.zip"},
+		{"https://example.com/vpc.tar.gz", "https://example.com/vpc.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAddress(tt.address); got != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestFetchHTTPArchive(t *testing.T) {
+	archive := tarGz(t, map[string]string{"main.tf": "# vpc", "modules/nat/main.tf": "# nat"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	dst := t.TempDir()
+	err := Fetch(context.TODO(), server.URL+"/vpc.tar.gz", dst, "sha256:"+sha256Hex(archive), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dst, "modules", "nat", "main.tf")); got != "# nat" {
+		t.Errorf("expected the archive to be extracted, got %s", got)
+	}
+
+	// A checksum mismatch must not leave any files in dst
+	dst = t.TempDir()
+	err = Fetch(context.TODO(), server.URL+"/vpc.tar.gz", dst, "sha256:"+sha256Hex([]byte("tampered")), nil)
+	if err == nil || !strings.Contains(strings.ToLower(err.Error()), "checksum") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	entries, _ := ioutil.ReadDir(dst)
+	if len(entries) != 0 {
+		t.Errorf("expected dst to be empty, got %d entries", len(entries))
+	}
+}
+
+func TestFetchS3(t *testing.T) {
+	archive := tarGz(t, map[string]string{"main.tf": "# from s3"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/modules" && req.URL.Query().Get("prefix") != "":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<ListBucketResult><Name>modules</Name><IsTruncated>false</IsTruncated><Contents><Key>vpc.tar.gz</Key><Size>%d</Size></Contents></ListBucketResult>`, len(archive))
+		case req.URL.Path == "/modules/vpc.tar.gz":
+			w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dst := t.TempDir()
+	address := "s3::" + server.URL + "/modules/vpc.tar.gz?aws_access_key_id=id&aws_access_key_secret=secret&region=us-east-1"
+	err := Fetch(context.TODO(), address, dst, "sha256:"+sha256Hex(archive), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dst, "main.tf")); got != "# from s3" {
+		t.Errorf("expected the object to be extracted, got %s", got)
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	layer := tarGz(t, map[string]string{"main.tf": "# from oci"})
+	layerDigest := "sha256:" + sha256Hex(layer)
+	readme := []byte("readme")
+	readmeDigest := "sha256:" + sha256Hex(readme)
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "mediaType": "%s", "layers": [
+		{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "%s", "size": %d},
+		{"mediaType": "text/plain", "digest": "%s", "size": %d, "annotations": {"%s": "docs/README.md"}}
+	]}`, ociManifestMediaType, layerDigest, len(layer), readmeDigest, len(readme), ociTitleAnnotation)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if user, pass, _ := req.BasicAuth(); user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token": "t0ken"}`)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:org/vpc:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v2/org/vpc/manifests/1.0.0":
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			fmt.Fprint(w, manifest)
+		case "/v2/org/vpc/blobs/" + layerDigest:
+			w.Write(layer)
+		case "/v2/org/vpc/blobs/" + readmeDigest:
+			w.Write(readme)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dst := t.TempDir()
+	host := strings.TrimPrefix(server.URL, "http://")
+	err := Fetch(context.TODO(), "oci://user:pass@"+host+"/org/vpc:1.0.0?insecure=true", dst, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dst, "main.tf")); got != "# from oci" {
+		t.Errorf("expected the layer to be extracted, got %s", got)
+	}
+	if got := readFile(t, filepath.Join(dst, "docs", "README.md")); got != "readme" {
+		t.Errorf("expected the titled layer to be written, got %s", got)
+	}
+}
+
+func TestFetchLocalDir(t *testing.T) {
+	// ConfigMaps and Secrets are mounted into the pod and fetched from their mount path
+	src := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(src, "main.tf"), []byte("# from configmap"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	err = Fetch(context.TODO(), "file://"+src, dst, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dst, "main.tf")); got != "# from configmap" {
+		t.Errorf("expected the dir to be copied, got %s", got)
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "main.tf")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("expected a regular file, got %v %v", info, err)
+	}
+}
+
+// serveSOCKS5 accepts SOCKS5 connect requests without auth and counts the connections
+func serveSOCKS5(listener net.Listener, connections *int32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+				return
+			}
+			conn.Write([]byte{5, 0})
+			request := make([]byte, 4)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			var host string
+			switch request[3] {
+			case 1:
+				ip := make([]byte, 4)
+				io.ReadFull(conn, ip)
+				host = net.IP(ip).String()
+			case 3:
+				length := make([]byte, 1)
+				io.ReadFull(conn, length)
+				name := make([]byte, length[0])
+				io.ReadFull(conn, name)
+				host = string(name)
+			default:
+				return
+			}
+			port := make([]byte, 2)
+			io.ReadFull(conn, port)
+			target, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprint(int(port[0])<<8|int(port[1]))))
+			if err != nil {
+				conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+				return
+			}
+			defer target.Close()
+			atomic.AddInt32(connections, 1)
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			go io.Copy(target, conn)
+			io.Copy(conn, target)
+		}(conn)
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "policy")
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var connections int32
+	go serveSOCKS5(listener, &connections)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	proxy := &Proxy{Address: listener.Addr().String(), Hosts: []string{"git.internal.example.com"}}
+	err = Fetch(context.TODO(), server.URL+"/policy.rego", t.TempDir(), "", proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&connections) != 0 {
+		t.Error("expected hosts that do not require the proxy to be fetched directly")
+	}
+
+	proxy.Hosts = append(proxy.Hosts, host)
+	dst := t.TempDir()
+	err = Fetch(context.TODO(), server.URL+"/policy.rego", dst, "", proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&connections) == 0 {
+		t.Error("expected the download to go through the proxy")
+	}
+	if got := readFile(t, filepath.Join(dst, "policy.rego")); got != "policy" {
+		t.Errorf("expected the file to be downloaded, got %s", got)
+	}
+}
@@ -0,0 +1,98 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	getter "github.com/hashicorp/go-getter"
+)
+
+// Proxy routes the http downloads from Hosts through the SOCKS5 proxy at Address, eg the ssh tunnel started
+// by StartTunnel. The git cli uses the `http.<url>.proxy` config the controller sets in the task's
+// environment, go-git clients use ProxyFunc.
+type Proxy struct {
+	Address string
+	Hosts   []string
+}
+
+// ProxyFunc returns the Proxy func of an http.Transport that routes the hosts through the proxy, or nil
+// when no hosts are routed through it
+func (p *Proxy) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if p == nil || p.Address == "" || len(p.Hosts) == 0 {
+		return nil
+	}
+	return p.proxyURL
+}
+
+func (p *Proxy) proxyURL(req *http.Request) (*url.URL, error) {
+	for _, host := range p.Hosts {
+		if strings.EqualFold(host, req.URL.Host) || strings.EqualFold(host, req.URL.Hostname()) {
+			return &url.URL{Scheme: "socks5", Host: p.Address}, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// setGetters replaces the http getters with ones that use the proxy
+func (p *Proxy) setGetters(getters map[string]getter.Getter) {
+	proxyFunc := p.ProxyFunc()
+	if proxyFunc == nil {
+		return
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	httpGetter := &getter.HttpGetter{
+		Netrc:  true,
+		Client: &http.Client{Transport: transport},
+	}
+	getters["http"] = httpGetter
+	getters["https"] = httpGetter
+}
+
+// StartTunnel starts `ssh -N <host>` with the ssh config and waits for the SOCKS5 proxy, which the config's
+// DynamicForward defines, to listen on address. The returned func stops the tunnel.
+func StartTunnel(ctx context.Context, sshConfig, host, address string) (func(), error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, "ssh", "-F", sshConfig, "-N", host)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Start()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("could not start the ssh tunnel: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	stop := func() {
+		cancel()
+		<-exited
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return stop, nil
+		}
+		select {
+		case err := <-exited:
+			cancel()
+			return nil, fmt.Errorf("the ssh tunnel exited: %v", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			stop()
+			return nil, fmt.Errorf("the ssh tunnel did not listen on %s", address)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package gitclient
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,17 +22,33 @@ import (
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	gitauth "gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
 	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
 
+// ErrNoChanges is returned by Commit when none of the files changed
+var ErrNoChanges = errors.New("no changes to commit")
+
+const (
+	defaultAuthorName  = "devops-automation"
+	defaultAuthorEmail = "devops-automation@example.com"
+)
+
 type GitRepo struct {
-	auth gitauth.AuthMethod
-	url  string
-	repo *git.Repository
-	ref  *plumbing.Reference
-	Log  logr.Logger
+	auth        gitauth.AuthMethod
+	url         string
+	repo        *git.Repository
+	ref         *plumbing.Reference
+	authorName  string
+	authorEmail string
+	httpsClient gitauth.Transport
+	rootCAs     *x509.CertPool
+	proxy       func(*http.Request) (*url.URL, error)
+	Log         logr.Logger
 }
 
 func NewGitRepo(user, password, sshKeyFilename string, logger logr.Logger) (GitRepo, error) {
@@ -50,6 +70,95 @@ func NewGitRepo(user, password, sshKeyFilename string, logger logr.Logger) (GitR
 	}, nil
 }
 
+// NewGitRepoWithSSHKey is like NewGitRepo but takes the content of the ssh key instead of a filename.
+func NewGitRepoWithSSHKey(user, password string, sshKey []byte, logger logr.Logger) (GitRepo, error) {
+	var auth gitauth.AuthMethod
+	var err error
+	if password != "" {
+		auth = passwordAuthMethod(user, password)
+	}
+	if len(sshKey) > 0 {
+		auth, err = sshAuthMethodFromKey(sshKey)
+		if err != nil {
+			return GitRepo{}, err
+		}
+	}
+
+	return GitRepo{
+		auth: auth,
+		Log:  logger,
+	}, nil
+}
+
+// ResolveRef finds the commit sha of a branch or tag without cloning the repo, like `git ls-remote`. A ref
+// that is already a commit sha is returned as is. An empty ref resolves the remote's HEAD. Annotated tags
+// resolve to the commit they point to rather than to the tag object.
+func (g *GitRepo) ResolveRef(url, ref string) (string, error) {
+	if IsCommitHash(ref) {
+		return ref, nil
+	}
+	g.url = url
+	reqLogger := g.Log.WithValues("repo", url)
+	reqLogger.V(1).Info(fmt.Sprintf("Listing refs to resolve '%s'", ref))
+	ar, err := g.advertisedReferences(url)
+	if err != nil {
+		return "", fmt.Errorf("could not list refs: %v", err)
+	}
+	refs, err := ar.AllReferences()
+	if err != nil {
+		return "", fmt.Errorf("could not list refs: %v", err)
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+		plumbing.ReferenceName(ref),
+	}
+	if ref == "" || ref == "HEAD" {
+		candidates = []plumbing.ReferenceName{plumbing.HEAD}
+	}
+	for _, name := range candidates {
+		// Follow symbolic refs, ie HEAD -> refs/heads/main
+		r, err := storer.ResolveReference(refs, name)
+		if err != nil {
+			continue
+		}
+		// The peeled hash, ie of `refs/tags/<tag>^{}`, is the commit of an annotated tag
+		if peeled, ok := ar.Peeled[r.Name().String()]; ok {
+			return peeled.String(), nil
+		}
+		return r.Hash().String(), nil
+	}
+	return "", fmt.Errorf("ref '%s' was not found", ref)
+}
+
+// advertisedReferences lists the refs of the remote. Unlike listing the refs of a git.Remote, the peeled
+// hashes of the annotated tags are kept.
+func (g *GitRepo) advertisedReferences(url string) (*packp.AdvRefs, error) {
+	ep, err := gitauth.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.NewUploadPackSession(ep, g.authMethod())
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return s.AdvertisedReferences()
+}
+
+// IsCommitHash returns true when the ref is a full 40 character commit sha.
+func IsCommitHash(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	return strings.Trim(strings.ToLower(ref), "0123456789abcdef") == ""
+}
+
 func (g *GitRepo) HashString() (string, error) {
 	if g.ref == nil {
 		return "", fmt.Errorf("the GitRepo.ref has not been set")
@@ -75,9 +184,7 @@ func (g *GitRepo) checkout(commit string) error {
 		return fmt.Errorf("could not get Worktree: %v", err)
 	}
 
-	// Check if this is a hash
-	n, _ := strconv.ParseUint(commit, 16, 64)
-	if len(commit) == 40 && n > 0 {
+	if IsCommitHash(commit) {
 		reqLogger.V(1).Info(fmt.Sprintf("Checking out hash: '%v'", commit))
 		// Try checking out a hash commit
 		err = w.Checkout(&git.CheckoutOptions{
@@ -88,12 +195,26 @@ func (g *GitRepo) checkout(commit string) error {
 		}
 	} else {
 		reqLogger.V(1).Info(fmt.Sprintf("Checking out branch: '%v'", "refs/heads/"+commit))
-		// Try checking out a branch
+		// Try checking out a branch and then a tag
 		err = w.Checkout(&git.CheckoutOptions{
-			Branch: plumbing.ReferenceName("refs/heads/" + commit),
+			Branch: plumbing.NewBranchReferenceName(commit),
 		})
 		if err != nil {
-			return fmt.Errorf("error checking out branch: %v", err)
+			tag, tagErr := g.repo.Tag(commit)
+			if tagErr != nil {
+				return fmt.Errorf("error checking out branch: %v", err)
+			}
+			hash := tag.Hash()
+			// Annotated tags point to a tag object instead of the commit
+			if tagObject, err := g.repo.TagObject(hash); err == nil {
+				hash = tagObject.Target
+			}
+			err = w.Checkout(&git.CheckoutOptions{
+				Hash: hash,
+			})
+			if err != nil {
+				return fmt.Errorf("error checking out tag: %v", err)
+			}
 		}
 	}
 	ref, err := g.repo.Head()
@@ -116,12 +237,8 @@ func (g *GitRepo) downloadGitRepo(c chan error, wg *sync.WaitGroup, url, repoDir
 	gitConfigs := git.CloneOptions{
 		URL:               url,
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		ReferenceName:     "refs/heads/master",
 		Progress:          progressLogger,
-	}
-
-	if g.auth != nil {
-		gitConfigs.Auth = g.auth
+		Auth:              g.authMethod(),
 	}
 
 	err := gitConfigs.Validate()
@@ -149,7 +266,7 @@ func (g *GitRepo) fetchRefs() error {
 	// Checkout the git-refs used for checkouts
 	reqLogger.V(1).Info("Fetching refs")
 	err := g.repo.Fetch(&git.FetchOptions{
-		Auth:     g.auth,
+		Auth:     g.authMethod(),
 		RefSpecs: []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
 		Progress: progressLogger,
 	})
@@ -188,6 +305,12 @@ func sshAuthMethod(sshKeyFilename string) (gitauth.AuthMethod, error) {
 		return auth, err
 	}
 
+	return sshAuthMethodFromKey(keyF)
+}
+
+func sshAuthMethodFromKey(keyF []byte) (gitauth.AuthMethod, error) {
+	var auth gitauth.AuthMethod
+
 	// Create the Signer for this private key.
 	signer, err := ssh.ParsePrivateKey(keyF)
 	if err != nil {
@@ -241,7 +364,9 @@ func (g *GitRepo) GitHTTPDownload(url, repoDir, user, password, ref string, time
 	reqLogger.V(1).Info("Successfully fetched refs")
 
 	if ref != "" {
-		g.checkout(ref)
+		if err := g.checkout(ref); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -276,7 +401,9 @@ func (g *GitRepo) GitSSHDownload(url, repoDir, sshKeyFilename, ref string, timeo
 	reqLogger.V(1).Info("Successfully fetched refs")
 
 	if ref != "" {
-		g.checkout(ref)
+		if err := g.checkout(ref); err != nil {
+			return err
+		}
 		reqLogger.V(1).Info(fmt.Sprintf("Checked out '%s' for '%s'", ref, url))
 	}
 	return nil
@@ -326,6 +453,68 @@ func (g *GitRepo) CheckoutBranch(branch string) error {
 	return nil
 }
 
+// SetAuthor sets the author of commits. Empty values keep the default author.
+func (g *GitRepo) SetAuthor(name, email string) {
+	g.authorName = name
+	g.authorEmail = email
+}
+
+// CloneBranch clones a single branch of the repo into repoDir. The default branch is cloned when branch is
+// empty. When the branch does not exist, it is created from the default branch and is pushed on the next
+// Push.
+func (g *GitRepo) CloneBranch(url, repoDir, branch string) error {
+	g.url = url
+	reqLogger := g.Log.WithValues("repo", url)
+	cloneOptions := git.CloneOptions{
+		URL:          url,
+		Auth:         g.authMethod(),
+		SingleBranch: true,
+	}
+	if branch != "" {
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+
+	reqLogger.V(1).Info(fmt.Sprintf("Cloning branch '%s'", branch))
+	var r *git.Repository
+	clone := func() (err error) {
+		r, err = git.PlainClone(repoDir, false, &cloneOptions)
+		return err
+	}
+	err := clone()
+	if err != nil && branch != "" && isRemoteRefNotFound(err) {
+		reqLogger.V(1).Info(fmt.Sprintf("Branch '%s' does not exist, cloning the default branch", branch))
+		os.RemoveAll(repoDir)
+		cloneOptions.ReferenceName = ""
+		err = clone()
+		if err != nil {
+			return fmt.Errorf("could not clone repo: %v", err)
+		}
+		g.repo = r
+		ref, err := r.Head()
+		if err != nil {
+			return fmt.Errorf("error reading head: %v", err)
+		}
+		g.ref = ref
+		return g.CheckoutBranch(plumbing.NewBranchReferenceName(branch).String())
+	}
+	if err != nil {
+		return fmt.Errorf("could not clone repo: %v", err)
+	}
+	g.repo = r
+	ref, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("error reading head: %v", err)
+	}
+	g.ref = ref
+	return nil
+}
+
+// isRemoteRefNotFound returns true when the clone failed because the remote does not have the branch. go-git
+// only returns a formatted error in that case.
+func isRemoteRefNotFound(err error) bool {
+	return errors.Is(err, plumbing.ErrReferenceNotFound) || strings.HasPrefix(err.Error(), "couldn't find remote ref")
+}
+
 func (g *GitRepo) Commit(filenames []string, message string) error {
 	reqLogger := g.Log.WithValues("repo", g.url)
 	w, err := g.repo.Worktree()
@@ -339,7 +528,7 @@ func (g *GitRepo) Commit(filenames []string, message string) error {
 	}
 
 	if status.IsClean() {
-		return fmt.Errorf("no changes to commit")
+		return ErrNoChanges
 	}
 
 	filesInStatus := []string{}
@@ -358,7 +547,7 @@ func (g *GitRepo) Commit(filenames []string, message string) error {
 	}
 
 	if !isFileInStatus {
-		return fmt.Errorf("no changes to commit")
+		return ErrNoChanges
 	}
 
 	for _, f := range filenames {
@@ -369,10 +558,18 @@ func (g *GitRepo) Commit(filenames []string, message string) error {
 		}
 	}
 
+	authorName := g.authorName
+	if authorName == "" {
+		authorName = defaultAuthorName
+	}
+	authorEmail := g.authorEmail
+	if authorEmail == "" {
+		authorEmail = defaultAuthorEmail
+	}
 	commit, err := w.Commit(message, &git.CommitOptions{
 		Author: &object.Signature{
-			Name:  "devops-automation",
-			Email: "devops-automation@example.com",
+			Name:  authorName,
+			Email: authorEmail,
 			When:  time.Now(),
 		},
 	})
@@ -403,7 +600,7 @@ func (g *GitRepo) Push(target plumbing.ReferenceName) error {
 	reqLogger.V(1).Info(fmt.Sprintf("Pushing '%v' to repo", target))
 	err := g.repo.Push(&git.PushOptions{
 		RefSpecs: []config.RefSpec{config.RefSpec(g.ref.Name() + ":" + target)},
-		Auth:     g.auth,
+		Auth:     g.authMethod(),
 		Progress: progressLogger,
 	})
 	if err != nil {
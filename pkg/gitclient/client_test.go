@@ -0,0 +1,158 @@
+package gitclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+var testSignature = &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+
+// newTestRemote creates a bare repo with a commit on master and returns its path
+func newTestRemote(t *testing.T) (string, *git.Repository) {
+	dir, err := ioutil.TempDir("", "gitclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	src := filepath.Join(dir, "src")
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "main.tf"), []byte("# main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("main.tf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit("initial", &git.CommitOptions{Author: testSignature}); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(dir, "remote.git")
+	bare, err := git.PlainClone(remote, true, &git.CloneOptions{URL: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote, bare
+}
+
+func TestCloneBranch(t *testing.T) {
+	remote, bare := newTestRemote(t)
+
+	tests := []struct {
+		branch string
+		exists bool
+	}{
+		{branch: "master", exists: true},
+		{branch: "exports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "clone")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			g, err := NewGitRepo("", "", "", logr.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := g.CloneBranch(remote, dir, tt.branch); err != nil {
+				t.Fatal(err)
+			}
+			branch, err := g.BranchName()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := plumbing.NewBranchReferenceName(tt.branch).String(); branch != want {
+				t.Errorf("expected the branch %s to be checked out, got %s", want, branch)
+			}
+
+			// A missing branch is created on the remote by the first push
+			if err := ioutil.WriteFile(filepath.Join(dir, tt.branch+".tf"), []byte("# export\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Commit([]string{tt.branch + ".tf"}, "export"); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Push(""); err != nil {
+				t.Fatal(err)
+			}
+			ref, err := bare.Reference(plumbing.NewBranchReferenceName(tt.branch), true)
+			if err != nil {
+				t.Fatalf("expected the remote to have the branch %s: %v", tt.branch, err)
+			}
+			commit, err := bare.CommitObject(ref.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit.Message != "export" || commit.NumParents() != 1 {
+				t.Errorf("expected the export to be committed on top of master, got %q with %d parents", commit.Message, commit.NumParents())
+			}
+		})
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	remote, bare := newTestRemote(t)
+	head, err := bare.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := head.Hash().String()
+	if _, err := bare.CreateTag("v1.0.0", head.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+	annotated, err := bare.CreateTag("v1.1.0", head.Hash(), &git.CreateTagOptions{Tagger: testSignature, Message: "v1.1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotated.Hash().String() == commit {
+		t.Fatal("expected the annotated tag to be a tag object")
+	}
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "", want: commit},
+		{ref: "master", want: commit},
+		{ref: "v1.0.0", want: commit},
+		{ref: "v1.1.0", want: commit},
+		{ref: "refs/tags/v1.1.0", want: commit},
+		{ref: commit, want: commit},
+		{ref: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		g, err := NewGitRepo("", "", "", logr.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := g.ResolveRef(remote, tt.ref)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", tt.ref, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: expected the commit %s, got %s %v", tt.ref, tt.want, got, err)
+		}
+	}
+}
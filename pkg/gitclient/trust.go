@@ -0,0 +1,113 @@
+package gitclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	gitauth "gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// go-git only has a global transport registry, so the https transport is installed once and picks the
+// client of each operation from its auth method. Repos that trust additional CAs or use a proxy wrap their
+// auth method with their own client.
+func init() {
+	client.InstallProtocol("https", httpsTransport{})
+}
+
+// trustedAuth is the auth method of a repo with trusted CAs or a proxy. It carries the client that uses
+// them.
+type trustedAuth struct {
+	auth   gitauth.AuthMethod
+	client gitauth.Transport
+}
+
+func (a *trustedAuth) Name() string {
+	if a.auth == nil {
+		return "trusted-ca"
+	}
+	return a.auth.Name()
+}
+
+func (a *trustedAuth) String() string {
+	if a.auth == nil {
+		return a.Name()
+	}
+	return a.auth.String()
+}
+
+// httpsTransport opens the sessions with the client of a trustedAuth and with the default client otherwise
+type httpsTransport struct{}
+
+func (httpsTransport) session(auth gitauth.AuthMethod) (gitauth.Transport, gitauth.AuthMethod) {
+	if a, ok := auth.(*trustedAuth); ok {
+		return a.client, a.auth
+	}
+	return githttp.DefaultClient, auth
+}
+
+func (t httpsTransport) NewUploadPackSession(ep *gitauth.Endpoint, auth gitauth.AuthMethod) (gitauth.UploadPackSession, error) {
+	c, auth := t.session(auth)
+	return c.NewUploadPackSession(ep, auth)
+}
+
+func (t httpsTransport) NewReceivePackSession(ep *gitauth.Endpoint, auth gitauth.AuthMethod) (gitauth.ReceivePackSession, error) {
+	c, auth := t.session(auth)
+	return c.NewReceivePackSession(ep, auth)
+}
+
+// SetTrustedCA makes the repo trust the PEM encoded certificates in addition to the system roots.
+func (g *GitRepo) SetTrustedCA(pem []byte) error {
+	if len(pem) == 0 {
+		g.rootCAs = nil
+		g.setHTTPSClient()
+		return nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates were found in the CA bundle")
+	}
+	g.rootCAs = pool
+	g.setHTTPSClient()
+	return nil
+}
+
+// SetProxy routes the repo's https operations through the proxy the func returns for each request, like
+// the Proxy of an http.Transport. A nil func uses the default client.
+func (g *GitRepo) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	g.proxy = proxy
+	g.setHTTPSClient()
+}
+
+// setHTTPSClient builds the client of the repo's https operations from its trusted CAs and proxy
+func (g *GitRepo) setHTTPSClient() {
+	if g.rootCAs == nil && g.proxy == nil {
+		g.httpsClient = nil
+		return
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if g.rootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: g.rootCAs}
+	}
+	if g.proxy != nil {
+		transport.Proxy = g.proxy
+	}
+	g.httpsClient = githttp.NewClient(&http.Client{Transport: transport})
+}
+
+// authMethod is the auth method of the repo's network operations, which carries the client that trusts
+// the repo's CAs and uses its proxy over https
+func (g *GitRepo) authMethod() gitauth.AuthMethod {
+	if g.httpsClient == nil || !strings.HasPrefix(g.url, "https://") {
+		return g.auth
+	}
+	return &trustedAuth{auth: g.auth, client: g.httpsClient}
+}
@@ -0,0 +1,79 @@
+package gitclient
+
+import (
+	"encoding/pem"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestTrustedCA(t *testing.T) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	backend := filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git-http-backend is not installed")
+	}
+	remote, bare := newTestRemote(t)
+	head, err := bare.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewTLSServer(&cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(remote), "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	defer server.Close()
+	url := server.URL + "/" + filepath.Base(remote)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// The repos resolve at the same time and only the one with the server's CA trusts it
+	tests := []struct {
+		name    string
+		ca      []byte
+		wantErr string
+	}{
+		{name: "trusted", ca: ca},
+		{name: "untrusted", wantErr: "certificate"},
+	}
+	var wg sync.WaitGroup
+	for _, tt := range tests {
+		tt := tt
+		g, err := NewGitRepo("", "", "", logr.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.SetTrustedCA(tt.ca); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hash, err := g.ResolveRef(url, "master")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("%s: expected a %s error, got %v", tt.name, tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || hash != head.Hash().String() {
+				t.Errorf("%s: expected %s, got %s %v", tt.name, head.Hash(), hash, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	g, _ := NewGitRepo("", "", "", logr.Discard())
+	if err := g.SetTrustedCA([]byte("not a certificate")); err == nil {
+		t.Errorf("expected a bundle without certificates to be rejected")
+	}
+}
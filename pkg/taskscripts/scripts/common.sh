@@ -0,0 +1,52 @@
+# Sourced by the default task scripts
+
+# The tunnel sidecar runs until the task creates the done file, which is created however the script exits
+if [ -n "${TFO_TASK_DONE_FILE:-}" ]; then
+  trap 'touch "$TFO_TASK_DONE_FILE"' EXIT
+fi
+
+# fail writes the reason of the failure to the error marker, which the controller copies into the stage
+fail() {
+  echo "$*" >&2
+  if [ -n "${TFO_ERROR_MARKER_FILE:-}" ]; then
+    echo "$*" > "$TFO_ERROR_MARKER_FILE" 2>/dev/null || true
+  fi
+  exit 1
+}
+
+# log_task copies the output of the task into the generation's directory
+log_task() {
+  mkdir -p "$TFO_GENERATION_PATH"
+  exec > >(tee -a "$TFO_GENERATION_PATH/$TFO_TASK.out") 2>&1
+}
+
+# setup_ssh copies the ssh config and keys since ssh refuses the permissions of the mounted files
+setup_ssh() {
+  if [ -z "$(ls -A /tmp/ssh 2>/dev/null)" ]; then
+    return
+  fi
+  mkdir -p "$HOME/.ssh"
+  cp /tmp/ssh/* "$HOME/.ssh/"
+  chmod 600 "$HOME/.ssh/"*
+}
+
+# start_tunnel starts the ssh tunnel when it is not run by the tunnel sidecar
+start_tunnel() {
+  if [ -n "${TFO_SSH_TUNNEL_HOST:-}" ]; then
+    ssh -F "$HOME/.ssh/config" -f -N "$TFO_SSH_TUNNEL_HOST" || fail "could not start the ssh tunnel"
+  fi
+}
+
+# verify_checksum checks the file against a `<type>:<hex>` checksum
+verify_checksum() {
+  local file="$1" checksum="$2"
+  if [ -z "$checksum" ]; then
+    return
+  fi
+  local type="${checksum%%:*}" want="${checksum#*:}"
+  local got
+  got=$("${type}sum" "$file" | cut -d' ' -f1) || fail "unsupported checksum type '$type'"
+  if [ "$(echo "$got" | tr A-F a-f)" != "$(echo "$want" | tr A-F a-f)" ]; then
+    fail "checksum of '$file' did not match: expected $want, got $got"
+  fi
+}
@@ -0,0 +1,3 @@
+#!/bin/bash
+# Default script of the script tasks. The tasks do nothing unless a script is configured in taskOptions.
+echo "No script is configured for the $TFO_TASK task"
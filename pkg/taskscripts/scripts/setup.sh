@@ -0,0 +1,121 @@
+#!/bin/bash
+# Default script of the setup and setup-delete tasks. Puts the main module, the resource downloads and the
+# main module addons into the generation's directory.
+set -o errexit -o nounset -o pipefail
+. "$TFO_TASK_EXEC_CONFIGMAP_SOURCE_PATH/common.sh"
+
+log_task
+setup_ssh
+start_tunnel
+
+if [ "${TFO_CLEANUP_DISK:-}" = "true" ]; then
+  find "$TFO_ROOT_PATH/generations" -mindepth 1 -maxdepth 1 ! -name "$TFO_GENERATION" -exec rm -rf {} +
+fi
+
+addons="$TFO_MAIN_MODULE_ADDONS"
+var_files="$TFO_GENERATION_PATH/var-files"
+rm -rf "$TFO_MAIN_MODULE" "$var_files"
+mkdir -p "$TFO_MAIN_MODULE"
+touch "$var_files"
+
+# git_download clones the repo of a `git::<url>[//<subdir>][?ref=<ref>]` address into the directory
+git_download() {
+  local address="${1#git::}" dst="$2"
+  local ref="" subdir="."
+  if [[ "$address" == *"?"* ]]; then
+    ref=$(echo "${address#*\?}" | tr '&' '\n' | sed -n 's/^ref=//p')
+    address="${address%%\?*}"
+  fi
+  if [[ "${address#*://}" == *"//"* ]]; then
+    local scheme="${address%%://*}://" rest="${address#*://}"
+    subdir="${rest#*//}"
+    address="$scheme${rest%%//*}"
+  fi
+  local repo
+  repo=$(mktemp -d)
+  git clone --quiet "$address" "$repo" || fail "could not clone '$address'"
+  if [ -n "$ref" ]; then
+    git -C "$repo" checkout --quiet "$ref" || fail "could not checkout '$ref' of '$address'"
+  fi
+  mkdir -p "$dst"
+  cp -r "$repo/$subdir/." "$dst/"
+  rm -rf "$repo"
+}
+
+# http_download fetches the file and verifies its checksum. Archives are extracted into the directory.
+http_download() {
+  local address="$1" dst="$2" checksum="$3"
+  local name="${address%%\?*}"
+  name="${name##*/}"
+  local tmp
+  tmp=$(mktemp -d)
+  curl --fail --silent --show-error --location --output "$tmp/$name" "$address" || fail "could not download '$address'"
+  verify_checksum "$tmp/$name" "$checksum"
+  mkdir -p "$dst"
+  case "$name" in
+    *.zip) unzip -q -o "$tmp/$name" -d "$dst" ;;
+    *.tar.gz | *.tgz) tar -xzf "$tmp/$name" -C "$dst" ;;
+    *.tar) tar -xf "$tmp/$name" -C "$dst" ;;
+    *) cp "$tmp/$name" "$dst/" ;;
+  esac
+  rm -rf "$tmp"
+}
+
+# download puts the address into the directory. Other getters than git, http and the mounted ConfigMaps
+# and Secrets require the runner.
+download() {
+  local address="$1" dst="$2" checksum="$3"
+  case "$address" in
+    file://*)
+      # The mounted ConfigMaps and Secrets, their keys are links into hidden directories
+      local src="${address#file://}"
+      mkdir -p "$dst"
+      if [ -d "$src" ]; then
+        [ -z "$checksum" ] || fail "the checksum of the directory '$src' can not be verified"
+        find -L "$src" -maxdepth 1 -type f -exec cp {} "$dst/" \;
+      else
+        verify_checksum "$src" "$checksum"
+        cp -L "$src" "$dst/"
+      fi
+      ;;
+    git::*)
+      [ -z "$checksum" ] || fail "the checksum of the git repo '$address' can not be verified"
+      git_download "$address" "$dst"
+      ;;
+    http://* | https://*) http_download "$address" "$dst" "$checksum" ;;
+    *) fail "'$address' is not supported by the default setup script" ;;
+  esac
+}
+
+if [ -n "${TFO_MAIN_MODULE_REPO:-}" ]; then
+  git_download "git::$TFO_MAIN_MODULE_REPO//${TFO_MAIN_MODULE_REPO_SUBDIR:-.}?ref=${TFO_MAIN_MODULE_REPO_REF:-}" "$TFO_MAIN_MODULE"
+elif [ -n "${TFO_MAIN_MODULE_ADDRESS:-}" ]; then
+  download "$TFO_MAIN_MODULE_ADDRESS" "$TFO_MAIN_MODULE" "${TFO_MAIN_MODULE_CHECKSUM:-}"
+elif [ -f "$addons/.__TFO__ConfigMapModule.json" ]; then
+  name=$(jq -r .name "$addons/.__TFO__ConfigMapModule.json")
+  key=$(jq -r '.key // ""' "$addons/.__TFO__ConfigMapModule.json")
+  data=$(kubectl get configmap "$name" --output json) || fail "could not get the ConfigMap '$name'"
+  for k in $(echo "$data" | jq -r '.data | keys[]'); do
+    if [ -z "$key" ] || [ "$k" = "$key" ]; then
+      echo "$data" | jq -r --arg k "$k" '.data[$k]' > "$TFO_MAIN_MODULE/$k"
+    fi
+  done
+fi
+
+if [ -f "$addons/.__TFO__ResourceDownloads.json" ]; then
+  count=$(jq length "$addons/.__TFO__ResourceDownloads.json")
+  for i in $(seq 0 $((count - 1))); do
+    item=$(jq ".[$i]" "$addons/.__TFO__ResourceDownloads.json")
+    address=$(echo "$item" | jq -r .address)
+    dst="$TFO_MAIN_MODULE/$(echo "$item" | jq -r '.path // "."')"
+    mkdir -p "$dst"
+    before=$(find "$dst" -maxdepth 1 -type f | sort)
+    download "$address" "$dst" "$(echo "$item" | jq -r '.checksum // ""')"
+    if [ "$(echo "$item" | jq -r .useAsVar)" = "true" ]; then
+      comm -13 <(echo "$before") <(find "$dst" -maxdepth 1 -type f | sort) >> "$var_files"
+    fi
+  done
+fi
+
+# The addons are the files rendered by the controller, eg the variables, except the task scripts
+find -L "$addons" -maxdepth 1 -type f ! -name '.__TFO__*' ! -name 'inline-*.sh' -exec cp {} "$TFO_MAIN_MODULE/" \;
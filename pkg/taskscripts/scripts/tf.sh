@@ -0,0 +1,194 @@
+#!/bin/bash
+# Default script of the terraform tasks. Runs terraform init, plan or apply in the generation's main module
+# and saves the outputs after an apply. The engine's binary, eg tofu, is run instead of terraform. Terragrunt
+# resources run terragrunt in its directory, with run-all for stacks. The refresh-only mode plans a refresh.
+set -o errexit -o nounset -o pipefail
+. "$TFO_TASK_EXEC_CONFIGMAP_SOURCE_PATH/common.sh"
+
+log_task
+setup_ssh
+start_tunnel
+
+export TF_IN_AUTOMATION=true
+engine="${TFO_ENGINE_BINARY:-terraform}"
+plan="$TFO_GENERATION_PATH/tfplan"
+[ -d "$TFO_MAIN_MODULE" ] || fail "the main module '$TFO_MAIN_MODULE' does not exist"
+cd "$TFO_MAIN_MODULE"
+
+run_all=false
+if [ "${TFO_TERRAGRUNT:-}" = "true" ]; then
+  command -v terragrunt > /dev/null || fail "terragrunt is not installed in the task image"
+  if [ -n "${TFO_TERRAGRUNT_VERSION:-}" ]; then
+    version=$(terragrunt --version | awk '{print $NF}')
+    [ "${version#v}" = "${TFO_TERRAGRUNT_VERSION#v}" ] ||
+      fail "terragrunt is at version $version instead of $TFO_TERRAGRUNT_VERSION"
+  fi
+  cd "${TFO_TERRAGRUNT_DIR:-.}" || fail "the terragrunt directory '${TFO_TERRAGRUNT_DIR:-.}' does not exist"
+  if [ -n "${TFO_TERRAGRUNT_CONFIG:-}" ]; then
+    export TERRAGRUNT_CONFIG="$PWD/$TFO_TERRAGRUNT_CONFIG"
+  fi
+  # The output of the modules is prefixed with their path to tell apart their plans
+  export TERRAGRUNT_TFPATH="$engine" TERRAGRUNT_NON_INTERACTIVE=true TERRAGRUNT_INCLUDE_MODULE_PREFIX=true
+  engine=terragrunt
+  if [ "${TFO_TERRAGRUNT_RUN_ALL:-}" = "true" ]; then
+    run_all=true
+    # Each module keeps its plan in its own working directory
+    plan=tfplan
+  fi
+fi
+
+# run runs a command of the engine, or of terragrunt for each module of a run-all stack
+run() {
+  if [ "$run_all" = "true" ]; then
+    terragrunt run-all "$@"
+  else
+    "$engine" "$@"
+  fi
+}
+
+# retention_minutes converts a duration, eg 720h0m0s, to minutes
+retention_minutes() {
+  echo "$1" | awk '{
+    m = 0; s = $0
+    while (match(s, /^[0-9.]+[hms]/)) {
+      v = substr(s, 1, RLENGTH); u = substr(v, length(v)); n = substr(v, 1, length(v) - 1)
+      if (u == "h") m += n * 60; else if (u == "m") m += n; else m += n / 60
+      s = substr(s, RLENGTH + 1)
+    }
+    printf "%d\n", m
+  }'
+}
+
+# collect_plugin_cache marks the provider versions linked by this init and removes the versions of the
+# cache that have not been marked for the retention period. Versions marked since the oldest running
+# workflow started, TFO_PLUGIN_CACHE_KEEP_SINCE, are kept.
+collect_plugin_cache() {
+  local cache="$TF_PLUGIN_CACHE_DIR" minutes
+  minutes=$(retention_minutes "$TFO_PLUGIN_CACHE_RETENTION")
+  if [ -n "${TFO_PLUGIN_CACHE_KEEP_SINCE:-}" ]; then
+    local since=$((($(date +%s) - TFO_PLUGIN_CACHE_KEEP_SINCE) / 60 + 1))
+    if [ "$since" -gt "$minutes" ]; then
+      minutes=$since
+    fi
+  fi
+  for dir in .terraform/providers/*/*/*/*; do
+    if [ -d "$cache/${dir#.terraform/providers/}" ]; then
+      touch "$cache/${dir#.terraform/providers/}/.tfo-last-used"
+    fi
+  done
+  find "$cache" -mindepth 4 -maxdepth 4 -type d | while read -r version; do
+    if [ ! -f "$version/.tfo-last-used" ]; then
+      touch "$version/.tfo-last-used"
+    elif [ -n "$(find "$version/.tfo-last-used" -mmin +"$minutes")" ]; then
+      echo "Removing $version from the plugin cache"
+      rm -rf "$version"
+    fi
+  done
+}
+
+# init_workspace runs init and creates the workspace selected by TF_WORKSPACE when it does not exist. The
+# workspace commands refuse to run while TF_WORKSPACE overrides the selected workspace.
+init_workspace() {
+  if [ -z "${TF_WORKSPACE:-}" ]; then
+    run init -input=false
+    return
+  fi
+  local workspace="$TF_WORKSPACE"
+  (
+    unset TF_WORKSPACE
+    run init -input=false && run workspace select -or-create "$workspace"
+  ) || fail "could not initialize the workspace '$workspace'"
+}
+
+# delete_workspace deletes the workspace once its resources are destroyed. The resource is deleted even
+# when the workspace can not be.
+delete_workspace() {
+  if [ -z "${TF_WORKSPACE:-}" ] || [ "$TF_WORKSPACE" = "default" ]; then
+    return
+  fi
+  local workspace="$TF_WORKSPACE"
+  (
+    unset TF_WORKSPACE
+    run workspace select default && run workspace delete "$workspace"
+  ) || echo "Could not delete the workspace '$workspace'"
+}
+
+# init holds the plugin cache's lock since the engine does not support concurrent writes to the cache
+init() {
+  if [ -z "${TFO_PLUGIN_CACHE_LOCK_FILE:-}" ]; then
+    init_workspace
+    return
+  fi
+  exec 9> "$TFO_PLUGIN_CACHE_LOCK_FILE"
+  flock 9 || fail "could not lock the plugin cache"
+  init_workspace
+  # The providers linked by terragrunt are in its cache so they can not be marked
+  if [ -n "${TFO_PLUGIN_CACHE_RETENTION:-}" ] && [ "${TFO_TERRAGRUNT:-}" != "true" ]; then
+    collect_plugin_cache
+  fi
+  flock --unlock 9
+}
+
+# save_outputs writes the outputs to the outputs secret. Strings are saved as is and other types as json.
+save_outputs() {
+  local data
+  data=$("$engine" output -json | jq --arg only "${TFO_OUTPUTS_TO_INCLUDE:-}" --arg omit "${TFO_OUTPUTS_TO_OMIT:-}" '
+    ($only | split(",") | map(select(. != ""))) as $only
+    | ($omit | split(",") | map(select(. != ""))) as $omit
+    | to_entries
+    | map(select((($only | length) == 0 or (.key | IN($only[]))) and ((.key | IN($omit[])) | not)))
+    | map({key: .key, value: ((if (.value.value | type) == "string" then .value.value else (.value.value | tojson) end) | @base64)})
+    | from_entries')
+  kubectl patch secret "$TFO_OUTPUTS_SECRET_NAME" --type json \
+    --patch "[{\"op\": \"add\", \"path\": \"/data\", \"value\": $data}]" || fail "could not save the outputs"
+}
+
+var_files=()
+if [ -f "$TFO_GENERATION_PATH/var-files" ]; then
+  while read -r file; do
+    var_files+=("-var-file=$file")
+  done < "$TFO_GENERATION_PATH/var-files"
+fi
+# The secret values are loaded last so they take precedence over the other variable files
+if [ -f "${TFO_SECRET_VAR_FILE:-}" ]; then
+  var_files+=("-var-file=$TFO_SECRET_VAR_FILE")
+fi
+
+case "$TFO_TASK" in
+  init | init-delete)
+    init
+    ;;
+  plan)
+    # The refresh-only mode plans to update the state to match the infrastructure
+    mode_args=()
+    if [ "${TFO_MODE:-}" = "refresh-only" ]; then
+      mode_args=(-refresh-only)
+    fi
+    run plan -input=false ${mode_args[@]+"${mode_args[@]}"} ${var_files[@]+"${var_files[@]}"} -out="$plan"
+    ;;
+  plan-delete)
+    run plan -input=false -destroy ${var_files[@]+"${var_files[@]}"} -out="$plan"
+    ;;
+  apply-delete)
+    if [ "$run_all" = "true" ]; then
+      # run-all destroy removes the modules in the reverse order of their dependencies
+      run destroy -input=false -auto-approve ${var_files[@]+"${var_files[@]}"}
+    else
+      run apply -input=false "$plan"
+    fi
+    delete_workspace
+    ;;
+  apply)
+    run apply -input=false "$plan"
+    if [ "${TFO_SAVE_OUTPUTS:-}" = "true" ]; then
+      if [ "$run_all" = "true" ]; then
+        echo "The outputs of a run-all stack are not saved"
+      else
+        save_outputs
+      fi
+    fi
+    ;;
+  *)
+    fail "unknown terraform task '$TFO_TASK'"
+    ;;
+esac
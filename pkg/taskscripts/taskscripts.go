@@ -0,0 +1,62 @@
+// Package taskscripts has the default scripts of the setup, terraform and script tasks. The scripts are
+// shipped to the task pods in a ConfigMap managed by the controller so the tasks do not download any
+// executable code.
+package taskscripts
+
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+)
+
+//go:embed scripts/*.sh
+var scripts embed.FS
+
+const (
+	// ConfigMapPrefix is the prefix of the name of the ConfigMap with the scripts. The name ends with the
+	// Version of the scripts.
+	ConfigMapPrefix = "tfo-task-scripts-"
+
+	// Setup is the default script of the setup tasks
+	Setup = "setup.sh"
+
+	// Terraform is the default script of the terraform tasks
+	Terraform = "tf.sh"
+
+	// Noop is the default script of the script tasks
+	Noop = "noop.sh"
+)
+
+// Scripts returns the scripts by file name
+func Scripts() map[string]string {
+	entries, err := scripts.ReadDir("scripts")
+	if err != nil {
+		panic(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		b, err := scripts.ReadFile(path.Join("scripts", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		files[entry.Name()] = string(b)
+	}
+	return files
+}
+
+// Version is a hash of the scripts. It changes whenever a script changes.
+func Version() string {
+	files := Scripts()
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%d:%s", name, len(files[name]), files[name])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:10]
+}
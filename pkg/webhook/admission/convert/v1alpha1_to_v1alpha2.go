@@ -29,6 +29,8 @@ func ConvertV1alpha1ToV1alpha2(rawRequest []byte) ([]byte, runtime.Object, error
 	}
 	want.ObjectMeta = have.ObjectMeta
 	want.Spec.TerraformVersion = have.Spec.TerraformVersion
+	// Resources from before the engines were added are run by terraform
+	want.Spec.Engine = &tfv1alpha2.Engine{Name: tfv1alpha2.EngineTerraform}
 	want.Spec.TerraformModule.Source = have.Spec.TerraformModule
 	want.Spec.TerraformModule.Inline = have.Spec.TerraformModuleInline
 	want.Spec.TerraformModule.ConfigMapSelector = (*tfv1alpha2.ConfigMapSelector)(have.Spec.TerraformModuleConfigMap)
@@ -43,6 +45,17 @@ func ConvertV1alpha1ToV1alpha2(rawRequest []byte) ([]byte, runtime.Object, error
 	want.Spec.OutputsToOmit = have.Spec.OutputsToOmit
 	want.Spec.ServiceAccount = have.Spec.ServiceAccount
 
+	if have.Spec.ExportRepo != nil {
+		want.Spec.ExportRepo = &tfv1alpha2.ExportRepo{
+			Address:        have.Spec.ExportRepo.Address,
+			TFVarsFile:     have.Spec.ExportRepo.TFVarsFile,
+			ConfFile:       have.Spec.ExportRepo.ConfFile,
+			GitEmail:       have.Spec.ExportRepo.GitEmail,
+			GitUsername:    have.Spec.ExportRepo.GitUsername,
+			RetryOnFailure: have.Spec.ExportRepo.RetryOnFailure,
+		}
+	}
+
 	scriptImageConfig := convertImageConfig("script", have.Spec.ScriptRunner, have.Spec.ScriptRunnerVersion, have.Spec.ScriptRunnerPullPolicy)
 	terraformImageConfig := convertImageConfig("terraform", have.Spec.TerraformRunner, "", have.Spec.TerraformRunnerPullPolicy)
 	setupImageConfig := convertImageConfig("setup", have.Spec.SetupRunner, have.Spec.SetupRunnerVersion, have.Spec.SetupRunnerPullPolicy)
@@ -177,7 +190,11 @@ func ConvertV1alpha1ToV1alpha2(rawRequest []byte) ([]byte, runtime.Object, error
 			if want.Spec.Setup == nil {
 				want.Spec.Setup = &tfv1alpha2.Setup{}
 			}
-			want.Spec.Setup.ResourceDownloads = append(want.Spec.Setup.ResourceDownloads, tfv1alpha2.ResourceDownload(*resourceDownload))
+			want.Spec.Setup.ResourceDownloads = append(want.Spec.Setup.ResourceDownloads, tfv1alpha2.ResourceDownload{
+				Address:  resourceDownload.Address,
+				Path:     resourceDownload.Path,
+				UseAsVar: resourceDownload.UseAsVar,
+			})
 		}
 	}
 
@@ -44,6 +44,9 @@ func ConvertV1alpha2ToV1alpha1(rawRequest []byte) ([]byte, runtime.Object, error
 	}
 	want.Status.Phase = tfv1alpha1.StatusPhase(have.Status.Phase)
 	want.Status.Exported = tfv1alpha1.ExportedFalse
+	if have.Spec.ExportRepo != nil && have.Status.ExportedGeneration == have.Generation {
+		want.Status.Exported = tfv1alpha1.ExportedTrue
+	}
 	want.Status.LastCompletedGeneration = have.Status.LastCompletedGeneration
 	rawResponse, _ := json.Marshal(want)
 	log.Print("took ", time.Since(start).String(), " to complete conversion")
@@ -0,0 +1,254 @@
+package gitpush
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// maxPayloadBytes is larger than the 25MB GitHub caps payloads at
+	maxPayloadBytes = 26 << 20
+
+	zeroCommit = "0000000000000000000000000000000000000000"
+)
+
+// PushEvent is the part of a push event common to all the supported git servers
+type PushEvent struct {
+	// Provider is the git server that sent the event, ie github, gitlab or gitea.
+	Provider string
+
+	// Ref is the full name of the ref that was pushed, eg refs/heads/main.
+	Ref string
+
+	// Commit is the sha the ref points to after the push.
+	Commit string
+
+	// RepoURLs are the urls of the repo, like the https and ssh clone urls.
+	RepoURLs []string
+
+	// DefaultBranch is the branch followed by the sources that do not set a ref, eg main.
+	DefaultBranch string
+}
+
+// PushHandlerFunc handles a validated push event and returns the names of the resources that were
+// triggered.
+type PushHandlerFunc func(ctx context.Context, event PushEvent) ([]string, error)
+
+// Webhook receives push events from GitHub, GitLab and Gitea. Every request must be signed with the shared
+// secret; GitHub and Gitea sign the payload with an HMAC and GitLab sends the secret as a token.
+type Webhook struct {
+	log    logr.Logger
+	secret []byte
+	onPush PushHandlerFunc
+}
+
+func NewWebhook(secret []byte, onPush PushHandlerFunc, log logr.Logger) Webhook {
+	return Webhook{
+		log:    log,
+		secret: secret,
+		onPush: onPush,
+	}
+}
+
+type response struct {
+	Message   string   `json:"message"`
+	Triggered []string `json:"triggered,omitempty"`
+}
+
+func (h Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.log
+	if r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, response{Message: "only POST is allowed"})
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+	if err != nil {
+		logger.Error(err, "failed to read git webhook request")
+		writeResponse(w, http.StatusBadRequest, response{Message: "could not read the request"})
+		return
+	}
+
+	provider, eventType := getProviderAndEventType(r.Header)
+	if provider == "" {
+		writeResponse(w, http.StatusBadRequest, response{Message: "unsupported git server"})
+		return
+	}
+	if err := h.validate(provider, r.Header, body); err != nil {
+		logger.Info(fmt.Sprintf("Rejected %s webhook: %s", provider, err))
+		writeResponse(w, http.StatusUnauthorized, response{Message: err.Error()})
+		return
+	}
+	if !isPushEvent(eventType) {
+		writeResponse(w, http.StatusOK, response{Message: fmt.Sprintf("ignored '%s' event", eventType)})
+		return
+	}
+
+	event, err := parsePushEvent(provider, body)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Message: err.Error()})
+		return
+	}
+	if event.Commit == "" || event.Commit == zeroCommit {
+		writeResponse(w, http.StatusOK, response{Message: fmt.Sprintf("ignored deletion of '%s'", event.Ref)})
+		return
+	}
+
+	triggered, err := h.onPush(r.Context(), event)
+	if err != nil {
+		logger.Error(err, "failed to handle git push")
+		writeResponse(w, http.StatusInternalServerError, response{Message: "failed to handle the push"})
+		return
+	}
+	logger.Info(fmt.Sprintf("Push of '%s' to %s triggered %d resources", event.Ref, event.RepoURLs, len(triggered)))
+	writeResponse(w, http.StatusOK, response{Message: "ok", Triggered: triggered})
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	b, _ := json.Marshal(resp)
+	w.Write(b)
+}
+
+// getProviderAndEventType finds the git server by its event header. Gitea also sends GitHub's headers so
+// it has to be checked first.
+func getProviderAndEventType(header http.Header) (string, string) {
+	if eventType := header.Get("X-Gitea-Event"); eventType != "" {
+		return "gitea", eventType
+	}
+	if eventType := header.Get("X-Gitlab-Event"); eventType != "" {
+		return "gitlab", eventType
+	}
+	if eventType := header.Get("X-GitHub-Event"); eventType != "" {
+		return "github", eventType
+	}
+	return "", ""
+}
+
+func isPushEvent(eventType string) bool {
+	switch eventType {
+	case "push", "Push Hook", "Tag Push Hook":
+		return true
+	}
+	return false
+}
+
+func (h Webhook) validate(provider string, header http.Header, body []byte) error {
+	if len(h.secret) == 0 {
+		return fmt.Errorf("the webhook secret is not configured")
+	}
+	switch provider {
+	case "gitlab":
+		token := header.Get("X-Gitlab-Token")
+		if subtle.ConstantTimeCompare([]byte(token), h.secret) != 1 {
+			return fmt.Errorf("invalid token")
+		}
+		return nil
+	case "gitea":
+		signature := header.Get("X-Gitea-Signature")
+		if signature == "" {
+			signature = strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+		}
+		return validateSignature(h.secret, signature, body)
+	default:
+		signature := header.Get("X-Hub-Signature-256")
+		if !strings.HasPrefix(signature, "sha256=") {
+			return fmt.Errorf("missing X-Hub-Signature-256")
+		}
+		return validateSignature(h.secret, strings.TrimPrefix(signature, "sha256="), body)
+	}
+}
+
+func validateSignature(secret []byte, signature string, body []byte) error {
+	want, err := hex.DecodeString(signature)
+	if err != nil || len(want) == 0 {
+		return fmt.Errorf("invalid signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+type pushPayload struct {
+	Ref         string `json:"ref"`
+	After       string `json:"after"`
+	CheckoutSHA string `json:"checkout_sha"`
+	Repository  struct {
+		CloneURL      string `json:"clone_url"`
+		SSHURL        string `json:"ssh_url"`
+		HTMLURL       string `json:"html_url"`
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		Homepage      string `json:"homepage"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Project struct {
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+func parsePushEvent(provider string, body []byte) (PushEvent, error) {
+	payload := pushPayload{}
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return PushEvent{}, fmt.Errorf("could not parse push event: %v", err)
+	}
+	if payload.Ref == "" {
+		return PushEvent{}, fmt.Errorf("push event does not have a ref")
+	}
+
+	commit := payload.After
+	if commit == "" {
+		commit = payload.CheckoutSHA
+	}
+	urls := []string{}
+	for _, u := range []string{
+		payload.Repository.CloneURL,
+		payload.Repository.SSHURL,
+		payload.Repository.HTMLURL,
+		payload.Repository.GitHTTPURL,
+		payload.Repository.GitSSHURL,
+		payload.Repository.Homepage,
+		payload.Project.GitHTTPURL,
+		payload.Project.GitSSHURL,
+		payload.Project.WebURL,
+	} {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return PushEvent{}, fmt.Errorf("push event does not have a repository url")
+	}
+
+	// GitLab only sends the default branch with the project
+	defaultBranch := payload.Repository.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = payload.Project.DefaultBranch
+	}
+
+	return PushEvent{
+		Provider:      provider,
+		Ref:           payload.Ref,
+		Commit:        commit,
+		RepoURLs:      urls,
+		DefaultBranch: defaultBranch,
+	}, nil
+}
@@ -0,0 +1,187 @@
+package gitpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+const testSecret = "s3cr3t"
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook(t *testing.T) {
+	var received []PushEvent
+	onPush := func(ctx context.Context, event PushEvent) ([]string, error) {
+		received = append(received, event)
+		return []string{"default/app"}, nil
+	}
+	server := httptest.NewServer(NewWebhook([]byte(testSecret), onPush, logr.Discard()))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		payload    string
+		headers    func(body []byte) map[string]string
+		wantStatus int
+		wantEvent  *PushEvent
+	}{
+		{
+			name:    "github push",
+			payload: "testdata/github-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(body)}
+			},
+			wantStatus: http.StatusOK,
+			wantEvent: &PushEvent{
+				Provider:      "github",
+				Ref:           "refs/heads/main",
+				Commit:        "59b20b8d5c6ff8d09518454d4dd8b7a30f095ab5",
+				RepoURLs:      []string{"https://github.com/octo-org/infra.git", "git@github.com:octo-org/infra.git", "https://github.com/octo-org/infra"},
+				DefaultBranch: "main",
+			},
+		},
+		{
+			name:    "github bad signature",
+			payload: "testdata/github-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign([]byte("other"))}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "github unsigned",
+			payload: "testdata/github-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "push"}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "github ping",
+			payload: "testdata/github-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + sign(body)}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:    "gitlab push",
+			payload: "testdata/gitlab-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": testSecret}
+			},
+			wantStatus: http.StatusOK,
+			wantEvent: &PushEvent{
+				Provider: "gitlab",
+				Ref:      "refs/heads/release",
+				Commit:   "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				RepoURLs: []string{
+					"https://gitlab.example.com/platform/infra.git",
+					"git@gitlab.example.com:platform/infra.git",
+					"https://gitlab.example.com/platform/infra",
+					"https://gitlab.example.com/platform/infra.git",
+					"git@gitlab.example.com:platform/infra.git",
+					"https://gitlab.example.com/platform/infra",
+				},
+				DefaultBranch: "main",
+			},
+		},
+		{
+			name:    "gitlab bad token",
+			payload: "testdata/gitlab-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "wrong"}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "gitea push",
+			payload: "testdata/gitea-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{"X-Gitea-Event": "push", "X-GitHub-Event": "push", "X-Gitea-Signature": sign(body)}
+			},
+			wantStatus: http.StatusOK,
+			wantEvent: &PushEvent{
+				Provider:      "gitea",
+				Ref:           "refs/tags/v1.2.0",
+				Commit:        "bffeb74224043ba2feb48d137756c8a9331c449a",
+				RepoURLs:      []string{"https://gitea.example.com/ops/modules.git", "ssh://git@gitea.example.com:2222/ops/modules.git", "https://gitea.example.com/ops/modules"},
+				DefaultBranch: "main",
+			},
+		},
+		{
+			name:    "unknown server",
+			payload: "testdata/github-push.json",
+			headers: func(body []byte) map[string]string {
+				return map[string]string{}
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		received = nil
+		body, err := ioutil.ReadFile(tt.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tt.headers(body) {
+			req.Header.Set(k, v)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantStatus, resp.StatusCode)
+		}
+		if tt.wantEvent == nil {
+			if len(received) != 0 {
+				t.Errorf("%s: expected no push to be handled, got %v", tt.name, received)
+			}
+			continue
+		}
+		if len(received) != 1 || !reflect.DeepEqual(received[0], *tt.wantEvent) {
+			t.Errorf("%s: expected %v, got %v", tt.name, *tt.wantEvent, received)
+		}
+	}
+}
+
+func TestWebhookWithoutSecret(t *testing.T) {
+	onPush := func(ctx context.Context, event PushEvent) ([]string, error) {
+		t.Error("expected the push to be rejected")
+		return nil, nil
+	}
+	server := httptest.NewServer(NewWebhook(nil, onPush, logr.Discard()))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte(`{"ref":"refs/heads/main"}`)))
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}